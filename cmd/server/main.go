@@ -2,16 +2,27 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
+	"sync"
 	"syscall"
 	"time"
 
+	"alerta_climatica/internal/incidents"
+	"alerta_climatica/internal/integrations/sms"
+	"alerta_climatica/internal/integrations/sms/gsm"
+	"alerta_climatica/internal/integrations/weather"
+	"alerta_climatica/internal/meteo"
+	"alerta_climatica/internal/notify"
 	"alerta_climatica/internal/processing"
+	"alerta_climatica/internal/sensors"
 	"alerta_climatica/internal/server"
+	"alerta_climatica/internal/spam"
 	"alerta_climatica/internal/storage"
 )
 
@@ -43,13 +54,178 @@ func main() {
 		}
 	}
 
+	// Reglas de escalamiento por corroboración (JSON, ver README); sin archivo
+	// se usan las reglas por defecto.
+	if path := os.Getenv("ESCALATION_RULES_FILE"); path != "" {
+		if err := loadEscalationRules(st, path); err != nil {
+			log.Printf("warning: cannot load escalation rules from %s: %v", path, err)
+		} else {
+			log.Printf("loaded escalation rules from %s", path)
+		}
+	}
+
+	// Plantillas de difusión (JSON, ver README) y, con SMS_GSM7=1, quitado de
+	// tildes fuera de GSM-7 para que cada SMS rinda 160 caracteres.
+	templates := notify.NewRenderer()
+	templates.SetStripAccents(os.Getenv("SMS_GSM7") == "1")
+	if path := os.Getenv("SMS_TEMPLATES_FILE"); path != "" {
+		if err := loadTemplates(templates, path); err != nil {
+			log.Printf("warning: cannot load SMS templates from %s: %v", path, err)
+		} else {
+			log.Printf("loaded SMS templates from %s", path)
+		}
+	}
+
+	// Gateways HTTP de SMS (operadoras locales, apps Android) definidos en
+	// SMS_GATEWAYS_FILE: cada uno recibe en /api/inbound/{nombre} y puede
+	// usarse para enviar con SMS_SENDER_GATEWAY.
+	gateways := loadGateways(os.Getenv("SMS_GATEWAYS_FILE"))
+
+	// Difusión SMS a suscriptores cuando una zona escala a amarillo o rojo.
+	// Con un módem GSM (GSM_MODEM_DEVICE) los SMS salen por él, si no por el
+	// gateway HTTP indicado; sin proveedor configurado se usa un Sender falso
+	// que escribe en SMS_OUTBOX_FILE (o en el log si no se define).
+	var sender sms.Sender = sms.NewLogSender(os.Getenv("SMS_OUTBOX_FILE"))
+	if name := os.Getenv("SMS_SENDER_GATEWAY"); name != "" {
+		found := false
+		for _, g := range gateways {
+			if g.Name == name {
+				sender = sms.NewHTTPSender(g, nil)
+				found = true
+			}
+		}
+		if !found {
+			log.Printf("warning: SMS_SENDER_GATEWAY %q not defined in SMS_GATEWAYS_FILE", name)
+		}
+	}
+	modem := openModem(os.Getenv("GSM_MODEM_DEVICE"))
+	if modem != nil {
+		sender = modem
+	}
+	dispatcher := notify.NewDispatcher(store, sender, templates)
+	st.OnZoneChange(func(c server.ZoneStatusChange, a processing.Alert) {
+		dispatcher.ZoneChanged(c.Zone, c.Prev, c.Status, a)
+	})
+
+	// Agrupación de alertas en incidentes (misma zona y fenómeno, separadas por
+	// menos de INCIDENT_GAP_MIN minutos).
+	clusterer := incidents.NewClusterer(store, time.Duration(envInt("INCIDENT_GAP_MIN", 120))*time.Minute)
+	st.OnAlert(func(a processing.Alert) { clusterer.Add(a) })
+
 	// Configurar zonas simuladas (puedes ajustar o cargar de config en el futuro)
 	zones := []string{"Zona Norte", "Zona Centro", "Zona Sur"}
 
+	// Número de shards (workers) configurable vía PROCESSOR_SHARDS. Los mensajes
+	// de una misma zona siempre caen en el mismo shard y se procesan en orden.
+	shards := envInt("PROCESSOR_SHARDS", 3)
+
 	proc := processing.NewProcessor(zones, st.AddAlert)
-	proc.StartWorkers(3) // 3 workers concurrentes (uno por zona simulada)
+	// Duplicados, cadenas, exceso de envíos y números bloqueados se descartan
+	// primero (quedan registrados con el motivo); así tampoco reciben
+	// respuesta a sus comandos.
+	proc.AddInterceptor(spam.NewFilter(store, spam.DefaultConfig()).Handle)
+	// Comandos SMS (ALTA/BAJA/ZONAS) se atienden antes de la detección y no generan alertas.
+	commands := notify.NewCommands(store, sender, st.ZoneNames)
+	proc.AddInterceptor(commands.Handle)
+	// Mensajes que hacen entrar en pánico a un worker van a dead-letter.
+	proc.SetErrorHandler(st.RecordFailedMessage)
+	// DEMO_LATENCY_MS > 0 simula sensores lentos (pausa tras cada mensaje).
+	if ms := envInt("DEMO_LATENCY_MS", 0); ms > 0 {
+		proc.SetDemoLatency(time.Duration(ms) * time.Millisecond)
+	}
+	proc.SetShards(shards)
+	// SMS largos que llegan en varias partes se unen antes de la detección:
+	// por su referencia de concatenación (también las del módem GSM) o, si no
+	// la traen, por llegar seguidos del mismo remitente. Los comandos nunca
+	// se unen con un reporte.
+	proc.SetReassembly(processing.DefaultPartsTimeout)
+	proc.SetJoinWindow(time.Duration(envInt("SMS_JOIN_WINDOW_SEC", 4))*time.Second, commands.IsCommand)
+	// Mensajes no procesados al vencer el plazo de cierre se guardan y se
+	// reinyectan en el siguiente arranque.
+	proc.SetQueue(store)
+	if err := proc.Start(context.Background()); err != nil {
+		log.Fatalf("no se pudo iniciar el procesador: %v", err)
+	}
+	st.SetReplayer(proc.Submit)
+
+	// Tareas en segundo plano (reintentos, módem, tiempo): se detienen con
+	// stopRetry y el cierre espera a que terminen antes de cerrar el dispatcher,
+	// porque varias generan alertas.
+	retryCtx, stopRetry := context.WithCancel(context.Background())
+	defer stopRetry()
+	var background sync.WaitGroup
+	goBackground := func(run func(context.Context)) {
+		background.Add(1)
+		go func() {
+			defer background.Done()
+			run(retryCtx)
+		}()
+	}
+	// Reintento automático (backoff exponencial) de alertas que no se pudieron persistir.
+	goBackground(func(ctx context.Context) { st.RunDeadLetterRetry(ctx, 5*time.Second) })
+	// Reintento (backoff exponencial) de SMS de difusión que no se pudieron enviar.
+	goBackground(func(ctx context.Context) { dispatcher.RunRetries(ctx, 10*time.Second) })
+
+	// Reportes que llegan al módem GSM: se consultan cada 5 s y la zona se
+	// toma del inicio del texto ("Zona Sur: ...").
+	if modem != nil {
+		modem.SetDeliver(proc.Submit)
+		modem.SetZoneResolver(sms.PrefixZoneResolver(st.ZoneNames, "Zona Centro"))
+		goBackground(func(ctx context.Context) { modem.Run(ctx, 5*time.Second) })
+	}
+
+	// Alertas automáticas por tiempo: con WEATHER_POLL_MIN se consulta
+	// Open-Meteo (u OPEN_METEO_URL) en el centroide de cada zona almacenada y
+	// se alerta al superar los umbrales (WEATHER_RULES_FILE o los de fábrica).
+	// Con el mismo pronóstico se mantienen las pre-alertas a 24-72 h.
+	// WEATHER_VALIDATE=1 contrasta cada reporte ciudadano con el tiempo
+	// actual de su zona y ajusta su confianza.
+	client := weather.NewOpenMeteo(os.Getenv("OPEN_METEO_URL"), nil)
+	if os.Getenv("WEATHER_VALIDATE") == "1" {
+		st.SetWeatherCheck(meteo.NewValidator(client, st.ListStoredZones).Check)
+	}
+	var forecaster *meteo.Forecaster
+	if os.Getenv("WEATHER_POLL_MIN") != "" {
+		sched := meteo.NewScheduler(client, st.ListStoredZones, st.AddAlert)
+		if path := os.Getenv("WEATHER_RULES_FILE"); path != "" {
+			if err := loadWeatherRules(sched, path); err != nil {
+				log.Printf("warning: cannot load weather rules from %s: %v", path, err)
+			}
+		}
+		every := time.Duration(envInt("WEATHER_POLL_MIN", 15)) * time.Minute
+		goBackground(func(ctx context.Context) { sched.Run(ctx, every) })
+		forecaster = meteo.NewForecaster(client, st.ListStoredZones)
+		goBackground(func(ctx context.Context) { forecaster.Run(ctx, every) })
+	}
 
 	srv := server.NewServer(st, proc)
+	srv.SetRenderer(templates)
+
+	// Webhook de SMS entrantes de Twilio; solo se monta con token configurado.
+	// La zona se toma del inicio del texto ("Zona Sur: ...").
+	var twilio *sms.TwilioReceiver
+	if token := os.Getenv("TWILIO_AUTH_TOKEN"); token != "" {
+		twilio = sms.NewTwilioReceiver(token, os.Getenv("PUBLIC_URL"), proc.Submit)
+		twilio.SetZoneResolver(sms.PrefixZoneResolver(st.ZoneNames, "Zona Centro"))
+		srv.Handle("/api/sms/twilio", twilio)
+	}
+	// Reportes de entrega: firmados por Twilio o con SMS_STATUS_TOKEN en
+	// Authorization: Bearer. Los fallidos se reintentan por el dispatcher.
+	srv.SetDeliveryAuth(twilio, os.Getenv("SMS_STATUS_TOKEN"))
+	st.SetDeliveryReporter(dispatcher.ReportDelivery)
+	if len(gateways) > 0 {
+		srv.Handle("/api/inbound/{gateway}", sms.NewGateways(gateways, proc.Submit, sms.PrefixZoneResolver(st.ZoneNames, "Zona Centro")))
+	}
+	if forecaster != nil {
+		srv.Handle("/api/prealerts", forecaster)
+	}
+
+	// Estaciones automáticas (limnímetros, pluviómetros): registro y lecturas.
+	// Con SENSORS_TOKEN las estaciones deben enviar Authorization: Bearer.
+	sensorSvc := sensors.NewService(store, st.AddAlert)
+	sensorSvc.SetToken(os.Getenv("SENSORS_TOKEN"))
+	srv.Handle("/api/admin/sensors", http.HandlerFunc(sensorSvc.ServeRegistry))
+	srv.Handle("/api/sensors/{id}/readings", http.HandlerFunc(sensorSvc.ServeReadings))
 
 	port := os.Getenv("PORT")
 	if port == "" {
@@ -61,6 +237,7 @@ func main() {
 		Handler:           srv.Router(),
 		ReadHeaderTimeout: 5 * time.Second,
 	}
+	s.RegisterOnShutdown(srv.CloseStreams)
 
 	// Señales para shutdown ordenado
 	stop := make(chan os.Signal, 1)
@@ -78,20 +255,132 @@ func main() {
 	<-stop
 	log.Println("Shutdown: señal recibida, iniciando cierre ordenado...")
 
-	// 1) Parar de aceptar nuevas conexiones
+	// 1) Parar de aceptar nuevas conexiones (webhooks, /api/sms, sensores)
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 	if err := s.Shutdown(ctx); err != nil {
 		log.Printf("error durante server.Shutdown: %v", err)
 	}
 
-	// 2) Cerrar processor (espera a workers)
-	proc.Close()
+	// 2) Detener las tareas en segundo plano que generan alertas o mensajes
+	// (módem, tiempo, reintentos), dentro del mismo plazo.
+	stopRetry()
+	waited := make(chan struct{})
+	go func() {
+		background.Wait()
+		close(waited)
+	}()
+	select {
+	case <-waited:
+	case <-ctx.Done():
+		log.Println("warning: background tasks still running at shutdown deadline")
+	}
+
+	// 3) Cerrar processor: deja de aceptar mensajes y drena dentro del mismo plazo;
+	// lo que no alcance a procesarse queda en la cola durable.
+	if err := proc.Shutdown(ctx); err != nil {
+		log.Printf("error durante processor.Shutdown: %v", err)
+	}
+
+	// 4) Ya sin productores de alertas, terminar difusiones SMS en curso
+	dispatcher.Close()
 
-	// 3) Esperar que el state persista pendientes y cerrar store
+	// 5) Esperar que el state persista pendientes y cerrar store
 	if err := st.Close(); err != nil {
 		log.Printf("warning: error closing store: %v", err)
 	}
 
 	log.Println("Shutdown completo")
 }
+
+// loadGateways lee la configuración de gateways HTTP de SMS; sin archivo o con
+// errores no se monta ninguno.
+func loadGateways(path string) []sms.GatewayConfig {
+	if path == "" {
+		return nil
+	}
+	cfgs, err := sms.LoadGateways(path)
+	if err != nil {
+		log.Printf("warning: cannot load SMS gateways from %s: %v", path, err)
+		return nil
+	}
+	return cfgs
+}
+
+// openModem abre e inicializa el módem GSM en device ("" = sin módem).
+// GSM_MODEM_BAUD fija la velocidad y GSM_MODEM_TEXT=1 usa modo texto en vez de PDU.
+func openModem(device string) *gsm.Modem {
+	if device == "" {
+		return nil
+	}
+	port, err := gsm.OpenSerial(device, envInt("GSM_MODEM_BAUD", 115200))
+	if err != nil {
+		log.Printf("warning: cannot open GSM modem %s: %v", device, err)
+		return nil
+	}
+	mode := gsm.PDUMode
+	if os.Getenv("GSM_MODEM_TEXT") == "1" {
+		mode = gsm.TextMode
+	}
+	modem := gsm.NewModem(port, mode)
+	if err := modem.Init(); err != nil {
+		log.Printf("warning: GSM modem %s not responding: %v", device, err)
+		port.Close()
+		return nil
+	}
+	log.Printf("GSM modem ready on %s", device)
+	return modem
+}
+
+// loadEscalationRules lee un arreglo JSON de server.EscalationRule.
+func loadEscalationRules(st *server.State, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var rules []server.EscalationRule
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return err
+	}
+	return st.SetEscalationRules(rules)
+}
+
+// loadWeatherRules lee un arreglo JSON de reglas de umbral meteorológico.
+func loadWeatherRules(sched *meteo.Scheduler, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var rules []meteo.Rule
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return err
+	}
+	return sched.SetRules(rules)
+}
+
+// loadTemplates lee un arreglo JSON de plantillas de difusión.
+func loadTemplates(r *notify.Renderer, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var list []notify.Template
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	return r.SetTemplates(list)
+}
+
+// envInt lee un entero positivo de la variable de entorno name o devuelve def.
+func envInt(name string, def int) int {
+	v := os.Getenv(name)
+	if v == "" {
+		return def
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n <= 0 {
+		log.Printf("warning: invalid %s=%q, using %d", name, v, def)
+		return def
+	}
+	return n
+}
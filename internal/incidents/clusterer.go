@@ -0,0 +1,69 @@
+// Package incidents agrupa alertas relacionadas en incidentes: reportes de la
+// misma zona y fenómeno separados por menos de un intervalo se consideran el
+// mismo evento.
+package incidents
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"alerta_climatica/internal/processing"
+	"alerta_climatica/internal/storage"
+)
+
+// DefaultGap es la separación máxima entre reportes de un mismo incidente.
+const DefaultGap = 2 * time.Hour
+
+// Clusterer asigna cada alerta nueva a un incidente abierto o abre uno nuevo.
+// Una alerta pertenece a un incidente si cae dentro de él o a menos de gap de
+// sus extremos (los reportes pueden llegar desordenados).
+type Clusterer struct {
+	mu    sync.Mutex // serializa la búsqueda y creación de incidentes
+	store storage.IncidentStore
+	gap   time.Duration
+}
+
+// NewClusterer crea el agrupador; gap <= 0 usa DefaultGap.
+func NewClusterer(store storage.IncidentStore, gap time.Duration) *Clusterer {
+	if gap <= 0 {
+		gap = DefaultGap
+	}
+	return &Clusterer{store: store, gap: gap}
+}
+
+// Relevant indica si una alerta describe un fenómeno agrupable. Los mensajes
+// informativos no forman incidentes.
+func Relevant(a processing.Alert) bool {
+	return a.Type != "" && a.Type != "informativo"
+}
+
+// Add vincula a al incidente correspondiente y devuelve su id (0 si la alerta
+// no es agrupable). Pensado para registrarse con State.OnAlert.
+func (c *Clusterer) Add(a processing.Alert) int64 {
+	if !Relevant(a) {
+		return 0
+	}
+	id, err := c.assign(a)
+	if err != nil {
+		log.Printf("warning: cannot cluster alert %s into incident: %v", a.ID, err)
+		return 0
+	}
+	return id
+}
+
+func (c *Clusterer) assign(a processing.Alert) (int64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	inc, err := c.store.FindIncident(a.Zone, a.Type, a.Timestamp, c.gap)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+	case err != nil:
+		return 0, err
+	default:
+		return inc.ID, c.store.AttachAlert(inc.ID, a)
+	}
+	return c.store.CreateIncident(a)
+}
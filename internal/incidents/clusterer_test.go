@@ -0,0 +1,90 @@
+package incidents
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"alerta_climatica/internal/processing"
+	"alerta_climatica/internal/storage"
+)
+
+func TestClustererGroupsByZoneTypeAndGap(t *testing.T) {
+	store, err := storage.NewSQLite(filepath.Join(t.TempDir(), "incidents.db"))
+	if err != nil {
+		t.Fatalf("NewSQLite: %v", err)
+	}
+	defer store.Close()
+	c := NewClusterer(store, 30*time.Minute)
+
+	t0 := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	flood := func(id string, at time.Duration, sev string) processing.Alert {
+		return processing.Alert{ID: id, Zone: "Zona Sur", Type: "desborde", Severity: sev, Timestamp: t0.Add(at)}
+	}
+	a := c.Add(flood("a1", 0, "alta"))
+	b := c.Add(flood("a2", 10*time.Minute, "crítica"))
+	d := c.Add(flood("a3", 35*time.Minute, "alta"))
+	if a == 0 || a != b || b != d {
+		t.Fatalf("alerts within gap should share an incident: %d %d %d", a, b, d)
+	}
+	if other := c.Add(processing.Alert{ID: "h1", Zone: "Zona Sur", Type: "huaico", Severity: "alta", Timestamp: t0}); other == a {
+		t.Fatal("different phenomenon joined the flood incident")
+	}
+	if later := c.Add(flood("a4", 3*time.Hour, "alta")); later == a {
+		t.Fatal("alert after the gap joined the old incident")
+	}
+	if id := c.Add(processing.Alert{ID: "i1", Zone: "Zona Sur", Type: "informativo", Severity: "baja", Timestamp: t0}); id != 0 {
+		t.Fatalf("informational alert clustered into %d", id)
+	}
+
+	inc, err := store.GetIncident(a)
+	if err != nil {
+		t.Fatalf("GetIncident: %v", err)
+	}
+	if inc.ReportCount != 3 || inc.PeakSeverity != "crítica" || !inc.Start.Equal(t0) || !inc.End.Equal(t0.Add(35*time.Minute)) {
+		t.Fatalf("unexpected incident aggregate: %+v", inc)
+	}
+
+	// Dividir y volver a fusionar.
+	split, err := store.SplitIncident(a, []string{"a2"})
+	if err != nil {
+		t.Fatalf("SplitIncident: %v", err)
+	}
+	if inc, _ = store.GetIncident(a); inc.ReportCount != 2 || inc.PeakSeverity != "alta" {
+		t.Fatalf("original after split: %+v", inc)
+	}
+	if _, err := store.SplitIncident(a, []string{"a1", "a3"}); err != storage.ErrInvalidIncidentOp {
+		t.Fatalf("splitting every alert should fail, got %v", err)
+	}
+	if err := store.MergeIncidents(a, []int64{split}); err != nil {
+		t.Fatalf("MergeIncidents: %v", err)
+	}
+	if inc, _ = store.GetIncident(a); inc.ReportCount != 3 || inc.PeakSeverity != "crítica" || len(inc.AlertIDs) != 3 {
+		t.Fatalf("incident after merge: %+v", inc)
+	}
+	if _, err := store.GetIncident(split); err == nil {
+		t.Fatal("merged source incident still exists")
+	}
+
+	// Un reporte atrasado vuelve al incidente anterior aunque haya uno más reciente.
+	if late := c.Add(flood("a5", 40*time.Minute, "media")); late != a {
+		t.Fatalf("late alert opened or joined incident %d, want %d", late, a)
+	}
+
+	// Descartar el reporte crítico baja el pico y el conteo del incidente.
+	if err := store.SaveAlert(flood("a2", 10*time.Minute, "crítica")); err != nil {
+		t.Fatalf("SaveAlert: %v", err)
+	}
+	if err := store.ReviewAlert("a2", storage.ReviewDismissed); err != nil {
+		t.Fatalf("ReviewAlert: %v", err)
+	}
+	if inc, _ = store.GetIncident(a); inc.ReportCount != 3 || inc.PeakSeverity != "alta" || !inc.End.Equal(t0.Add(40*time.Minute)) {
+		t.Fatalf("incident after dismissal: %+v", inc)
+	}
+	if err := store.ReviewAlert("a2", storage.ReviewConfirmed); err != nil {
+		t.Fatalf("ReviewAlert: %v", err)
+	}
+	if inc, _ = store.GetIncident(a); inc.ReportCount != 4 || inc.PeakSeverity != "crítica" {
+		t.Fatalf("incident after confirming again: %+v", inc)
+	}
+}
@@ -0,0 +1,450 @@
+package sms
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"alerta_climatica/internal/processing"
+)
+
+// GatewayConfig describe un gateway HTTP de SMS (operadora local o app
+// Android de gateway): qué campos trae el webhook entrante y cómo se le
+// envían los mensajes salientes. Los campos de entrada admiten rutas con
+// puntos para JSON anidado ("message.from", "data.0.body"); en formularios
+// son el nombre del campo.
+type GatewayConfig struct {
+	Name string `json:"nombre"`
+
+	FromField  string `json:"campo_remitente"`
+	TextField  string `json:"campo_texto"`
+	TimeField  string `json:"campo_fecha,omitempty"`
+	TimeFormat string `json:"formato_fecha,omitempty"` // layout de Go, "unix" o "unix_ms"; RFC3339 por defecto
+	ZoneField  string `json:"campo_zona,omitempty"`    // sin él, la zona se toma del texto
+	IDField    string `json:"campo_id,omitempty"`      // id del mensaje, para ignorar reenvíos
+	// Concatenación de SMS largos, si el gateway entrega las partes sueltas.
+	RefField   string `json:"campo_ref,omitempty"`
+	PartField  string `json:"campo_parte,omitempty"`
+	PartsField string `json:"campo_partes,omitempty"`
+	AuthHeader string `json:"cabecera_auth,omitempty"` // p. ej. "X-Api-Key" o "Authorization"
+	AuthToken  string `json:"token,omitempty"`
+
+	SendURL        string            `json:"url_envio,omitempty"`
+	SendFormat     string            `json:"formato_envio,omitempty"` // "json" (por defecto) o "form"
+	SendToField    string            `json:"campo_destino_envio,omitempty"`
+	SendTextField  string            `json:"campo_texto_envio,omitempty"`
+	SendAuthHeader string            `json:"cabecera_auth_envio,omitempty"`
+	SendAuthToken  string            `json:"token_envio,omitempty"`
+	SendExtra      map[string]string `json:"extra_envio,omitempty"`        // campos fijos (usuario, remitente, ...)
+	SendIDField    string            `json:"campo_id_respuesta,omitempty"` // id del mensaje en la respuesta JSON
+}
+
+// LoadGateways lee un arreglo JSON de GatewayConfig.
+func LoadGateways(path string) ([]GatewayConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var cfgs []GatewayConfig
+	if err := json.Unmarshal(data, &cfgs); err != nil {
+		return nil, err
+	}
+	for i, c := range cfgs {
+		if c.Name == "" || c.FromField == "" || c.TextField == "" {
+			return nil, fmt.Errorf("sms: gateway %d: nombre, campo_remitente y campo_texto son obligatorios", i+1)
+		}
+		if (c.AuthHeader == "") != (c.AuthToken == "") {
+			return nil, fmt.Errorf("sms: gateway %q: cabecera_auth y token van juntos", c.Name)
+		}
+		if c.AuthHeader == "" {
+			log.Printf("warning: sms: gateway %s has no cabecera_auth; its senders are treated as unverified", c.Name)
+		}
+	}
+	return cfgs, nil
+}
+
+// gatewaySeenWindow es cuánto se recuerdan los mensajes entregados para
+// ignorar los reenvíos de un lote que falló a medias.
+const gatewaySeenWindow = 10 * time.Minute
+
+// GatewayReceiver implementa Receiver para un gateway configurado.
+type GatewayReceiver struct {
+	cfg     GatewayConfig
+	deliver func(processing.IncomingMessage) error
+	zone    ZoneResolver
+
+	mu   sync.Mutex
+	seen map[string]time.Time // mensajes ya entregados, por id o huella
+}
+
+// NewGatewayReceiver crea el receptor de un gateway.
+func NewGatewayReceiver(cfg GatewayConfig, deliver func(processing.IncomingMessage) error) *GatewayReceiver {
+	return &GatewayReceiver{cfg: cfg, deliver: deliver, seen: make(map[string]time.Time)}
+}
+
+// SetZoneResolver define cómo se obtiene la zona cuando el gateway no la envía.
+func (g *GatewayReceiver) SetZoneResolver(fn ZoneResolver) { g.zone = fn }
+
+// ParseAndAck interpreta el cuerpo de un webhook (JSON, un objeto o un arreglo
+// de mensajes, o formulario) según el mapeo y entrega cada mensaje.
+func (g *GatewayReceiver) ParseAndAck(payload []byte) error {
+	_, err := g.parseAndDeliver(payload)
+	return err
+}
+
+// parseAndDeliver valida el lote completo antes de entregar nada y omite los
+// mensajes ya entregados: si una entrega falla, el gateway reenvía el lote y
+// solo se entregan los que faltaban.
+func (g *GatewayReceiver) parseAndDeliver(payload []byte) (int, error) {
+	records, err := decodeRecords(payload)
+	if err != nil {
+		return 0, err
+	}
+	msgs := make([]processing.IncomingMessage, len(records))
+	keys := make([]string, len(records))
+	for i, rec := range records {
+		if msgs[i], err = g.message(rec); err != nil {
+			return 0, err
+		}
+		keys[i] = g.messageKey(rec, msgs[i])
+	}
+	for i, msg := range msgs {
+		if g.delivered(keys[i], time.Now()) {
+			log.Printf("sms: gateway %s: resent message from %s ignored", g.cfg.Name, msg.From)
+			continue
+		}
+		if err := g.deliver(msg); err != nil {
+			return i, err
+		}
+		g.markDelivered(keys[i], time.Now())
+	}
+	return len(msgs), nil
+}
+
+// messageKey identifica un mensaje entre reenvíos: su id si el gateway lo
+// manda o, con campo_fecha, remitente, hora y texto. "" si no hay cómo.
+func (g *GatewayReceiver) messageKey(rec func(string) (string, bool), msg processing.IncomingMessage) string {
+	if g.cfg.IDField != "" {
+		if id, ok := rec(g.cfg.IDField); ok && id != "" {
+			return "id|" + id
+		}
+	}
+	if g.cfg.TimeField != "" {
+		if at, ok := rec(g.cfg.TimeField); ok && at != "" {
+			return "msg|" + msg.From + "|" + at + "|" + msg.Text + "|" + strconv.Itoa(msg.Part)
+		}
+	}
+	return ""
+}
+
+// delivered dice si key ya se entregó dentro de la ventana.
+func (g *GatewayReceiver) delivered(key string, now time.Time) bool {
+	if key == "" {
+		return false
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for k, at := range g.seen {
+		if now.Sub(at) > gatewaySeenWindow {
+			delete(g.seen, k)
+		}
+	}
+	_, ok := g.seen[key]
+	return ok
+}
+
+func (g *GatewayReceiver) markDelivered(key string, now time.Time) {
+	if key == "" {
+		return
+	}
+	g.mu.Lock()
+	g.seen[key] = now
+	g.mu.Unlock()
+}
+
+// errMapping indica un webhook al que le falta un campo obligatorio del mapeo.
+var errMapping = errors.New("sms: el mensaje no coincide con el mapeo del gateway")
+
+func (g *GatewayReceiver) message(rec func(string) (string, bool)) (processing.IncomingMessage, error) {
+	rawFrom, ok := rec(g.cfg.FromField)
+	if !ok {
+		return processing.IncomingMessage{}, fmt.Errorf("%w: falta %s", errMapping, g.cfg.FromField)
+	}
+	text, ok := rec(g.cfg.TextField)
+	if !ok {
+		return processing.IncomingMessage{}, fmt.Errorf("%w: falta %s", errMapping, g.cfg.TextField)
+	}
+	from, err := NormalizeE164(rawFrom, DefaultCountryCode)
+	if err != nil {
+		return processing.IncomingMessage{}, err
+	}
+	// Sin cabecera de autenticación cualquiera puede llamar al webhook: el
+	// remitente no se considera verificado.
+	msg := processing.IncomingMessage{Text: strings.TrimSpace(text), From: from, Channel: "sms", Verified: g.cfg.AuthHeader != "", ReceivedAt: time.Now()}
+	if g.cfg.TimeField != "" {
+		if v, ok := rec(g.cfg.TimeField); ok {
+			if t, err := parseGatewayTime(v, g.cfg.TimeFormat); err == nil {
+				msg.ReceivedAt = t
+			} else {
+				log.Printf("sms: gateway %s: bad timestamp %q: %v", g.cfg.Name, v, err)
+			}
+		}
+	}
+	if g.cfg.ZoneField != "" {
+		msg.Zone, _ = rec(g.cfg.ZoneField)
+	}
+	if g.cfg.PartsField != "" {
+		msg.Ref = intField(rec, g.cfg.RefField)
+		msg.Part = intField(rec, g.cfg.PartField)
+		msg.Parts = intField(rec, g.cfg.PartsField)
+	}
+	if msg.Zone == "" && g.zone != nil {
+		msg.Zone, msg.Text = g.zone(from, msg.Text)
+	}
+	return msg, nil
+}
+
+// ServeHTTP verifica la cabecera de autenticación y entrega los mensajes.
+func (g *GatewayReceiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if g.cfg.AuthHeader != "" {
+		got := r.Header.Get(g.cfg.AuthHeader)
+		if subtle.ConstantTimeCompare([]byte(got), []byte(g.cfg.AuthToken)) != 1 {
+			http.Error(w, "no autorizado", http.StatusUnauthorized)
+			return
+		}
+	}
+	payload, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxWebhookBody))
+	if err != nil {
+		http.Error(w, "cuerpo inválido", http.StatusBadRequest)
+		return
+	}
+	n, err := g.parseAndDeliver(payload)
+	if err != nil {
+		if errors.Is(err, errMapping) || errors.Is(err, ErrInvalidPhone) || errors.Is(err, errPayload) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		log.Printf("sms: gateway %s message rejected: %v", g.cfg.Name, err)
+		http.Error(w, "servicio no disponible, reintente", http.StatusServiceUnavailable)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{"status": "recibido", "mensajes": n})
+}
+
+var errPayload = errors.New("sms: cuerpo de webhook ilegible")
+
+// decodeRecords devuelve un accesor de campos por cada mensaje del cuerpo.
+func decodeRecords(payload []byte) ([]func(string) (string, bool), error) {
+	trimmed := bytes.TrimSpace(payload)
+	if len(trimmed) > 0 && (trimmed[0] == '{' || trimmed[0] == '[') {
+		dec := json.NewDecoder(bytes.NewReader(trimmed))
+		dec.UseNumber()
+		var v any
+		if err := dec.Decode(&v); err != nil {
+			return nil, fmt.Errorf("%w: %v", errPayload, err)
+		}
+		items, ok := v.([]any)
+		if !ok {
+			items = []any{v}
+		}
+		out := make([]func(string) (string, bool), len(items))
+		for i, it := range items {
+			it := it
+			out[i] = func(path string) (string, bool) { return jsonPath(it, path) }
+		}
+		return out, nil
+	}
+	form, err := url.ParseQuery(string(trimmed))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errPayload, err)
+	}
+	return []func(string) (string, bool){func(k string) (string, bool) {
+		v, ok := form[k]
+		if !ok || len(v) == 0 {
+			return "", false
+		}
+		return v[0], true
+	}}, nil
+}
+
+// jsonPath sigue una ruta con puntos por objetos y arreglos (índices numéricos).
+func jsonPath(v any, path string) (string, bool) {
+	for _, key := range strings.Split(path, ".") {
+		switch node := v.(type) {
+		case map[string]any:
+			v = node[key]
+		case []any:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(node) {
+				return "", false
+			}
+			v = node[i]
+		default:
+			return "", false
+		}
+	}
+	switch x := v.(type) {
+	case string:
+		return x, true
+	case json.Number:
+		return x.String(), true
+	case bool:
+		return strconv.FormatBool(x), true
+	}
+	return "", false
+}
+
+// intField lee un campo numérico; ausente o inválido vale 0.
+func intField(rec func(string) (string, bool), path string) int {
+	if path == "" {
+		return 0
+	}
+	v, _ := rec(path)
+	n, _ := strconv.Atoi(v)
+	return n
+}
+
+func parseGatewayTime(v, layout string) (time.Time, error) {
+	switch layout {
+	case "unix", "unix_ms":
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if layout == "unix_ms" {
+			return time.UnixMilli(n), nil
+		}
+		return time.Unix(n, 0), nil
+	case "":
+		layout = time.RFC3339
+	}
+	return time.Parse(layout, v)
+}
+
+// Gateways agrupa los receptores configurados y los atiende en una sola ruta
+// con el patrón "/api/inbound/{gateway}".
+type Gateways map[string]*GatewayReceiver
+
+// NewGateways crea un receptor por configuración.
+func NewGateways(cfgs []GatewayConfig, deliver func(processing.IncomingMessage) error, zone ZoneResolver) Gateways {
+	out := make(Gateways, len(cfgs))
+	for _, c := range cfgs {
+		g := NewGatewayReceiver(c, deliver)
+		g.SetZoneResolver(zone)
+		out[c.Name] = g
+	}
+	return out
+}
+
+// ServeHTTP despacha al gateway indicado en la ruta.
+func (gs Gateways) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	g, ok := gs[r.PathValue("gateway")]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	g.ServeHTTP(w, r)
+}
+
+// HTTPSender implementa Sender enviando cada SMS con un POST al gateway.
+type HTTPSender struct {
+	cfg    GatewayConfig
+	client *http.Client
+}
+
+// NewHTTPSender crea el Sender de un gateway; client nil usa un cliente con
+// timeout de 10 s.
+func NewHTTPSender(cfg GatewayConfig, client *http.Client) *HTTPSender {
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	return &HTTPSender{cfg: cfg, client: client}
+}
+
+// Send hace POST de destino y texto (más los campos fijos) en JSON o formulario.
+// Cualquier respuesta fuera de 2xx es un error.
+func (s *HTTPSender) Send(to, message string) error {
+	_, err := s.SendWithID(to, message)
+	return err
+}
+
+// SendWithID es Send que además devuelve el id del mensaje leído de
+// campo_id_respuesta ("" si no está configurado o no viene).
+func (s *HTTPSender) SendWithID(to, message string) (string, error) {
+	if s.cfg.SendURL == "" {
+		return "", fmt.Errorf("sms: gateway %s sin url_envio", s.cfg.Name)
+	}
+	fields := make(map[string]string, len(s.cfg.SendExtra)+2)
+	for k, v := range s.cfg.SendExtra {
+		fields[k] = v
+	}
+	fields[orDefault(s.cfg.SendToField, "to")] = to
+	fields[orDefault(s.cfg.SendTextField, "message")] = message
+
+	var body io.Reader
+	contentType := "application/json"
+	if s.cfg.SendFormat == "form" {
+		form := url.Values{}
+		for k, v := range fields {
+			form.Set(k, v)
+		}
+		body = strings.NewReader(form.Encode())
+		contentType = "application/x-www-form-urlencoded"
+	} else {
+		b, err := json.Marshal(fields)
+		if err != nil {
+			return "", err
+		}
+		body = bytes.NewReader(b)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, s.cfg.SendURL, body)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", contentType)
+	if s.cfg.SendAuthHeader != "" {
+		req.Header.Set(s.cfg.SendAuthHeader, s.cfg.SendAuthToken)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("sms: gateway %s: %w", s.cfg.Name, err)
+	}
+	defer resp.Body.Close()
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return "", fmt.Errorf("sms: gateway %s respondió %s", s.cfg.Name, resp.Status)
+	}
+	if s.cfg.SendIDField == "" {
+		return "", nil
+	}
+	records, err := decodeRecords(respBody)
+	if err != nil || len(records) == 0 {
+		return "", nil
+	}
+	id, _ := records[0](s.cfg.SendIDField)
+	return id, nil
+}
+
+func orDefault(v, def string) string {
+	if v == "" {
+		return def
+	}
+	return v
+}
@@ -0,0 +1,158 @@
+package sms
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"alerta_climatica/internal/processing"
+)
+
+func TestGatewaysInboundMapping(t *testing.T) {
+	cfgs := []GatewayConfig{
+		{
+			Name: "android", FromField: "payload.phoneNumber", TextField: "payload.message",
+			TimeField: "payload.receivedAt", TimeFormat: "unix",
+			AuthHeader: "X-Api-Key", AuthToken: "secreto",
+		},
+		{Name: "operadora", FromField: "msisdn", TextField: "texto", ZoneField: "zona"},
+	}
+	var got []processing.IncomingMessage
+	zones := func() []string { return []string{"Zona Norte", "Zona Centro", "Zona Sur"} }
+	gws := NewGateways(cfgs, func(m processing.IncomingMessage) error {
+		got = append(got, m)
+		return nil
+	}, PrefixZoneResolver(zones, "Zona Centro"))
+	mux := http.NewServeMux()
+	mux.Handle("/api/inbound/{gateway}", gws)
+
+	body, err := os.ReadFile("testdata/gateway_android.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	post := func(path, ctype, key, body string) int {
+		r := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		r.Header.Set("Content-Type", ctype)
+		if key != "" {
+			r.Header.Set("X-Api-Key", key)
+		}
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	if code := post("/api/inbound/android", "application/json", "otro", string(body)); code != http.StatusUnauthorized {
+		t.Fatalf("wrong key: got %d", code)
+	}
+	if code := post("/api/inbound/android", "application/json", "secreto", string(body)); code != http.StatusOK {
+		t.Fatalf("android: got %d", code)
+	}
+	form := url.Values{"msisdn": {"987111222"}, "texto": {"lluvia fuerte"}, "zona": {"Zona Sur"}}.Encode()
+	if code := post("/api/inbound/operadora", "application/x-www-form-urlencoded", "", form); code != http.StatusOK {
+		t.Fatalf("operadora: got %d", code)
+	}
+	if code := post("/api/inbound/operadora", "application/x-www-form-urlencoded", "", "texto=sin+remitente"); code != http.StatusBadRequest {
+		t.Fatalf("missing field: got %d", code)
+	}
+	if code := post("/api/inbound/desconocido", "application/json", "", "{}"); code != http.StatusNotFound {
+		t.Fatalf("unknown gateway: got %d", code)
+	}
+
+	if len(got) != 2 {
+		t.Fatalf("expected 2 messages, got %d", len(got))
+	}
+	a := got[0]
+	if a.From != "+51987654321" || a.Zone != "Zona Norte" || a.Text != "huaico bajando por la quebrada" || a.Channel != "sms" {
+		t.Fatalf("unexpected android message %+v", a)
+	}
+	if !a.ReceivedAt.Equal(time.Unix(1760793600, 0)) {
+		t.Fatalf("timestamp not mapped: %v", a.ReceivedAt)
+	}
+	if b := got[1]; b.From != "+51987111222" || b.Zone != "Zona Sur" || b.Text != "lluvia fuerte" {
+		t.Fatalf("unexpected form message %+v", b)
+	}
+}
+
+// TestGatewayResentBatchSkipsDelivered verifica que, si una entrega del lote
+// falla, el reenvío del gateway solo entrega los mensajes que faltaban.
+func TestGatewayResentBatchSkipsDelivered(t *testing.T) {
+	cfg := GatewayConfig{Name: "android", FromField: "from", TextField: "text", IDField: "id", ZoneField: "zona",
+		AuthHeader: "X-Api-Key", AuthToken: "secreto"}
+	var got []string
+	fail := true
+	g := NewGatewayReceiver(cfg, func(m processing.IncomingMessage) error {
+		if m.Text == "segundo" && fail {
+			fail = false
+			return errors.New("cola llena")
+		}
+		got = append(got, m.Text)
+		return nil
+	})
+	batch := `[{"id": "1", "from": "987111222", "text": "primero", "zona": "Zona Sur"},
+		{"id": "2", "from": "987111222", "text": "segundo", "zona": "Zona Sur"},
+		{"id": "3", "from": "987333444", "text": "tercero", "zona": "Zona Sur"}]`
+	post := func() int {
+		r := httptest.NewRequest(http.MethodPost, "/api/inbound/android", strings.NewReader(batch))
+		r.Header.Set("X-Api-Key", "secreto")
+		w := httptest.NewRecorder()
+		g.ServeHTTP(w, r)
+		return w.Code
+	}
+	if code := post(); code != http.StatusServiceUnavailable {
+		t.Fatalf("partial failure: got %d", code)
+	}
+	if code := post(); code != http.StatusOK {
+		t.Fatalf("resent batch: got %d", code)
+	}
+	if strings.Join(got, ",") != "primero,segundo,tercero" {
+		t.Fatalf("expected each message delivered once, got %v", got)
+	}
+}
+
+func TestHTTPSenderPostsMappedFields(t *testing.T) {
+	var gotCT, gotAuth string
+	var gotBody []byte
+	status := http.StatusOK
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotCT, gotAuth = r.Header.Get("Content-Type"), r.Header.Get("Authorization")
+		gotBody, _ = io.ReadAll(r.Body)
+		w.WriteHeader(status)
+	}))
+	defer ts.Close()
+
+	cfg := GatewayConfig{
+		Name: "android", SendURL: ts.URL, SendToField: "phoneNumbers", SendTextField: "text",
+		SendAuthHeader: "Authorization", SendAuthToken: "Bearer abc", SendExtra: map[string]string{"sim": "1"},
+	}
+	if err := NewHTTPSender(cfg, ts.Client()).Send("+51987654321", "Alerta roja en Zona Sur"); err != nil {
+		t.Fatal(err)
+	}
+	var fields map[string]string
+	if err := json.Unmarshal(gotBody, &fields); err != nil {
+		t.Fatalf("body is not JSON: %s", gotBody)
+	}
+	if gotCT != "application/json" || gotAuth != "Bearer abc" ||
+		fields["phoneNumbers"] != "+51987654321" || fields["text"] != "Alerta roja en Zona Sur" || fields["sim"] != "1" {
+		t.Fatalf("unexpected request %q %q %v", gotCT, gotAuth, fields)
+	}
+
+	cfg.SendFormat = "form"
+	if err := NewHTTPSender(cfg, ts.Client()).Send("+51987654321", "hola"); err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := url.ParseQuery(string(gotBody)); gotCT != "application/x-www-form-urlencoded" || v.Get("text") != "hola" {
+		t.Fatalf("unexpected form request %q %s", gotCT, gotBody)
+	}
+
+	status = http.StatusBadGateway
+	if err := NewHTTPSender(cfg, ts.Client()).Send("+51987654321", "hola"); err == nil {
+		t.Fatal("expected error on 502")
+	}
+}
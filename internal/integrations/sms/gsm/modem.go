@@ -0,0 +1,366 @@
+// Package gsm implementa un gateway SMS sobre un módem GSM USB/serie con
+// comandos AT, para comunidades sin internet: recibe reportes (AT+CMGL),
+// envía avisos (AT+CMGS) en modo PDU o texto y reensambla SMS concatenados.
+package gsm
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"alerta_climatica/internal/integrations/sms"
+	"alerta_climatica/internal/processing"
+)
+
+// Mode es el formato de mensajes del módem (valor de AT+CMGF).
+type Mode int
+
+const (
+	PDUMode  Mode = 0 // binario: admite UCS-2 (tildes) y SMS concatenados
+	TextMode Mode = 1 // texto: solo GSM-7 y un SMS por mensaje
+)
+
+// ErrModem envuelve las respuestas ERROR, +CMS ERROR y +CME ERROR del módem.
+var ErrModem = errors.New("gsm: error del módem")
+
+// Parámetros por defecto.
+const (
+	defaultTimeout = 10 * time.Second // espera máxima de una respuesta
+	ctrlZ          = "\x1A"
+)
+
+// deadliner es lo que ofrece un *os.File de puerto serie o pty.
+type deadliner interface {
+	SetReadDeadline(t time.Time) error
+}
+
+// Modem habla AT con un módem GSM. Implementa sms.Sender y sms.Receiver y
+// atiende un comando a la vez, así que puede usarse para enviar mientras Run
+// consulta los mensajes entrantes.
+type Modem struct {
+	mu      sync.Mutex // serializa comandos
+	port    io.ReadWriter
+	r       *bufio.Reader
+	mode    Mode
+	timeout time.Duration
+	ref     byte // referencia de concatenación de envíos
+
+	deliver func(processing.IncomingMessage) error
+	zone    sms.ZoneResolver
+}
+
+// NewModem crea el cliente sobre port (normalmente el resultado de OpenSerial).
+func NewModem(port io.ReadWriter, mode Mode) *Modem {
+	return &Modem{
+		port:    port,
+		r:       bufio.NewReader(port),
+		mode:    mode,
+		timeout: defaultTimeout,
+		deliver: func(processing.IncomingMessage) error { return nil },
+	}
+}
+
+// SetDeliver registra el destino de los mensajes recibidos (p. ej.
+// Processor.Submit). Las partes de un SMS concatenado se entregan una a una
+// con Ref/Part/Parts; las une el reensamblado del Processor (SetReassembly).
+func (m *Modem) SetDeliver(fn func(processing.IncomingMessage) error) { m.deliver = fn }
+
+// SetZoneResolver define cómo se obtiene la zona del texto del SMS.
+func (m *Modem) SetZoneResolver(fn sms.ZoneResolver) { m.zone = fn }
+
+// Init comprueba el módem, desactiva el eco y fija el modo de mensajes.
+func (m *Modem) Init() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	cmds := []string{"AT", "ATE0", fmt.Sprintf("AT+CMGF=%d", m.mode)}
+	if m.mode == TextMode {
+		cmds = append(cmds, `AT+CSCS="GSM"`)
+	}
+	for _, c := range cmds {
+		if _, err := m.command(c); err != nil {
+			return fmt.Errorf("gsm: %s: %w", c, err)
+		}
+	}
+	return nil
+}
+
+// Send envía text a to. En modo PDU los textos largos se parten en SMS
+// concatenados; en modo texto solo se admite un SMS GSM-7.
+func (m *Modem) Send(to, text string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.mode == TextMode {
+		septets, ok := sms.EncodeGSM7(text)
+		if !ok || len(septets) > sms.GSM7Single {
+			return errors.New("gsm: en modo texto solo se envía un SMS GSM-7 (use modo PDU)")
+		}
+		// Con AT+CSCS="GSM" cada septeto viaja como un byte.
+		return m.submit(fmt.Sprintf(`AT+CMGS="%s"`, to), string(septets))
+	}
+	m.ref++
+	pdus, err := EncodeSubmit(to, text, m.ref)
+	if err != nil {
+		return err
+	}
+	for _, p := range pdus {
+		if err := m.submit(fmt.Sprintf("AT+CMGS=%d", p.Length), p.Hex); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ParseAndAck procesa un listado de AT+CMGL (la respuesta cruda del módem) y
+// entrega sus mensajes. No borra nada de la SIM; eso lo hace Poll.
+func (m *Modem) ParseAndAck(payload []byte) error {
+	for _, st := range parseListing(strings.Split(string(payload), "\n"), m.mode) {
+		if err := m.receive(st.msg, time.Now()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Poll lista los mensajes guardados en el módem, los entrega y los borra.
+// Un mensaje que no se pudo entregar queda en la SIM para la próxima pasada.
+func (m *Modem) Poll(now time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	list := "AT+CMGL=4"
+	if m.mode == TextMode {
+		list = `AT+CMGL="ALL"`
+	}
+	lines, err := m.command(list)
+	if err != nil {
+		return err
+	}
+	for _, st := range parseListing(lines, m.mode) {
+		if st.err != nil {
+			log.Printf("gsm: discarding unreadable message %d: %v", st.index, st.err)
+		} else if err := m.receive(st.msg, now); err != nil {
+			return err
+		}
+		if _, err := m.command(fmt.Sprintf("AT+CMGD=%d", st.index)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Run consulta el módem cada every hasta que ctx se cancela.
+func (m *Modem) Run(ctx context.Context, every time.Duration) {
+	t := time.NewTicker(every)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-t.C:
+			if err := m.Poll(now); err != nil {
+				log.Println("warning: gsm poll failed:", err)
+			}
+		}
+	}
+}
+
+// receive entrega un mensaje recibido, o una parte de uno concatenado.
+func (m *Modem) receive(d Deliver, now time.Time) error {
+	from, err := sms.NormalizeE164(d.From, sms.DefaultCountryCode)
+	if err != nil {
+		// Remitentes alfanuméricos (avisos de la operadora) no son reportes.
+		log.Printf("gsm: ignoring message from %q", d.From)
+		return nil
+	}
+	msg := processing.IncomingMessage{Text: d.Text, From: from, Channel: "sms", Verified: true, ReceivedAt: now}
+	if d.Parts > 1 {
+		// El texto de una parte se conserva tal cual: el corte puede caer
+		// junto a un espacio.
+		msg.Ref, msg.Part, msg.Parts = d.Ref, d.Part, d.Parts
+	} else {
+		msg.Text = strings.TrimSpace(msg.Text)
+	}
+	if m.zone != nil {
+		zone, text := m.zone(from, msg.Text)
+		// La zona va al inicio del mensaje: solo se quita de la primera parte.
+		msg.Zone = zone
+		if msg.Part <= 1 {
+			msg.Text = text
+		}
+	}
+	return m.deliver(msg)
+}
+
+// command envía un comando AT y devuelve las líneas de respuesta previas al OK.
+func (m *Modem) command(cmd string) ([]string, error) {
+	if _, err := io.WriteString(m.port, cmd+"\r"); err != nil {
+		return nil, err
+	}
+	return m.readResponse(cmd)
+}
+
+// readResponse lee líneas hasta el resultado final. Se omiten líneas vacías y
+// el eco del comando.
+func (m *Modem) readResponse(cmd string) ([]string, error) {
+	var lines []string
+	for {
+		line, err := m.readLine()
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case line == "" || line == cmd:
+		case line == "OK":
+			return lines, nil
+		case line == "ERROR", strings.HasPrefix(line, "+CMS ERROR"), strings.HasPrefix(line, "+CME ERROR"):
+			return nil, fmt.Errorf("%w: %s", ErrModem, line)
+		default:
+			lines = append(lines, line)
+		}
+	}
+}
+
+func (m *Modem) readLine() (string, error) {
+	m.setDeadline()
+	line, err := m.r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(line), nil
+}
+
+// submit envía AT+CMGS, espera el prompt "> " y manda el cuerpo con Ctrl-Z.
+func (m *Modem) submit(cmd, body string) error {
+	if _, err := io.WriteString(m.port, cmd+"\r"); err != nil {
+		return err
+	}
+	var buf []byte
+	for {
+		m.setDeadline()
+		b, err := m.r.ReadByte()
+		if err != nil {
+			return err
+		}
+		if b == '>' {
+			break
+		}
+		buf = append(buf, b)
+		if b == '\n' && strings.Contains(string(buf), "ERROR") {
+			return fmt.Errorf("%w: %s", ErrModem, strings.TrimSpace(string(buf)))
+		}
+	}
+	if _, err := io.WriteString(m.port, body+ctrlZ); err != nil {
+		return err
+	}
+	_, err := m.readResponse(cmd)
+	return err
+}
+
+func (m *Modem) setDeadline() {
+	if d, ok := m.port.(deadliner); ok {
+		_ = d.SetReadDeadline(time.Now().Add(m.timeout))
+	}
+}
+
+// stored es un mensaje de un listado AT+CMGL con su posición en la SIM.
+type stored struct {
+	index int
+	msg   Deliver
+	err   error
+}
+
+// parseListing interpreta las líneas de AT+CMGL. En modo PDU cada cabecera
+// "+CMGL: idx,stat,,len" va seguida del PDU; en modo texto,
+// "+CMGL: idx,"stat","remitente",,"fecha"" va seguida del texto, que puede
+// ocupar varias líneas.
+func parseListing(lines []string, mode Mode) []stored {
+	var out []stored
+	var cur *stored
+	var body []string
+	flush := func() {
+		if cur == nil {
+			return
+		}
+		if mode == TextMode {
+			cur.msg.Text = sms.DecodeGSM7([]byte(strings.Join(body, "\n")))
+		}
+		out = append(out, *cur)
+		cur, body = nil, nil
+	}
+	for _, raw := range lines {
+		line := strings.TrimRight(raw, "\r")
+		if rest, ok := strings.CutPrefix(line, "+CMGL:"); ok {
+			flush()
+			fields := splitFields(rest)
+			idx, err := strconv.Atoi(fields[0])
+			if err != nil {
+				continue
+			}
+			cur = &stored{index: idx, msg: Deliver{Part: 1, Parts: 1}}
+			if mode == TextMode && len(fields) >= 3 {
+				cur.msg.From = fields[2]
+				if len(fields) >= 5 {
+					cur.msg.SentAt = parseTextTimestamp(fields[4])
+				}
+			}
+			continue
+		}
+		if cur == nil || strings.HasPrefix(line, "+") || line == "OK" {
+			continue // respuestas no solicitadas (+CMTI) u otras líneas
+		}
+		if mode == PDUMode {
+			if strings.TrimSpace(line) == "" {
+				continue
+			}
+			cur.msg, cur.err = DecodeDeliver(line)
+			flush()
+			continue
+		}
+		body = append(body, line)
+	}
+	if mode == TextMode {
+		flush()
+	}
+	return out
+}
+
+// splitFields separa campos por comas respetando comillas y las quita.
+func splitFields(s string) []string {
+	var out []string
+	var b strings.Builder
+	quoted := false
+	for _, r := range strings.TrimSpace(s) {
+		switch {
+		case r == '"':
+			quoted = !quoted
+		case r == ',' && !quoted:
+			out = append(out, b.String())
+			b.Reset()
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return append(out, b.String())
+}
+
+// parseTextTimestamp interpreta "yy/MM/dd,hh:mm:ss±zz" (zona en cuartos de hora).
+func parseTextTimestamp(s string) time.Time {
+	if len(s) < 20 {
+		return time.Time{}
+	}
+	t, err := time.Parse("06/01/02,15:04:05", s[:17])
+	if err != nil {
+		return time.Time{}
+	}
+	q, err := strconv.Atoi(s[17:])
+	if err != nil {
+		return t
+	}
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), 0, time.FixedZone("", q*15*60))
+}
@@ -0,0 +1,263 @@
+//go:build linux
+
+package gsm
+
+import (
+	"bufio"
+	"context"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/unix"
+
+	"alerta_climatica/internal/integrations/sms"
+	"alerta_climatica/internal/processing"
+)
+
+// openPTY abre un pseudo-terminal y devuelve el maestro y la ruta del esclavo.
+func openPTY(t *testing.T) (*os.File, string) {
+	t.Helper()
+	master, err := os.OpenFile("/dev/ptmx", os.O_RDWR|unix.O_NOCTTY|unix.O_NONBLOCK, 0)
+	if err != nil {
+		t.Skipf("pty no disponible: %v", err)
+	}
+	rc, err := master.SyscallConn()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var n int
+	var ierr error
+	rc.Control(func(fd uintptr) {
+		if ierr = unix.IoctlSetPointerInt(int(fd), unix.TIOCSPTLCK, 0); ierr == nil {
+			n, ierr = unix.IoctlGetInt(int(fd), unix.TIOCGPTN)
+		}
+	})
+	if ierr != nil {
+		t.Skipf("pty no disponible: %v", ierr)
+	}
+	return master, fmt.Sprintf("/dev/pts/%d", n)
+}
+
+// fakeModem emula un módem GSM del lado maestro del pty.
+type fakeModem struct {
+	mu      sync.Mutex
+	master  *os.File
+	stored  map[int]string // índice -> PDU o "remitente\ttexto" en modo texto
+	mode    string
+	sent    []string // cuerpos recibidos por AT+CMGS
+	deleted []int
+	cmds    []string
+}
+
+func (f *fakeModem) serve() {
+	r := bufio.NewReader(f.master)
+	for {
+		cmd, err := r.ReadString('\r')
+		if err != nil {
+			return
+		}
+		cmd = strings.TrimSpace(cmd)
+		f.mu.Lock()
+		f.cmds = append(f.cmds, cmd)
+		f.mu.Unlock()
+		switch {
+		case strings.HasPrefix(cmd, "AT+CMGF="):
+			f.mode = strings.TrimPrefix(cmd, "AT+CMGF=")
+			f.reply("OK")
+		case cmd == "AT+CMGL=4" || cmd == `AT+CMGL="ALL"`:
+			f.mu.Lock()
+			var out []string
+			for i := 1; i <= 9; i++ {
+				s, ok := f.stored[i]
+				if !ok {
+					continue
+				}
+				if f.mode == "0" {
+					out = append(out, fmt.Sprintf("+CMGL: %d,0,,%d", i, len(s)/2-1), s)
+				} else {
+					from, text, _ := strings.Cut(s, "\t")
+					out = append(out, fmt.Sprintf(`+CMGL: %d,"REC UNREAD","%s",,"26/03/01,10:00:00-20"`, i, from), text)
+				}
+			}
+			f.mu.Unlock()
+			f.reply(append(out, "OK")...)
+		case strings.HasPrefix(cmd, "AT+CMGD="):
+			var i int
+			fmt.Sscanf(cmd, "AT+CMGD=%d", &i)
+			f.mu.Lock()
+			delete(f.stored, i)
+			f.deleted = append(f.deleted, i)
+			f.mu.Unlock()
+			f.reply("OK")
+		case strings.HasPrefix(cmd, "AT+CMGS="):
+			f.master.WriteString("\r\n> ")
+			body, err := r.ReadString(0x1A)
+			if err != nil {
+				return
+			}
+			f.mu.Lock()
+			f.sent = append(f.sent, strings.TrimSuffix(body, "\x1A"))
+			n := len(f.sent)
+			f.mu.Unlock()
+			f.reply(fmt.Sprintf("+CMGS: %d", n), "OK")
+		case strings.HasPrefix(cmd, "AT"):
+			f.reply("OK")
+		default:
+			f.reply("ERROR")
+		}
+	}
+}
+
+func (f *fakeModem) reply(lines ...string) {
+	var b strings.Builder
+	for _, l := range lines {
+		b.WriteString("\r\n" + l + "\r\n")
+	}
+	f.master.WriteString(b.String())
+}
+
+// deliverPDU arma un SMS-DELIVER GSM-7 (text no debe llevar tildes fuera del
+// alfabeto), con cabecera de concatenación si parts > 1.
+func deliverPDU(from, text string, ref, part, parts int) string {
+	da, _ := encodeAddress(from)
+	septets, _ := sms.EncodeGSM7(text)
+	fo := byte(0x04)
+	var udh []byte
+	if parts > 1 {
+		fo |= 0x40
+		udh = []byte{0x05, 0x00, 0x03, byte(ref), byte(parts), byte(part)}
+	}
+	pdu := []byte{0x00, fo}
+	pdu = append(pdu, da...)
+	pdu = append(pdu, 0x00, 0x00, 0x62, 0x30, 0x10, 0x01, 0x00, 0x00, 0x0A) // PID, DCS, 2026-03-01 10:00 -05
+	headerSeptets := (len(udh)*8 + 6) / 7
+	pdu = append(pdu, byte(headerSeptets+len(septets)))
+	pdu = append(pdu, udh...)
+	pdu = append(pdu, sms.PackSeptets(septets, headerSeptets*7-len(udh)*8)...)
+	return strings.ToUpper(hex.EncodeToString(pdu))
+}
+
+func startModem(t *testing.T, mode Mode, stored map[int]string) (*Modem, *fakeModem, *[]processing.IncomingMessage) {
+	t.Helper()
+	master, slave := openPTY(t)
+	fake := &fakeModem{master: master, stored: stored}
+	go fake.serve()
+	port, err := OpenSerial(slave, 115200)
+	if err != nil {
+		master.Close()
+		t.Fatalf("OpenSerial: %v", err)
+	}
+	t.Cleanup(func() {
+		port.Close()
+		master.Close()
+	})
+
+	var got []processing.IncomingMessage
+	m := NewModem(port, mode)
+	m.timeout = 2 * time.Second
+	m.SetDeliver(func(msg processing.IncomingMessage) error {
+		got = append(got, msg)
+		return nil
+	})
+	zones := func() []string { return []string{"Zona Norte", "Zona Centro", "Zona Sur"} }
+	m.SetZoneResolver(sms.PrefixZoneResolver(zones, "Zona Centro"))
+	if err := m.Init(); err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+	return m, fake, &got
+}
+
+func TestModemPDUReceiveAndSend(t *testing.T) {
+	stored := map[int]string{
+		// Partes de un SMS concatenado guardadas en desorden.
+		2: deliverPDU("+51987654321", "del rio junto al puente", 9, 2, 2),
+		1: deliverPDU("+51987654321", "Zona Sur: se desborda ", 9, 1, 2),
+		3: deliverPDU("+51911111111", "Zona Norte: lluvia intensa", 0, 1, 1),
+	}
+	m, fake, _ := startModem(t, PDUMode, stored)
+	// Las partes las une el reensamblado del Processor.
+	var mu sync.Mutex
+	var alerts []processing.Alert
+	p := processing.NewProcessor(nil, func(a processing.Alert) {
+		mu.Lock()
+		alerts = append(alerts, a)
+		mu.Unlock()
+	})
+	p.SetReassembly(time.Minute)
+	if err := p.Start(context.Background()); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	m.SetDeliver(p.Submit)
+
+	if err := m.Poll(time.Now()); err != nil {
+		t.Fatalf("Poll: %v", err)
+	}
+	p.Close()
+	if len(alerts) != 2 {
+		t.Fatalf("expected 2 alerts, got %+v", alerts)
+	}
+	byFrom := map[string]processing.Alert{}
+	for _, a := range alerts {
+		byFrom[a.Reporter] = a
+	}
+	if a := byFrom["+51987654321"]; a.Zone != "Zona Sur" || a.Message != "se desborda del rio junto al puente" || a.Channel != "sms" {
+		t.Fatalf("unexpected reassembled alert: %+v", a)
+	}
+	if a := byFrom["+51911111111"]; a.Zone != "Zona Norte" || a.Message != "lluvia intensa" {
+		t.Fatalf("unexpected single alert: %+v", a)
+	}
+	fake.mu.Lock()
+	if len(fake.stored) != 0 || len(fake.deleted) != 3 {
+		t.Fatalf("messages not deleted from SIM: stored %v, deleted %v", fake.stored, fake.deleted)
+	}
+	fake.mu.Unlock()
+
+	long := strings.Repeat("Alerta roja en Zona Sur, evacúe. ", 4)
+	if err := m.Send("+51987654321", long); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	if len(fake.sent) != 2 {
+		t.Fatalf("expected 2 concatenated submits, got %d", len(fake.sent))
+	}
+	var lengths []string
+	for _, c := range fake.cmds {
+		if strings.HasPrefix(c, "AT+CMGS=") {
+			lengths = append(lengths, strings.TrimPrefix(c, "AT+CMGS="))
+		}
+	}
+	for i, body := range fake.sent {
+		if want := fmt.Sprint(len(body)/2 - 1); lengths[i] != want {
+			t.Fatalf("part %d: AT+CMGS=%s, want %s", i+1, lengths[i], want)
+		}
+	}
+}
+
+func TestModemTextMode(t *testing.T) {
+	stored := map[int]string{1: "987654321\tZona Centro: viento fuerte"}
+	m, fake, got := startModem(t, TextMode, stored)
+
+	if err := m.Poll(time.Now()); err != nil {
+		t.Fatalf("Poll: %v", err)
+	}
+	if len(*got) != 1 || (*got)[0].From != "+51987654321" || (*got)[0].Zone != "Zona Centro" || (*got)[0].Text != "viento fuerte" {
+		t.Fatalf("unexpected messages: %+v", *got)
+	}
+	if err := m.Send("+51987654321", "Suscrito a Zona Centro"); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if err := m.Send("+51987654321", "Evacúe"); err == nil {
+		t.Fatal("text mode should reject non GSM-7 text")
+	}
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	if len(fake.sent) != 1 || fake.sent[0] != "Suscrito a Zona Centro" {
+		t.Fatalf("unexpected submits: %q", fake.sent)
+	}
+}
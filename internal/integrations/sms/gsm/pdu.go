@@ -0,0 +1,318 @@
+package gsm
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf16"
+
+	"alerta_climatica/internal/integrations/sms"
+)
+
+// ErrBadPDU indica un PDU truncado o con un formato no soportado.
+var ErrBadPDU = errors.New("gsm: PDU inválido")
+
+// Deliver es un SMS-DELIVER recibido. Ref, Part y Parts describen la
+// concatenación (Parts es 1 para un SMS simple).
+type Deliver struct {
+	From   string
+	Text   string
+	SentAt time.Time
+	Ref    int
+	Part   int
+	Parts  int
+}
+
+// pduReader recorre un PDU con control de longitud.
+type pduReader struct {
+	b   []byte
+	pos int
+	err error
+}
+
+func (r *pduReader) next(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n < 0 || r.pos+n > len(r.b) {
+		r.err = ErrBadPDU
+		return nil
+	}
+	out := r.b[r.pos : r.pos+n]
+	r.pos += n
+	return out
+}
+
+func (r *pduReader) byte() byte {
+	if b := r.next(1); b != nil {
+		return b[0]
+	}
+	return 0
+}
+
+// DecodeDeliver decodifica un SMS-DELIVER en hexadecimal tal como lo lista
+// AT+CMGL en modo PDU (con la dirección del SMSC al inicio).
+func DecodeDeliver(hexPDU string) (Deliver, error) {
+	raw, err := hex.DecodeString(strings.TrimSpace(hexPDU))
+	if err != nil {
+		return Deliver{}, fmt.Errorf("%w: %v", ErrBadPDU, err)
+	}
+	r := &pduReader{b: raw}
+	r.next(int(r.byte())) // SMSC
+	fo := r.byte()
+	if r.err == nil && fo&0x03 != 0 {
+		return Deliver{}, fmt.Errorf("%w: no es SMS-DELIVER (MTI %d)", ErrBadPDU, fo&0x03)
+	}
+	d := Deliver{Part: 1, Parts: 1}
+	oaDigits := int(r.byte())
+	toa := r.byte()
+	oa := r.next((oaDigits + 1) / 2)
+	d.From = decodeAddress(oa, oaDigits, toa)
+	r.byte() // PID
+	dcs := r.byte()
+	d.SentAt = decodeTimestamp(r.next(7))
+	udl := int(r.byte())
+	ud := r.b[min(r.pos, len(r.b)):]
+	if r.err != nil {
+		return Deliver{}, r.err
+	}
+
+	headerLen := 0 // octetos de UDH incluido el octeto de longitud
+	if fo&0x40 != 0 {
+		if len(ud) == 0 || int(ud[0])+1 > len(ud) {
+			return Deliver{}, ErrBadPDU
+		}
+		headerLen = int(ud[0]) + 1
+		parseConcat(ud[1:headerLen], &d)
+	}
+
+	switch dcsAlphabet(dcs) {
+	case alphabetGSM7:
+		headerSeptets := (headerLen*8 + 6) / 7
+		septets := sms.UnpackSeptets(ud, udl, 0)
+		if len(septets) < headerSeptets {
+			return Deliver{}, ErrBadPDU
+		}
+		d.Text = sms.DecodeGSM7(septets[headerSeptets:])
+	case alphabetUCS2:
+		if udl > len(ud) || headerLen > udl {
+			return Deliver{}, ErrBadPDU
+		}
+		d.Text = decodeUCS2(ud[headerLen:udl])
+	default:
+		if udl > len(ud) || headerLen > udl {
+			return Deliver{}, ErrBadPDU
+		}
+		d.Text = string(ud[headerLen:udl])
+	}
+	return d, nil
+}
+
+// parseConcat lee el elemento de concatenación (IEI 0x00 con referencia de 8
+// bits o 0x08 con referencia de 16 bits) de la cabecera UDH.
+func parseConcat(h []byte, d *Deliver) {
+	for i := 0; i+1 < len(h); {
+		iei, l := h[i], int(h[i+1])
+		v := h[i+2 : min(i+2+l, len(h))]
+		switch {
+		case iei == 0x00 && len(v) == 3 && v[1] > 0:
+			d.Ref, d.Parts, d.Part = int(v[0]), int(v[1]), int(v[2])
+		case iei == 0x08 && len(v) == 4 && v[2] > 0:
+			d.Ref, d.Parts, d.Part = int(v[0])<<8|int(v[1]), int(v[2]), int(v[3])
+		}
+		i += 2 + l
+	}
+}
+
+const (
+	alphabetGSM7 = iota
+	alphabet8Bit
+	alphabetUCS2
+)
+
+func dcsAlphabet(dcs byte) int {
+	switch {
+	case dcs&0xC0 == 0x00, dcs&0xC0 == 0x40: // grupo de codificación general
+		switch (dcs >> 2) & 0x03 {
+		case 1:
+			return alphabet8Bit
+		case 2:
+			return alphabetUCS2
+		}
+	case dcs&0xF0 == 0xF0:
+		if dcs&0x04 != 0 {
+			return alphabet8Bit
+		}
+	case dcs&0xF0 == 0xE0:
+		return alphabetUCS2
+	}
+	return alphabetGSM7
+}
+
+// decodeAddress interpreta una dirección en semi-octetos invertidos o, para
+// remitentes alfanuméricos (operadoras), en GSM-7 empaquetado.
+func decodeAddress(b []byte, digits int, toa byte) string {
+	if toa&0x70 == 0x50 {
+		return sms.DecodeGSM7(sms.UnpackSeptets(b, digits*4/7, 0))
+	}
+	s := swapSemiOctets(b)
+	if len(s) > digits {
+		s = s[:digits]
+	}
+	if toa&0x70 == 0x10 {
+		return "+" + s
+	}
+	return s
+}
+
+func swapSemiOctets(b []byte) string {
+	var sb strings.Builder
+	for _, o := range b {
+		for _, n := range []byte{o & 0x0F, o >> 4} {
+			if n <= 9 {
+				sb.WriteByte('0' + n)
+			}
+		}
+	}
+	return sb.String()
+}
+
+// decodeTimestamp lee el sello de hora del centro de servicio (semi-octetos
+// invertidos; la zona horaria va en cuartos de hora).
+func decodeTimestamp(b []byte) time.Time {
+	if len(b) != 7 {
+		return time.Time{}
+	}
+	f := func(o byte) int { return int(o&0x0F)*10 + int(o>>4) }
+	tz := b[6]
+	quarters := int(tz&0x07)*10 + int(tz>>4)
+	if tz&0x08 != 0 {
+		quarters = -quarters
+	}
+	loc := time.FixedZone("", quarters*15*60)
+	return time.Date(2000+f(b[0]), time.Month(f(b[1])), f(b[2]), f(b[3]), f(b[4]), f(b[5]), 0, loc)
+}
+
+func decodeUCS2(b []byte) string {
+	units := make([]uint16, len(b)/2)
+	for i := range units {
+		units[i] = uint16(b[2*i])<<8 | uint16(b[2*i+1])
+	}
+	return string(utf16.Decode(units))
+}
+
+// SubmitPDU es un SMS-SUBMIT listo para AT+CMGS: Hex incluye un SMSC vacío
+// ("00") y Length es la longitud del TPDU en octetos sin el SMSC.
+type SubmitPDU struct {
+	Hex    string
+	Length int
+}
+
+// EncodeSubmit codifica text para to como uno o más SMS-SUBMIT. Usa GSM-7 si
+// el texto cabe en ese alfabeto y UCS-2 si no; los textos largos se parten
+// con cabecera de concatenación y referencia ref.
+func EncodeSubmit(to, text string, ref byte) ([]SubmitPDU, error) {
+	da, err := encodeAddress(to)
+	if err != nil {
+		return nil, err
+	}
+	var chunks [][]byte // septetos GSM-7 u octetos UCS-2 por parte
+	dcs := byte(0x00)
+	if septets, ok := sms.EncodeGSM7(text); ok {
+		chunks = splitSeptets(septets)
+	} else {
+		dcs = 0x08
+		chunks = splitUCS2(utf16.Encode([]rune(text)))
+	}
+	if len(chunks) > 255 {
+		return nil, errors.New("gsm: mensaje demasiado largo")
+	}
+
+	out := make([]SubmitPDU, 0, len(chunks))
+	for i, chunk := range chunks {
+		fo := byte(0x01) // SMS-SUBMIT, sin período de validez
+		var udh []byte
+		if len(chunks) > 1 {
+			fo |= 0x40
+			udh = []byte{0x05, 0x00, 0x03, ref, byte(len(chunks)), byte(i + 1)}
+		}
+		tpdu := append([]byte{fo, 0x00}, da...)
+		tpdu = append(tpdu, 0x00, dcs)
+		if dcs == 0x00 {
+			headerSeptets := (len(udh)*8 + 6) / 7
+			fill := headerSeptets*7 - len(udh)*8
+			tpdu = append(tpdu, byte(headerSeptets+len(chunk)))
+			tpdu = append(tpdu, udh...)
+			tpdu = append(tpdu, sms.PackSeptets(chunk, fill)...)
+		} else {
+			tpdu = append(tpdu, byte(len(udh)+len(chunk)))
+			tpdu = append(tpdu, udh...)
+			tpdu = append(tpdu, chunk...)
+		}
+		out = append(out, SubmitPDU{Hex: "00" + strings.ToUpper(hex.EncodeToString(tpdu)), Length: len(tpdu)})
+	}
+	return out, nil
+}
+
+// encodeAddress codifica el destino (E.164 con "+" o número nacional).
+func encodeAddress(to string) ([]byte, error) {
+	toa := byte(0x81)
+	digits := strings.TrimPrefix(to, "+")
+	if digits != to {
+		toa = 0x91
+	}
+	if digits == "" || strings.Trim(digits, "0123456789") != "" {
+		return nil, fmt.Errorf("gsm: destino inválido %q", to)
+	}
+	out := []byte{byte(len(digits)), toa}
+	for i := 0; i < len(digits); i += 2 {
+		lo := digits[i] - '0'
+		hi := byte(0x0F)
+		if i+1 < len(digits) {
+			hi = digits[i+1] - '0'
+		}
+		out = append(out, hi<<4|lo)
+	}
+	return out, nil
+}
+
+// splitSeptets parte un texto GSM-7 sin separar un escape de su carácter.
+func splitSeptets(s []byte) [][]byte {
+	if len(s) <= sms.GSM7Single {
+		return [][]byte{s}
+	}
+	var out [][]byte
+	for len(s) > 0 {
+		n := min(sms.GSM7Part, len(s))
+		if n < len(s) && s[n-1] == 0x1B {
+			n--
+		}
+		out = append(out, s[:n])
+		s = s[n:]
+	}
+	return out
+}
+
+// splitUCS2 parte en octetos UTF-16BE sin separar pares sustitutos.
+func splitUCS2(units []uint16) [][]byte {
+	limit := sms.UCS2Single
+	if len(units) > sms.UCS2Single {
+		limit = sms.UCS2Part
+	}
+	var out [][]byte
+	for len(units) > 0 {
+		n := min(limit, len(units))
+		if n < len(units) && utf16.IsSurrogate(rune(units[n-1])) && units[n-1] < 0xDC00 {
+			n--
+		}
+		b := make([]byte, 0, 2*n)
+		for _, u := range units[:n] {
+			b = append(b, byte(u>>8), byte(u))
+		}
+		out = append(out, b)
+		units = units[n:]
+	}
+	return out
+}
@@ -0,0 +1,54 @@
+package gsm
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDecodeDeliverReference(t *testing.T) {
+	// Ejemplo de referencia: "How are you?" de +31641600986.
+	d, err := DecodeDeliver("07911326040000F0040B911346610089F60000208062917314080CC8F71D14969741F977FD07")
+	if err != nil {
+		t.Fatalf("DecodeDeliver: %v", err)
+	}
+	if d.From != "+31641600986" || d.Text != "How are you?" || d.SentAt.Format(time.DateTime) != "2002-08-26 19:37:41" || d.Parts != 1 {
+		t.Fatalf("unexpected deliver: %+v", d)
+	}
+	if _, err := DecodeDeliver("07911326040000F004"); err == nil {
+		t.Fatal("expected error for truncated PDU")
+	}
+}
+
+func TestEncodeSubmit(t *testing.T) {
+	pdus, err := EncodeSubmit("+51987654321", "hellohello", 1)
+	if err != nil {
+		t.Fatalf("EncodeSubmit: %v", err)
+	}
+	if len(pdus) != 1 || pdus[0].Hex != "0001000B911589674523F100000AE8329BFD4697D9EC37" || pdus[0].Length != 22 {
+		t.Fatalf("unexpected PDU: %+v", pdus)
+	}
+
+	long := strings.Repeat("Evacúe hacia la loma. ", 5) // UCS-2 por la ú, 110 caracteres
+	pdus, err = EncodeSubmit("987654321", long, 7)
+	if err != nil {
+		t.Fatalf("EncodeSubmit: %v", err)
+	}
+	if len(pdus) != 2 {
+		t.Fatalf("expected 2 concatenated parts, got %d", len(pdus))
+	}
+	for _, p := range pdus {
+		if len(p.Hex) != 2+2*p.Length {
+			t.Fatalf("length %d does not match PDU %s", p.Length, p.Hex)
+		}
+	}
+}
+
+func TestDecodeTimestampZone(t *testing.T) {
+	// 2026-03-01 10:00:00 -05:00: -20 cuartos de hora -> semi-octetos "2","0" con bit de signo.
+	ts := decodeTimestamp([]byte{0x62, 0x30, 0x10, 0x01, 0x00, 0x00, 0x0A})
+	want := time.Date(2026, 3, 1, 10, 0, 0, 0, time.FixedZone("", -5*3600))
+	if !ts.Equal(want) {
+		t.Fatalf("got %v, want %v", ts, want)
+	}
+}
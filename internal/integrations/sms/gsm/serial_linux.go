@@ -0,0 +1,67 @@
+//go:build linux
+
+package gsm
+
+import (
+	"fmt"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+var baudRates = map[int]uint32{
+	9600:   unix.B9600,
+	19200:  unix.B19200,
+	38400:  unix.B38400,
+	57600:  unix.B57600,
+	115200: unix.B115200,
+	230400: unix.B230400,
+	460800: unix.B460800,
+}
+
+// OpenSerial abre un puerto serie (p. ej. /dev/ttyUSB0) en modo crudo 8N1 a
+// la velocidad indicada. El archivo admite plazos de lectura
+// (SetReadDeadline), que el Modem usa para no bloquearse ante un módem mudo.
+func OpenSerial(path string, baud int) (*os.File, error) {
+	speed, ok := baudRates[baud]
+	if !ok {
+		return nil, fmt.Errorf("gsm: velocidad no soportada %d", baud)
+	}
+	f, err := os.OpenFile(path, os.O_RDWR|unix.O_NOCTTY|unix.O_NONBLOCK, 0)
+	if err != nil {
+		return nil, err
+	}
+	// SyscallConn en vez de Fd(): Fd() dejaría el descriptor en modo
+	// bloqueante y sin soporte de plazos.
+	rc, err := f.SyscallConn()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	var terr error
+	err = rc.Control(func(fd uintptr) {
+		t, err := unix.IoctlGetTermios(int(fd), unix.TCGETS)
+		if err != nil {
+			terr = err
+			return
+		}
+		// Equivalente a cfmakeraw: sin eco, sin modo canónico ni traducción de fin de línea.
+		t.Iflag &^= unix.IGNBRK | unix.BRKINT | unix.PARMRK | unix.ISTRIP | unix.INLCR | unix.IGNCR | unix.ICRNL | unix.IXON
+		t.Oflag &^= unix.OPOST
+		t.Lflag &^= unix.ECHO | unix.ECHONL | unix.ICANON | unix.ISIG | unix.IEXTEN
+		t.Cflag &^= unix.CSIZE | unix.PARENB | unix.CBAUD
+		t.Cflag |= unix.CS8 | unix.CREAD | unix.CLOCAL | speed
+		t.Ispeed, t.Ospeed = speed, speed
+		t.Cc[unix.VMIN] = 1
+		t.Cc[unix.VTIME] = 0
+		terr = unix.IoctlSetTermios(int(fd), unix.TCSETS, t)
+	})
+	if err == nil {
+		err = terr
+	}
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("gsm: configurando %s: %w", path, err)
+	}
+	return f, nil
+}
@@ -0,0 +1,13 @@
+//go:build !linux
+
+package gsm
+
+import (
+	"errors"
+	"os"
+)
+
+// OpenSerial solo está implementado en Linux (el kit de campo usa Raspberry Pi).
+func OpenSerial(path string, baud int) (*os.File, error) {
+	return nil, errors.New("gsm: puerto serie no soportado en este sistema")
+}
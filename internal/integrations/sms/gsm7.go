@@ -0,0 +1,109 @@
+package sms
+
+import "strings"
+
+// Alfabeto GSM 03.38 por defecto (7 bits). El índice es el septeto; 0x1B es
+// el escape a la tabla de extensión.
+var gsm7Basic = [128]rune{
+	'@', '£', '$', '¥', 'è', 'é', 'ù', 'ì', 'ò', 'Ç', '\n', 'Ø', 'ø', '\r', 'Å', 'å',
+	'Δ', '_', 'Φ', 'Γ', 'Λ', 'Ω', 'Π', 'Ψ', 'Σ', 'Θ', 'Ξ', 0x1B, 'Æ', 'æ', 'ß', 'É',
+	' ', '!', '"', '#', '¤', '%', '&', '\'', '(', ')', '*', '+', ',', '-', '.', '/',
+	'0', '1', '2', '3', '4', '5', '6', '7', '8', '9', ':', ';', '<', '=', '>', '?',
+	'¡', 'A', 'B', 'C', 'D', 'E', 'F', 'G', 'H', 'I', 'J', 'K', 'L', 'M', 'N', 'O',
+	'P', 'Q', 'R', 'S', 'T', 'U', 'V', 'W', 'X', 'Y', 'Z', 'Ä', 'Ö', 'Ñ', 'Ü', '§',
+	'¿', 'a', 'b', 'c', 'd', 'e', 'f', 'g', 'h', 'i', 'j', 'k', 'l', 'm', 'n', 'o',
+	'p', 'q', 'r', 's', 't', 'u', 'v', 'w', 'x', 'y', 'z', 'ä', 'ö', 'ñ', 'ü', 'à',
+}
+
+// gsm7Ext es la tabla de extensión: cada carácter ocupa dos septetos (ESC + código).
+var gsm7Ext = map[byte]rune{
+	0x0A: '\f', 0x14: '^', 0x28: '{', 0x29: '}', 0x2F: '\\',
+	0x3C: '[', 0x3D: '~', 0x3E: ']', 0x40: '|', 0x65: '€',
+}
+
+const gsm7Escape = 0x1B
+
+var gsm7BasicIndex, gsm7ExtIndex = gsm7Indexes()
+
+func gsm7Indexes() (map[rune]byte, map[rune]byte) {
+	basic := make(map[rune]byte, len(gsm7Basic))
+	for i, r := range gsm7Basic {
+		if i != gsm7Escape {
+			basic[r] = byte(i)
+		}
+	}
+	ext := make(map[rune]byte, len(gsm7Ext))
+	for c, r := range gsm7Ext {
+		ext[r] = c
+	}
+	return basic, ext
+}
+
+// EncodeGSM7 convierte s a septetos del alfabeto GSM por defecto. ok es false
+// si algún carácter no existe en el alfabeto (el mensaje requiere UCS-2). Las
+// vocales con tilde aguda salvo la é (á, í, ó, ú) no están en GSM-7.
+func EncodeGSM7(s string) (septets []byte, ok bool) {
+	septets = make([]byte, 0, len(s))
+	for _, r := range s {
+		if c, found := gsm7BasicIndex[r]; found {
+			septets = append(septets, c)
+		} else if c, found := gsm7ExtIndex[r]; found {
+			septets = append(septets, gsm7Escape, c)
+		} else {
+			return nil, false
+		}
+	}
+	return septets, true
+}
+
+// DecodeGSM7 convierte septetos GSM-7 a texto.
+func DecodeGSM7(septets []byte) string {
+	var b strings.Builder
+	for i := 0; i < len(septets); i++ {
+		c := septets[i] & 0x7F
+		if c == gsm7Escape && i+1 < len(septets) {
+			i++
+			if r, ok := gsm7Ext[septets[i]&0x7F]; ok {
+				b.WriteRune(r)
+			} else {
+				b.WriteRune(' ') // escape desconocido: la norma pide un espacio
+			}
+			continue
+		}
+		b.WriteRune(gsm7Basic[c])
+	}
+	return b.String()
+}
+
+// PackSeptets empaqueta septetos en octetos (7 bits cada uno, LSB primero),
+// dejando fill bits de relleno al inicio (para alinear tras una cabecera UDH).
+func PackSeptets(septets []byte, fill int) []byte {
+	out := make([]byte, (fill+7*len(septets)+7)/8)
+	for i, s := range septets {
+		bit := fill + 7*i
+		idx, shift := bit/8, uint(bit%8)
+		out[idx] |= s << shift
+		if shift > 1 {
+			out[idx+1] |= s >> (8 - shift)
+		}
+	}
+	return out
+}
+
+// UnpackSeptets extrae n septetos de data, saltando fill bits iniciales.
+func UnpackSeptets(data []byte, n, fill int) []byte {
+	out := make([]byte, 0, n)
+	for i := 0; i < n; i++ {
+		bit := fill + 7*i
+		idx, shift := bit/8, uint(bit%8)
+		if idx >= len(data) {
+			break
+		}
+		v := data[idx] >> shift
+		if shift > 1 && idx+1 < len(data) {
+			v |= data[idx+1] << (8 - shift)
+		}
+		out = append(out, v&0x7F)
+	}
+	return out
+}
@@ -0,0 +1,76 @@
+package sms
+
+import (
+	"bytes"
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+func TestGSM7PackRoundTrip(t *testing.T) {
+	// Ejemplo clásico de la especificación: "hellohello" -> E8329BFD4697D9EC37.
+	septets, ok := EncodeGSM7("hellohello")
+	if !ok {
+		t.Fatal("hellohello should be GSM-7")
+	}
+	if got := strings.ToUpper(hex.EncodeToString(PackSeptets(septets, 0))); got != "E8329BFD4697D9EC37" {
+		t.Fatalf("packed = %s", got)
+	}
+
+	for _, fill := range []int{0, 1, 6} {
+		text := "Alerta {roja} en Zona Sur: ¡evacúe!"
+		septets, ok := EncodeGSM7(strings.ReplaceAll(text, "ú", "u"))
+		if !ok {
+			t.Fatalf("text should be GSM-7")
+		}
+		packed := PackSeptets(septets, fill)
+		if back := UnpackSeptets(packed, len(septets), fill); !bytes.Equal(back, septets) {
+			t.Fatalf("fill %d: roundtrip mismatch", fill)
+		}
+		if got := DecodeGSM7(septets); got != strings.ReplaceAll(text, "ú", "u") {
+			t.Fatalf("decode = %q", got)
+		}
+	}
+
+	if _, ok := EncodeGSM7("evacúe"); ok {
+		t.Fatal("ú is not in the GSM-7 alphabet")
+	}
+	if s, _ := EncodeGSM7("€[]"); len(s) != 6 {
+		t.Fatalf("extension chars should take two septets each, got %d", len(s))
+	}
+}
+
+func TestSegments(t *testing.T) {
+	cases := []struct {
+		text     string
+		enc      string
+		segments int
+		nonGSM   string
+	}{
+		{strings.Repeat("a", 160), EncodingGSM7, 1, ""},
+		{strings.Repeat("a", 161), EncodingGSM7, 2, ""},
+		{strings.Repeat("€", 81), EncodingGSM7, 2, ""}, // extensión: 2 septetos cada uno
+		{"Manténgase atento, señora", EncodingGSM7, 1, ""},
+		{"Evacúe hacia zonas altas", EncodingUCS2, 1, "ú"},
+		{strings.Repeat("ó", 71), EncodingUCS2, 2, "ó"},
+	}
+	for _, c := range cases {
+		got := Segments(c.text)
+		if got.Encoding != c.enc || got.Segments != c.segments || strings.Join(got.NonGSM, "") != c.nonGSM {
+			t.Errorf("Segments(%.20q) = %+v", c.text, got)
+		}
+	}
+	if got := Segments(strings.Repeat("a", 200)); got.PerSegment != GSM7Part || got.Remaining != 2*GSM7Part-200 {
+		t.Errorf("unexpected multipart info %+v", got)
+	}
+}
+
+func TestStripAccents(t *testing.T) {
+	got := StripAccents("Evacúe “ya” al coliseo – está señalizado…")
+	if want := `Evacue "ya" al coliseo - esta señalizado...`; got != want {
+		t.Fatalf("StripAccents = %q, want %q", got, want)
+	}
+	if _, ok := EncodeGSM7(StripAccents("Alerta 🌧 en Río Seco")); !ok {
+		t.Fatal("stripped text is not GSM-7")
+	}
+}
@@ -0,0 +1,43 @@
+package sms
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LogSender es un Sender falso para desarrollo local: en vez de enviar SMS
+// reales escribe cada mensaje en un archivo (una línea por envío) o, si no
+// se indica archivo, en el log del proceso.
+type LogSender struct {
+	mu   sync.Mutex
+	path string
+}
+
+// NewLogSender crea un LogSender que agrega los envíos a path ("" = log estándar).
+func NewLogSender(path string) *LogSender {
+	return &LogSender{path: path}
+}
+
+// Send registra el mensaje como "timestamp<TAB>destino<TAB>texto".
+func (s *LogSender) Send(to string, message string) error {
+	line := fmt.Sprintf("%s\t%s\t%s", time.Now().UTC().Format(time.RFC3339), to, strings.ReplaceAll(message, "\n", " "))
+	if s.path == "" {
+		log.Println("sms (fake):", line)
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintln(f, line); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,56 @@
+package sms
+
+import (
+	"errors"
+	"strings"
+)
+
+// DefaultCountryCode es el código de país usado para números sin prefijo internacional (Perú).
+const DefaultCountryCode = "51"
+
+// ErrInvalidPhone indica un número que no se puede llevar a formato E.164.
+var ErrInvalidPhone = errors.New("sms: número de teléfono inválido")
+
+// NormalizeE164 lleva un número tal como lo escribe un usuario o lo entrega un
+// proveedor ("987 654 321", "0051-987654321", "+51 (987) 654321") a formato
+// E.164 ("+51987654321"). Los números nacionales sin prefijo reciben
+// countryCode; el prefijo troncal 0 se descarta.
+func NormalizeE164(raw, countryCode string) (string, error) {
+	s := strings.TrimSpace(raw)
+	if s == "" {
+		return "", ErrInvalidPhone
+	}
+	international := false
+	switch {
+	case strings.HasPrefix(s, "+"):
+		international = true
+		s = s[1:]
+	case strings.HasPrefix(s, "00"):
+		international = true
+		s = s[2:]
+	}
+
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r >= '0' && r <= '9':
+			b.WriteRune(r)
+		case r == ' ' || r == '-' || r == '.' || r == '(' || r == ')':
+			// separadores habituales
+		default:
+			return "", ErrInvalidPhone
+		}
+	}
+	digits := b.String()
+	if !international {
+		digits = strings.TrimLeft(digits, "0")
+		if !strings.HasPrefix(digits, countryCode) || len(digits) <= 9 {
+			digits = countryCode + digits
+		}
+	}
+	// E.164: hasta 15 dígitos; exigimos un mínimo razonable para descartar basura.
+	if len(digits) < 8 || len(digits) > 15 || digits[0] == '0' {
+		return "", ErrInvalidPhone
+	}
+	return "+" + digits, nil
+}
@@ -0,0 +1,28 @@
+package sms
+
+import "testing"
+
+func TestNormalizeE164(t *testing.T) {
+	cases := []struct {
+		in, want string
+	}{
+		{"987654321", "+51987654321"},
+		{"987 654 321", "+51987654321"},
+		{"+51 (987) 654-321", "+51987654321"},
+		{"0051987654321", "+51987654321"},
+		{"51987654321", "+51987654321"},
+		{"014567890", "+5114567890"},
+		{"+14155550100", "+14155550100"},
+	}
+	for _, c := range cases {
+		got, err := NormalizeE164(c.in, DefaultCountryCode)
+		if err != nil || got != c.want {
+			t.Errorf("NormalizeE164(%q) = %q, %v; want %q", c.in, got, err, c.want)
+		}
+	}
+	for _, bad := range []string{"", "abc", "12", "+1234567890123456", "98765x321"} {
+		if got, err := NormalizeE164(bad, DefaultCountryCode); err == nil {
+			t.Errorf("NormalizeE164(%q) = %q, expected error", bad, got)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package sms
+
+import (
+	"strings"
+	"unicode/utf16"
+)
+
+// Límites de un SMS en septetos (GSM-7) o unidades UTF-16 (UCS-2), con y sin
+// la cabecera de concatenación de 6 octetos.
+const (
+	GSM7Single = 160
+	GSM7Part   = 153
+	UCS2Single = 70
+	UCS2Part   = 67
+)
+
+// Codificaciones de un SMS.
+const (
+	EncodingGSM7 = "GSM-7"
+	EncodingUCS2 = "UCS-2"
+)
+
+// SegmentInfo describe cuánto ocupa un texto enviado como SMS.
+type SegmentInfo struct {
+	Encoding   string   `json:"codificacion"`
+	Units      int      `json:"unidades"` // septetos GSM-7 o unidades UTF-16
+	Segments   int      `json:"segmentos"`
+	PerSegment int      `json:"por_segmento"`
+	Remaining  int      `json:"restantes"`        // unidades libres en el último segmento
+	NonGSM     []string `json:"no_gsm,omitempty"` // caracteres que obligan a usar UCS-2
+}
+
+// Segments calcula la codificación y el número de segmentos de text. Un solo
+// carácter fuera de GSM-7 pasa todo el mensaje a UCS-2, que reduce la
+// capacidad de 160 a 70 caracteres.
+func Segments(text string) SegmentInfo {
+	if septets, ok := EncodeGSM7(text); ok {
+		return segmentInfo(EncodingGSM7, len(septets), GSM7Single, GSM7Part)
+	}
+	info := segmentInfo(EncodingUCS2, len(utf16.Encode([]rune(text))), UCS2Single, UCS2Part)
+	seen := map[rune]bool{}
+	for _, r := range text {
+		if _, ok := EncodeGSM7(string(r)); !ok && !seen[r] {
+			seen[r] = true
+			info.NonGSM = append(info.NonGSM, string(r))
+		}
+	}
+	return info
+}
+
+func segmentInfo(enc string, units, single, part int) SegmentInfo {
+	info := SegmentInfo{Encoding: enc, Units: units, Segments: 1, PerSegment: single}
+	if units > single {
+		info.PerSegment = part
+		info.Segments = (units + part - 1) / part
+	}
+	info.Remaining = info.Segments*info.PerSegment - units
+	return info
+}
+
+// gsmFallback reemplaza caracteres frecuentes en español que no existen en
+// GSM-7 por su equivalente más cercano que sí existe.
+var gsmFallback = strings.NewReplacer(
+	"á", "a", "í", "i", "ó", "o", "ú", "u",
+	"Á", "A", "Í", "I", "Ó", "O", "Ú", "U",
+	"ê", "e", "â", "a", "ô", "o", "ç", "c",
+	"“", "\"", "”", "\"", "‘", "'", "’", "'",
+	"–", "-", "—", "-", "…", "...", "º", "o", "ª", "a",
+)
+
+// StripAccents devuelve text usando solo el alfabeto GSM-7: quita las tildes
+// que no existen en él (á, í, ó, ú; la é, la ñ y la ü sí existen) y reemplaza
+// comillas tipográficas y guiones largos. Lo que no tenga equivalente se
+// omite.
+func StripAccents(text string) string {
+	text = gsmFallback.Replace(text)
+	if _, ok := EncodeGSM7(text); ok {
+		return text
+	}
+	return strings.Map(func(r rune) rune {
+		if _, ok := EncodeGSM7(string(r)); ok {
+			return r
+		}
+		return -1
+	}, text)
+}
@@ -1,6 +1,6 @@
 package sms
 
-// Paquete sms: stub para una futura integración con proveedor de SMS.
+// Paquete sms: integración con proveedores de SMS.
 // Define interfaces y estructuras básicas para desacoplar implementación.
 
 // Sender representa un cliente capaz de enviar SMS reales.
@@ -8,10 +8,15 @@ type Sender interface {
 	Send(to string, message string) error
 }
 
+// IDSender es un Sender que además devuelve el id que el proveedor asignó al
+// mensaje, para asociarle después los reportes de entrega.
+type IDSender interface {
+	Sender
+	SendWithID(to string, message string) (string, error)
+}
+
 // Receiver representa un webhook/cliente para recibir SMS entrantes.
 type Receiver interface {
 	// ParseAndAck procesa la carga entrante del proveedor y confirma recepción.
 	ParseAndAck(payload []byte) error
 }
-
-// En el futuro, puede añadirse una implementación concreta (Twilio, etc.).
@@ -0,0 +1,226 @@
+package sms
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"alerta_climatica/internal/processing"
+)
+
+// ErrInvalidSignature indica un webhook cuya firma X-Twilio-Signature no
+// corresponde al token configurado.
+var ErrInvalidSignature = errors.New("sms: firma de Twilio inválida")
+
+// Twilio reintenta un webhook que no respondió a tiempo; los MessageSid vistos
+// y entregados en esta ventana se confirman sin volver a procesarse.
+const twilioSidWindow = 10 * time.Minute
+
+// maxWebhookBody limita el tamaño de un webhook entrante.
+const maxWebhookBody = 64 << 10
+
+// ZoneResolver deduce la zona de un SMS que no la trae como campo aparte y
+// devuelve el texto sin el prefijo de zona.
+type ZoneResolver func(from, body string) (zone, text string)
+
+// TwilioReceiver implementa Receiver para webhooks de SMS entrantes con el
+// formato de Twilio (application/x-www-form-urlencoded con From, Body y
+// MessageSid). Como http.Handler verifica la firma y responde TwiML.
+type TwilioReceiver struct {
+	authToken string
+	publicURL string // URL base con la que Twilio firma (detrás de proxies r.Host no sirve)
+	deliver   func(processing.IncomingMessage) error
+	zone      ZoneResolver
+
+	mu   sync.Mutex
+	sids map[string]time.Time // MessageSid recientes, para ignorar reintentos
+}
+
+// NewTwilioReceiver crea el receptor. authToken firma los webhooks; publicURL
+// es el esquema y host públicos ("https://alertas.example.org"), o "" para
+// deducirlos de la petición. deliver recibe cada mensaje (p. ej. Processor.Submit).
+func NewTwilioReceiver(authToken, publicURL string, deliver func(processing.IncomingMessage) error) *TwilioReceiver {
+	return &TwilioReceiver{
+		authToken: authToken,
+		publicURL: strings.TrimSuffix(publicURL, "/"),
+		deliver:   deliver,
+		sids:      make(map[string]time.Time),
+	}
+}
+
+// SetZoneResolver define cómo se obtiene la zona del texto del SMS.
+func (t *TwilioReceiver) SetZoneResolver(fn ZoneResolver) {
+	t.zone = fn
+}
+
+// ParseAndAck convierte el cuerpo de un webhook de Twilio en un
+// IncomingMessage y lo entrega. Un MessageSid ya entregado se confirma sin
+// entregarlo de nuevo. No verifica la firma; eso lo hace ServeHTTP, y quien
+// lo llame directamente debe haber autenticado el cuerpo.
+func (t *TwilioReceiver) ParseAndAck(payload []byte) error {
+	form, err := url.ParseQuery(string(payload))
+	if err != nil {
+		return err
+	}
+	from, err := NormalizeE164(form.Get("From"), DefaultCountryCode)
+	if err != nil {
+		return err
+	}
+	sid := form.Get("MessageSid")
+	if sid != "" && t.seen(sid, time.Now()) {
+		log.Printf("sms: twilio retry for %s ignored", sid)
+		return nil
+	}
+	body := strings.TrimSpace(form.Get("Body"))
+	zone, text := "", body
+	if t.zone != nil {
+		zone, text = t.zone(from, body)
+	}
+	if err := t.deliver(processing.IncomingMessage{
+		Zone:       zone,
+		Text:       text,
+		From:       from,
+		Channel:    "sms",
+		Verified:   true, // ServeHTTP verificó la firma
+		ReceivedAt: time.Now(),
+	}); err != nil {
+		return err
+	}
+	// Solo se recuerda lo entregado: si falla, el reintento de Twilio debe
+	// procesarse.
+	if sid != "" {
+		t.remember(sid, time.Now())
+	}
+	return nil
+}
+
+// seen dice si sid ya se entregó dentro de la ventana.
+func (t *TwilioReceiver) seen(sid string, now time.Time) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for s, at := range t.sids {
+		if now.Sub(at) > twilioSidWindow {
+			delete(t.sids, s)
+		}
+	}
+	_, ok := t.sids[sid]
+	return ok
+}
+
+// remember registra sid como entregado.
+func (t *TwilioReceiver) remember(sid string, now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.sids[sid] = now
+}
+
+// twiml es la respuesta al webhook. Se responde sin <Message>: las respuestas
+// a comandos salen por el Sender y una confirmación aquí duplicaría SMS.
+type twiml struct {
+	XMLName xml.Name `xml:"Response"`
+	Message string   `xml:"Message,omitempty"`
+}
+
+// ServeHTTP atiende el webhook: verifica X-Twilio-Signature, entrega el
+// mensaje y responde TwiML. Si el procesador no acepta el mensaje responde
+// 503 para que Twilio reintente.
+func (t *TwilioReceiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	payload, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxWebhookBody))
+	if err != nil {
+		http.Error(w, "cuerpo inválido", http.StatusBadRequest)
+		return
+	}
+	params, err := url.ParseQuery(string(payload))
+	if err != nil {
+		http.Error(w, "formulario inválido", http.StatusBadRequest)
+		return
+	}
+	if !t.Verify(r, params) {
+		log.Println("sms: rejected twilio webhook:", ErrInvalidSignature)
+		http.Error(w, "firma inválida", http.StatusForbidden)
+		return
+	}
+	if err := t.ParseAndAck(payload); err != nil {
+		if errors.Is(err, ErrInvalidPhone) {
+			http.Error(w, "From inválido", http.StatusBadRequest)
+			return
+		}
+		log.Println("sms: twilio message rejected:", err)
+		http.Error(w, "servicio no disponible, reintente", http.StatusServiceUnavailable)
+		return
+	}
+	w.Header().Set("Content-Type", "text/xml; charset=utf-8")
+	io.WriteString(w, xml.Header)
+	if err := xml.NewEncoder(w).Encode(twiml{}); err != nil {
+		log.Println("sms: cannot write twiml:", err)
+	}
+}
+
+// Verify comprueba la cabecera X-Twilio-Signature de r con sus parámetros
+// POST. Sirve también para otros webhooks de Twilio, como los reportes de
+// entrega.
+func (t *TwilioReceiver) Verify(r *http.Request, params url.Values) bool {
+	return t.ValidSignature(t.requestURL(r), params, r.Header.Get("X-Twilio-Signature"))
+}
+
+// requestURL reconstruye la URL completa con la que Twilio firmó la petición.
+func (t *TwilioReceiver) requestURL(r *http.Request) string {
+	if t.publicURL != "" {
+		return t.publicURL + r.URL.RequestURI()
+	}
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if p := r.Header.Get("X-Forwarded-Proto"); p != "" {
+		scheme = p
+	}
+	return scheme + "://" + r.Host + r.URL.RequestURI()
+}
+
+// ValidSignature comprueba la firma de Twilio: HMAC-SHA1 con el auth token
+// sobre la URL seguida de cada parámetro POST (nombre y valor) en orden
+// alfabético, codificado en base64.
+func (t *TwilioReceiver) ValidSignature(fullURL string, params url.Values, signature string) bool {
+	if t.authToken == "" || signature == "" {
+		return false
+	}
+	want, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+	return hmac.Equal(want, twilioSignature(t.authToken, fullURL, params))
+}
+
+func twilioSignature(token, fullURL string, params url.Values) []byte {
+	keys := make([]string, 0, len(params))
+	for k := range params {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	b.WriteString(fullURL)
+	for _, k := range keys {
+		for _, v := range params[k] {
+			b.WriteString(k)
+			b.WriteString(v)
+		}
+	}
+	mac := hmac.New(sha1.New, []byte(token))
+	mac.Write([]byte(b.String()))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,149 @@
+package sms
+
+import (
+	"encoding/xml"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"alerta_climatica/internal/processing"
+)
+
+// Firmas registradas de los payloads en testdata, calculadas con el token y
+// la URL pública de prueba.
+const (
+	testTwilioToken = "12345abcdef"
+	testTwilioURL   = "https://alertas.example.org"
+	sigInbound      = "Sfw+EetiVaSPGdRCTaoVVJ7VKu0="
+	sigCommand      = "4HgFcjP4ma5/AES5B2S6gT7mOFI="
+)
+
+func twilioRequest(t *testing.T, file, sig string) *http.Request {
+	t.Helper()
+	body, err := os.ReadFile("testdata/" + file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := httptest.NewRequest(http.MethodPost, "/api/sms/twilio", strings.NewReader(string(body)))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	r.Header.Set("X-Twilio-Signature", sig)
+	return r
+}
+
+func TestTwilioReceiverWebhook(t *testing.T) {
+	var got []processing.IncomingMessage
+	rcv := NewTwilioReceiver(testTwilioToken, testTwilioURL, func(m processing.IncomingMessage) error {
+		got = append(got, m)
+		return nil
+	})
+	zones := func() []string { return []string{"Zona Norte", "Zona Centro", "Zona Sur"} }
+	rcv.SetZoneResolver(PrefixZoneResolver(zones, "Zona Centro"))
+
+	w := httptest.NewRecorder()
+	rcv.ServeHTTP(w, twilioRequest(t, "twilio_inbound.form", sigInbound))
+	if w.Code != http.StatusOK || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/xml") {
+		t.Fatalf("unexpected response %d %q: %s", w.Code, w.Header().Get("Content-Type"), w.Body)
+	}
+	var resp struct {
+		XMLName xml.Name `xml:"Response"`
+	}
+	if err := xml.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("response is not TwiML: %v: %s", err, w.Body)
+	}
+	if len(got) != 1 {
+		t.Fatalf("expected one delivered message, got %d", len(got))
+	}
+	m := got[0]
+	if m.Zone != "Zona Sur" || m.Text != "se desborda el río junto al puente" || m.From != "+51987654321" || m.Channel != "sms" {
+		t.Fatalf("unexpected message: %+v", m)
+	}
+
+	// Reintento de Twilio con el mismo MessageSid: se confirma sin reprocesar.
+	w = httptest.NewRecorder()
+	rcv.ServeHTTP(w, twilioRequest(t, "twilio_inbound.form", sigInbound))
+	if w.Code != http.StatusOK || len(got) != 1 {
+		t.Fatalf("retry: status %d, delivered %d", w.Code, len(got))
+	}
+
+	// Número nacional y sin zona: se normaliza y se usa la zona por defecto.
+	w = httptest.NewRecorder()
+	rcv.ServeHTTP(w, twilioRequest(t, "twilio_command.form", sigCommand))
+	if w.Code != http.StatusOK || len(got) != 2 {
+		t.Fatalf("command: status %d, delivered %d", w.Code, len(got))
+	}
+	if m := got[1]; m.From != "+51987654321" || m.Zone != "Zona Centro" || m.Text != "ZONAS" {
+		t.Fatalf("unexpected command message: %+v", m)
+	}
+}
+
+func TestTwilioReceiverRejects(t *testing.T) {
+	fail := errors.New("cerrado")
+	rcv := NewTwilioReceiver(testTwilioToken, testTwilioURL, func(processing.IncomingMessage) error { return fail })
+
+	cases := []struct {
+		name string
+		req  *http.Request
+		want int
+	}{
+		{"wrong signature", twilioRequest(t, "twilio_inbound.form", sigCommand), http.StatusForbidden},
+		{"missing signature", twilioRequest(t, "twilio_inbound.form", ""), http.StatusForbidden},
+		{"processor closed", twilioRequest(t, "twilio_inbound.form", sigInbound), http.StatusServiceUnavailable},
+	}
+	for _, c := range cases {
+		w := httptest.NewRecorder()
+		rcv.ServeHTTP(w, c.req)
+		if w.Code != c.want {
+			t.Errorf("%s: status %d, want %d", c.name, w.Code, c.want)
+		}
+	}
+
+	// El reintento de un mensaje que no se pudo entregar se procesa.
+	var got []processing.IncomingMessage
+	rcv = NewTwilioReceiver(testTwilioToken, testTwilioURL, func(m processing.IncomingMessage) error {
+		got = append(got, m)
+		if len(got) == 1 {
+			return fail
+		}
+		return nil
+	})
+	for i, want := range []int{http.StatusServiceUnavailable, http.StatusOK, http.StatusOK} {
+		w := httptest.NewRecorder()
+		rcv.ServeHTTP(w, twilioRequest(t, "twilio_inbound.form", sigInbound))
+		if w.Code != want {
+			t.Fatalf("attempt %d: status %d, want %d", i+1, w.Code, want)
+		}
+	}
+	if len(got) != 2 {
+		t.Fatalf("delivery attempts = %d, want 2 (failed, retried; then ignored)", len(got))
+	}
+
+	// Sin URL pública la firma se calcula con el host de la petición.
+	rcv = NewTwilioReceiver(testTwilioToken, "", func(processing.IncomingMessage) error { return nil })
+	r := twilioRequest(t, "twilio_inbound.form", sigInbound)
+	r.Host = "alertas.example.org"
+	r.Header.Set("X-Forwarded-Proto", "https")
+	w := httptest.NewRecorder()
+	rcv.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("forwarded request: status %d", w.Code)
+	}
+}
+
+func TestPrefixZoneResolver(t *testing.T) {
+	resolve := PrefixZoneResolver(func() []string { return []string{"Zona Sur", "Zona Sur Alta", "Río Seco"} }, "Zona Centro")
+	cases := []struct{ body, zone, text string }{
+		{"ZONA SUR: lluvia intensa", "Zona Sur", "lluvia intensa"},
+		{"zona sur alta - huaico", "Zona Sur Alta", "huaico"},
+		{"Rio Seco, desborde", "Río Seco", "desborde"},
+		{"Zona Surco inundada", "Zona Centro", "Zona Surco inundada"},
+		{"lluvia intensa", "Zona Centro", "lluvia intensa"},
+	}
+	for _, c := range cases {
+		if zone, text := resolve("", c.body); zone != c.zone || text != c.text {
+			t.Errorf("%q: got (%q, %q), want (%q, %q)", c.body, zone, text, c.zone, c.text)
+		}
+	}
+}
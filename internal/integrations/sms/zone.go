@@ -0,0 +1,44 @@
+package sms
+
+import (
+	"strings"
+	"unicode"
+)
+
+var accentFolder = strings.NewReplacer("á", "a", "é", "e", "í", "i", "ó", "o", "ú", "u", "ü", "u", "ñ", "n")
+
+// PrefixZoneResolver reconoce la zona al inicio del SMS ("Zona Sur: se
+// desborda el río"), sin distinguir mayúsculas ni tildes. Si el texto no
+// empieza con una zona conocida se usa def y el texto queda intacto.
+func PrefixZoneResolver(zones func() []string, def string) ZoneResolver {
+	return func(_, body string) (string, string) {
+		folded := accentFolder.Replace(strings.ToLower(body))
+		best, bestLen := "", 0
+		for _, z := range zones() {
+			// Ante zonas que son prefijo de otras, gana la más larga.
+			fz := accentFolder.Replace(strings.ToLower(z))
+			if len(fz) > bestLen && strings.HasPrefix(folded, fz) && boundary(folded[len(fz):]) {
+				best, bestLen = z, len(fz)
+			}
+		}
+		if best == "" {
+			return def, body
+		}
+		// Las tildes ocupan distinto número de bytes tras el plegado: avanzar
+		// sobre el texto original tantas runas como tiene la zona.
+		rest := []rune(body)[len([]rune(best)):]
+		text := strings.TrimLeftFunc(string(rest), func(r rune) bool {
+			return unicode.IsSpace(r) || r == ':' || r == ',' || r == '-' || r == '.'
+		})
+		return best, text
+	}
+}
+
+// boundary indica si tras el nombre de zona termina la palabra.
+func boundary(rest string) bool {
+	if rest == "" {
+		return true
+	}
+	r := []rune(rest)[0]
+	return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+}
@@ -0,0 +1,281 @@
+package weather
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrBadLocation indica una ubicación que no es "lat,lon" válido.
+var ErrBadLocation = errors.New("weather: ubicación inválida, se espera \"lat,lon\"")
+
+// Parámetros por defecto del adaptador Open-Meteo.
+const (
+	DefaultOpenMeteoURL = "https://api.open-meteo.com"
+	defaultCacheTTL     = 10 * time.Minute
+	// Ante un error del proveedor se sirve el último dato si no es más viejo que esto.
+	maxStale      = time.Hour
+	forecastDays  = 3
+	openMeteoVars = "temperature_2m,relative_humidity_2m,precipitation,wind_speed_10m,weather_code"
+)
+
+// Location arma la ubicación "lat,lon" que esperan los métodos de Client.
+func Location(lat, lon float64) string {
+	return strconv.FormatFloat(lat, 'f', 4, 64) + "," + strconv.FormatFloat(lon, 'f', 4, 64)
+}
+
+// parseLocation valida y separa "lat,lon".
+func parseLocation(location string) (lat, lon string, err error) {
+	la, lo, ok := strings.Cut(location, ",")
+	if !ok {
+		return "", "", ErrBadLocation
+	}
+	la, lo = strings.TrimSpace(la), strings.TrimSpace(lo)
+	flat, err1 := strconv.ParseFloat(la, 64)
+	flon, err2 := strconv.ParseFloat(lo, 64)
+	if err1 != nil || err2 != nil || flat < -90 || flat > 90 || flon < -180 || flon > 180 {
+		return "", "", ErrBadLocation
+	}
+	return la, lo, nil
+}
+
+// OpenMeteo implementa Client contra /v1/forecast de Open-Meteo (o una API
+// compatible). Una sola consulta trae condiciones actuales y pronóstico
+// horario; la respuesta se guarda por ubicación durante el TTL del caché.
+type OpenMeteo struct {
+	baseURL string
+	client  *http.Client
+	ttl     time.Duration
+	now     func() time.Time
+
+	mu    sync.Mutex
+	cache map[string]cachedForecast
+}
+
+type cachedForecast struct {
+	at       time.Time
+	current  Conditions
+	forecast []Conditions
+}
+
+// NewOpenMeteo crea el adaptador. baseURL "" usa DefaultOpenMeteoURL; client
+// nil usa un cliente con timeout de 10 s.
+func NewOpenMeteo(baseURL string, client *http.Client) *OpenMeteo {
+	if baseURL == "" {
+		baseURL = DefaultOpenMeteoURL
+	}
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	return &OpenMeteo{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		client:  client,
+		ttl:     defaultCacheTTL,
+		now:     time.Now,
+		cache:   make(map[string]cachedForecast),
+	}
+}
+
+// SetCacheTTL cambia cuánto tiempo se reutiliza una respuesta (0 desactiva el caché).
+func (o *OpenMeteo) SetCacheTTL(ttl time.Duration) {
+	o.mu.Lock()
+	o.ttl = ttl
+	o.mu.Unlock()
+}
+
+// CurrentConditions devuelve las condiciones actuales en location.
+func (o *OpenMeteo) CurrentConditions(location string) (Conditions, error) {
+	f, err := o.get(location)
+	if err != nil {
+		return Conditions{}, err
+	}
+	return f.current, nil
+}
+
+// Forecast devuelve el pronóstico horario desde la hora en curso.
+func (o *OpenMeteo) Forecast(location string) ([]Conditions, error) {
+	f, err := o.get(location)
+	if err != nil {
+		return nil, err
+	}
+	from := o.now().Truncate(time.Hour)
+	out := make([]Conditions, 0, len(f.forecast))
+	for _, c := range f.forecast {
+		if !c.Time.Before(from) {
+			out = append(out, c)
+		}
+	}
+	return out, nil
+}
+
+// get devuelve la respuesta en caché o consulta al proveedor. Si la consulta
+// falla y hay un dato de menos de maxStale, se usa ése.
+func (o *OpenMeteo) get(location string) (cachedForecast, error) {
+	lat, lon, err := parseLocation(location)
+	if err != nil {
+		return cachedForecast{}, err
+	}
+	key := lat + "," + lon
+	now := o.now()
+	o.mu.Lock()
+	cached, ok := o.cache[key]
+	ttl := o.ttl
+	o.mu.Unlock()
+	if ok && now.Sub(cached.at) < ttl {
+		return cached, nil
+	}
+
+	f, err := o.fetch(lat, lon)
+	if err != nil {
+		if ok && now.Sub(cached.at) < maxStale {
+			log.Printf("warning: weather: %v; using data from %s", err, cached.at.Format(time.RFC3339))
+			return cached, nil
+		}
+		return cachedForecast{}, err
+	}
+	f.at = now
+	o.mu.Lock()
+	o.cache[key] = f
+	o.mu.Unlock()
+	return f, nil
+}
+
+// openMeteoResponse es el subconjunto usado de la respuesta de /v1/forecast.
+type openMeteoResponse struct {
+	UTCOffset int `json:"utc_offset_seconds"`
+	Current   struct {
+		Time        string  `json:"time"`
+		Temperature float64 `json:"temperature_2m"`
+		Humidity    float64 `json:"relative_humidity_2m"`
+		Rain        float64 `json:"precipitation"`
+		Wind        float64 `json:"wind_speed_10m"`
+		Code        int     `json:"weather_code"`
+	} `json:"current"`
+	Hourly struct {
+		Time        []string  `json:"time"`
+		Temperature []float64 `json:"temperature_2m"`
+		Humidity    []float64 `json:"relative_humidity_2m"`
+		Rain        []float64 `json:"precipitation"`
+		Wind        []float64 `json:"wind_speed_10m"`
+		Code        []int     `json:"weather_code"`
+	} `json:"hourly"`
+	Error  bool   `json:"error"`
+	Reason string `json:"reason"`
+}
+
+func (o *OpenMeteo) fetch(lat, lon string) (cachedForecast, error) {
+	q := url.Values{
+		"latitude":        {lat},
+		"longitude":       {lon},
+		"current":         {openMeteoVars},
+		"hourly":          {openMeteoVars},
+		"forecast_days":   {strconv.Itoa(forecastDays)},
+		"wind_speed_unit": {"kmh"},
+		"timezone":        {"UTC"},
+	}
+	resp, err := o.client.Get(o.baseURL + "/v1/forecast?" + q.Encode())
+	if err != nil {
+		return cachedForecast{}, fmt.Errorf("weather: open-meteo: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return cachedForecast{}, fmt.Errorf("weather: open-meteo: %w", err)
+	}
+	var r openMeteoResponse
+	decodeErr := json.Unmarshal(body, &r)
+	if resp.StatusCode != http.StatusOK || r.Error {
+		if r.Reason != "" {
+			return cachedForecast{}, fmt.Errorf("weather: open-meteo respondió %s: %s", resp.Status, r.Reason)
+		}
+		return cachedForecast{}, fmt.Errorf("weather: open-meteo respondió %s", resp.Status)
+	}
+	if decodeErr != nil {
+		return cachedForecast{}, fmt.Errorf("weather: respuesta de open-meteo ilegible: %w", decodeErr)
+	}
+	return r.conditions()
+}
+
+// conditions convierte la respuesta; las horas vienen sin zona, con el
+// desfase utc_offset_seconds.
+func (r *openMeteoResponse) conditions() (cachedForecast, error) {
+	loc := time.FixedZone("", r.UTCOffset)
+	parse := func(s string) (time.Time, error) {
+		t, err := time.ParseInLocation("2006-01-02T15:04", s, loc)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("weather: hora inválida %q en respuesta de open-meteo", s)
+		}
+		return t.UTC(), nil
+	}
+	var f cachedForecast
+	t, err := parse(r.Current.Time)
+	if err != nil {
+		return f, err
+	}
+	f.current = Conditions{
+		Time:         t,
+		TemperatureC: r.Current.Temperature,
+		RainMM:       r.Current.Rain,
+		WindKPH:      r.Current.Wind,
+		HumidityPct:  r.Current.Humidity,
+		Summary:      weatherCodeSummary(r.Current.Code),
+	}
+	h := r.Hourly
+	n := len(h.Time)
+	if len(h.Temperature) != n || len(h.Humidity) != n || len(h.Rain) != n || len(h.Wind) != n || len(h.Code) != n {
+		return f, errors.New("weather: series horarias de open-meteo de distinto largo")
+	}
+	f.forecast = make([]Conditions, n)
+	for i := range h.Time {
+		t, err := parse(h.Time[i])
+		if err != nil {
+			return f, err
+		}
+		f.forecast[i] = Conditions{
+			Time:         t,
+			TemperatureC: h.Temperature[i],
+			RainMM:       h.Rain[i],
+			WindKPH:      h.Wind[i],
+			HumidityPct:  h.Humidity[i],
+			Summary:      weatherCodeSummary(h.Code[i]),
+		}
+	}
+	return f, nil
+}
+
+// weatherCodeSummary describe un código de tiempo WMO (el que usa Open-Meteo).
+func weatherCodeSummary(code int) string {
+	switch {
+	case code == 0:
+		return "despejado"
+	case code <= 3:
+		return "parcialmente nublado"
+	case code == 45 || code == 48:
+		return "niebla"
+	case code >= 51 && code <= 57:
+		return "llovizna"
+	case code == 61 || code == 80:
+		return "lluvia ligera"
+	case code == 63 || code == 81:
+		return "lluvia moderada"
+	case code == 65 || code == 82:
+		return "lluvia intensa"
+	case code == 66 || code == 67:
+		return "lluvia helada"
+	case code >= 71 && code <= 77, code == 85 || code == 86:
+		return "nieve"
+	case code == 95:
+		return "tormenta"
+	case code == 96 || code == 99:
+		return "tormenta con granizo"
+	}
+	return "código " + strconv.Itoa(code)
+}
@@ -0,0 +1,98 @@
+package weather
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// recordedServer sirve testdata/file como respuesta de /v1/forecast y cuenta las consultas.
+func recordedServer(t *testing.T, file string, status int) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	body, err := os.ReadFile("testdata/" + file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var calls atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		q := r.URL.Query()
+		if r.URL.Path != "/v1/forecast" || q.Get("latitude") != "-12.0600" || !strings.Contains(q.Get("hourly"), "precipitation") {
+			t.Errorf("unexpected request %s", r.URL)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		w.Write(body)
+	}))
+	t.Cleanup(ts.Close)
+	return ts, &calls
+}
+
+func TestOpenMeteoCurrentAndForecast(t *testing.T) {
+	ts, calls := recordedServer(t, "openmeteo_forecast.json", http.StatusOK)
+	c := NewOpenMeteo(ts.URL, ts.Client())
+	c.now = func() time.Time { return time.Date(2025, 3, 14, 18, 50, 0, 0, time.UTC) }
+	loc := Location(-12.06, -77.05)
+
+	cur, err := c.CurrentConditions(loc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Conditions{Time: time.Date(2025, 3, 14, 18, 45, 0, 0, time.UTC), TemperatureC: 22.4, RainMM: 4.2, WindKPH: 18.7, HumidityPct: 91, Summary: "lluvia moderada"}
+	if cur != want {
+		t.Fatalf("CurrentConditions = %+v, want %+v", cur, want)
+	}
+
+	fc, err := c.Forecast(loc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fc) != 5 || !fc[0].Time.Equal(time.Date(2025, 3, 14, 18, 0, 0, 0, time.UTC)) {
+		t.Fatalf("forecast should start at the current hour, got %+v", fc)
+	}
+	if fc[2].RainMM != 18.2 || fc[2].WindKPH != 31 || fc[2].Summary != "tormenta" {
+		t.Fatalf("unexpected 20:00 forecast %+v", fc[2])
+	}
+	if n := calls.Load(); n != 1 {
+		t.Fatalf("expected one request served from cache, got %d", n)
+	}
+}
+
+func TestOpenMeteoErrorsAndStaleCache(t *testing.T) {
+	if _, err := NewOpenMeteo("http://127.0.0.1:0", nil).CurrentConditions("Zona Sur"); err != ErrBadLocation {
+		t.Fatalf("expected ErrBadLocation, got %v", err)
+	}
+
+	ts, _ := recordedServer(t, "openmeteo_error.json", http.StatusBadRequest)
+	_, err := NewOpenMeteo(ts.URL, ts.Client()).CurrentConditions(Location(-12.06, -77.05))
+	if err == nil || !strings.Contains(err.Error(), "Latitude must be in range") {
+		t.Fatalf("expected provider reason in error, got %v", err)
+	}
+
+	// Un proveedor que deja de responder: se sirve el último dato hasta maxStale.
+	good, _ := recordedServer(t, "openmeteo_forecast.json", http.StatusOK)
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+	}))
+	defer slow.Close()
+
+	now := time.Date(2025, 3, 14, 18, 50, 0, 0, time.UTC)
+	c := NewOpenMeteo(good.URL, &http.Client{Timeout: 50 * time.Millisecond})
+	c.now = func() time.Time { return now }
+	if _, err := c.CurrentConditions(Location(-12.06, -77.05)); err != nil {
+		t.Fatal(err)
+	}
+	c.baseURL = slow.URL
+	now = now.Add(20 * time.Minute)
+	if cur, err := c.CurrentConditions(Location(-12.06, -77.05)); err != nil || cur.RainMM != 4.2 {
+		t.Fatalf("expected stale data after timeout, got %+v, %v", cur, err)
+	}
+	now = now.Add(maxStale)
+	if _, err := c.CurrentConditions(Location(-12.06, -77.05)); err == nil {
+		t.Fatal("expected error once cached data is too old")
+	}
+}
@@ -1,8 +1,12 @@
 package weather
 
-// Paquete weather: stub para una futura integración con API meteorológica.
+import "time"
+
+// Paquete weather: integración con APIs meteorológicas. OpenMeteo implementa
+// Client para APIs con el formato de Open-Meteo.
 
 // Client define los métodos esperados de un proveedor de datos del tiempo.
+// location es "lat,lon" en grados decimales (ver Location).
 type Client interface {
 	// CurrentConditions obtiene condiciones actuales para una ubicación.
 	CurrentConditions(location string) (Conditions, error)
@@ -10,13 +14,13 @@ type Client interface {
 	Forecast(location string) ([]Conditions, error)
 }
 
-// Conditions es un modelo simplificado de datos climáticos.
+// Conditions es un modelo simplificado de datos climáticos. Time es el
+// instante observado o, en un pronóstico, el inicio de la hora pronosticada.
 type Conditions struct {
-	TemperatureC float64
-	RainMM       float64
-	WindKPH      float64
-	HumidityPct  float64
-	Summary      string
+	Time         time.Time `json:"hora"`
+	TemperatureC float64   `json:"temperatura_c"`
+	RainMM       float64   `json:"lluvia_mm"` // precipitación en la hora (o en el intervalo actual)
+	WindKPH      float64   `json:"viento_kph"`
+	HumidityPct  float64   `json:"humedad_pct"`
+	Summary      string    `json:"resumen"`
 }
-
-// Se podrían añadir adaptadores a APIs públicas (e.g., con claves de API).
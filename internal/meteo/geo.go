@@ -0,0 +1,122 @@
+package meteo
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"math"
+	"sync"
+
+	"alerta_climatica/internal/integrations/weather"
+	"alerta_climatica/internal/storage"
+)
+
+// ErrNoGeometry indica una geometría de zona vacía o de un tipo no soportado.
+var ErrNoGeometry = errors.New("meteo: geometría de zona sin coordenadas utilizables")
+
+// geometry es el subconjunto de GeoJSON que se usa para ubicar una zona.
+type geometry struct {
+	Type        string          `json:"type"`
+	Coordinates json.RawMessage `json:"coordinates"`
+}
+
+// Centroid calcula el centroide (lat, lon) de una geometría GeoJSON: Point,
+// Polygon o MultiPolygon (ponderado por área, anillo exterior de cada
+// polígono). Las coordenadas GeoJSON vienen como [lon, lat].
+func Centroid(geomJSON string) (lat, lon float64, err error) {
+	var g geometry
+	if err := json.Unmarshal([]byte(geomJSON), &g); err != nil {
+		return 0, 0, err
+	}
+	var polys [][][][2]float64
+	switch g.Type {
+	case "Point":
+		var p [2]float64
+		if err := json.Unmarshal(g.Coordinates, &p); err != nil {
+			return 0, 0, err
+		}
+		return p[1], p[0], nil
+	case "Polygon":
+		var rings [][][2]float64
+		if err := json.Unmarshal(g.Coordinates, &rings); err != nil {
+			return 0, 0, err
+		}
+		polys = [][][][2]float64{rings}
+	case "MultiPolygon":
+		if err := json.Unmarshal(g.Coordinates, &polys); err != nil {
+			return 0, 0, err
+		}
+	default:
+		return 0, 0, ErrNoGeometry
+	}
+
+	var area, cx, cy float64
+	var sumX, sumY float64
+	var n int
+	for _, rings := range polys {
+		if len(rings) == 0 {
+			continue
+		}
+		ring := rings[0]
+		for i := range ring {
+			x0, y0 := ring[i][0], ring[i][1]
+			x1, y1 := ring[(i+1)%len(ring)][0], ring[(i+1)%len(ring)][1]
+			cross := x0*y1 - x1*y0
+			area += cross
+			cx += (x0 + x1) * cross
+			cy += (y0 + y1) * cross
+			sumX += x0
+			sumY += y0
+			n++
+		}
+	}
+	if n == 0 {
+		return 0, 0, ErrNoGeometry
+	}
+	// Polígonos degenerados (área nula): promedio de vértices.
+	if math.Abs(area) < 1e-12 {
+		return sumY / float64(n), sumX / float64(n), nil
+	}
+	area /= 2
+	return cy / (6 * area), cx / (6 * area), nil
+}
+
+// centroid es la ubicación calculada de una geometría de zona.
+type centroid struct {
+	lat, lon float64
+	loc      string // "lat,lon" para weather.Client; "" si la geometría no sirve
+}
+
+// locator calcula y recuerda el centroide de cada geometría de zona.
+type locator struct {
+	mu    sync.Mutex
+	cache map[string]centroid
+}
+
+func (l *locator) get(z storage.Zone) centroid {
+	l.mu.Lock()
+	c, ok := l.cache[z.Geom]
+	l.mu.Unlock()
+	if ok {
+		return c
+	}
+	lat, lon, err := Centroid(z.Geom)
+	if err != nil {
+		log.Printf("warning: meteo: zone %q has no usable geometry: %v", z.Name, err)
+	} else {
+		c = centroid{lat: lat, lon: lon, loc: weather.Location(lat, lon)}
+	}
+	l.mu.Lock()
+	if l.cache == nil {
+		l.cache = make(map[string]centroid)
+	}
+	l.cache[z.Geom] = c
+	l.mu.Unlock()
+	return c
+}
+
+// location devuelve el centroide de la zona como "lat,lon".
+func (l *locator) location(z storage.Zone) (string, bool) {
+	c := l.get(z)
+	return c.loc, c.loc != ""
+}
@@ -0,0 +1,228 @@
+package meteo
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"alerta_climatica/internal/integrations/weather"
+	"alerta_climatica/internal/processing"
+	"alerta_climatica/internal/storage"
+)
+
+// KindPreAlert identifica una pre-alerta por pronóstico.
+const KindPreAlert = "pre-alerta"
+
+// minRainMM es la lluvia horaria desde la que una hora cuenta como lluviosa
+// para el inicio y el fin previstos de una pre-alerta de lluvia.
+const minRainMM = 1.0
+
+// PreAlert es un aviso temprano: el pronóstico supera un umbral en las
+// próximas horas. No escala la zona; caduca sola al pasar el episodio.
+type PreAlert struct {
+	ID        string    `json:"id"`
+	Kind      string    `json:"tipo"` // siempre "pre-alerta"
+	Zone      string    `json:"zona"`
+	Type      string    `json:"fenomeno"`
+	Severity  string    `json:"severidad"`
+	Rule      string    `json:"regla"`
+	Message   string    `json:"mensaje"`
+	Value     float64   `json:"valor"` // lluvia acumulada o pico previsto
+	Threshold float64   `json:"umbral"`
+	Onset     time.Time `json:"inicio_previsto"`
+	Peak      time.Time `json:"pico"`
+	Expires   time.Time `json:"expira"`
+	Issued    time.Time `json:"emitida"`
+	Lat       float64   `json:"lat"`
+	Lon       float64   `json:"lon"`
+}
+
+// DefaultPreAlertRules miran 24 a 72 horas adelante.
+func DefaultPreAlertRules() []Rule {
+	return []Rule{
+		{Name: "lluvia-24h", Variable: VarRain, Threshold: 30, Hours: 24, Type: "lluvia", Severity: "media"},
+		{Name: "lluvia-72h", Variable: VarRain, Threshold: 60, Hours: 72, Type: "lluvia", Severity: "alta"},
+		{Name: "viento-48h", Variable: VarWind, Threshold: 70, Hours: 48, Type: "viento", Severity: "media"},
+	}
+}
+
+// Forecaster evalúa el pronóstico de cada zona y mantiene las pre-alertas
+// vigentes. Como http.Handler sirve GET /api/prealerts.
+type Forecaster struct {
+	client  weather.Client
+	zones   func() ([]storage.Zone, error)
+	locator locator
+
+	mu     sync.Mutex
+	rules  []Rule
+	active map[string]PreAlert // zona|regla
+}
+
+// NewForecaster crea el evaluador con las reglas por defecto.
+func NewForecaster(client weather.Client, zones func() ([]storage.Zone, error)) *Forecaster {
+	return &Forecaster{client: client, zones: zones, rules: DefaultPreAlertRules(), active: make(map[string]PreAlert)}
+}
+
+// SetRules reemplaza las reglas; todas deben mirar el pronóstico (horas > 0).
+func (f *Forecaster) SetRules(rules []Rule) error {
+	for _, r := range rules {
+		if err := r.Validate(); err != nil {
+			return err
+		}
+		if r.Hours == 0 {
+			return fmt.Errorf("meteo: regla de pre-alerta %q sin horas de pronóstico", r.Name)
+		}
+	}
+	f.mu.Lock()
+	f.rules = append([]Rule(nil), rules...)
+	f.mu.Unlock()
+	return nil
+}
+
+// Evaluate consulta el pronóstico de todas las zonas y actualiza las
+// pre-alertas: crea las nuevas, actualiza inicio y valor de las que siguen y
+// retira las que el pronóstico ya no sostiene. Si el pronóstico de una zona
+// no está disponible se conservan sus pre-alertas hasta que caduquen.
+func (f *Forecaster) Evaluate(now time.Time) {
+	zones, err := f.zones()
+	if err != nil {
+		log.Println("warning: meteo: cannot list zones:", err)
+		return
+	}
+	f.mu.Lock()
+	rules := f.rules
+	f.mu.Unlock()
+
+	for _, z := range zones {
+		c := f.locator.get(z)
+		if c.loc == "" {
+			continue
+		}
+		forecast, err := f.client.Forecast(c.loc)
+		if err != nil {
+			log.Printf("warning: meteo: forecast for %s: %v", z.Name, err)
+			continue
+		}
+		for _, r := range rules {
+			key := z.Name + "|" + r.Name
+			p, ok := preAlertFor(r, forecast, now)
+			f.mu.Lock()
+			prev, had := f.active[key]
+			switch {
+			case !ok:
+				delete(f.active, key)
+			case had:
+				p.ID, p.Issued = prev.ID, prev.Issued
+			default:
+				p.ID, p.Issued = processing.NewID(), now
+			}
+			if ok {
+				p.Zone, p.Lat, p.Lon = z.Name, c.lat, c.lon
+				f.active[key] = p
+			}
+			f.mu.Unlock()
+		}
+	}
+}
+
+// preAlertFor evalúa r sobre las horas de pronóstico en [now, now+Hours). La
+// lluvia se acumula y el inicio es la primera hora con lluvia apreciable;
+// para las demás variables cuenta el pico y el inicio es la primera hora que
+// alcanza el umbral. Expira al terminar la última hora que aporta al evento.
+func preAlertFor(r Rule, forecast []weather.Conditions, now time.Time) (PreAlert, bool) {
+	from := now.Truncate(time.Hour)
+	until := now.Add(time.Duration(r.Hours) * time.Hour)
+	p := PreAlert{Kind: KindPreAlert, Type: r.Type, Severity: r.Severity, Rule: r.Name, Threshold: r.Threshold}
+	var peak float64
+	for _, c := range forecast {
+		if c.Time.Before(from) {
+			continue
+		}
+		if !c.Time.Before(until) {
+			break
+		}
+		v := value(c, r.Variable)
+		counts := v >= r.Threshold
+		if r.Variable == VarRain {
+			p.Value += v
+			counts = v >= minRainMM
+		} else if v > p.Value {
+			p.Value = v
+		}
+		if v > peak || p.Peak.IsZero() {
+			peak, p.Peak = v, c.Time
+		}
+		if counts {
+			if p.Onset.IsZero() {
+				p.Onset = c.Time
+			}
+			p.Expires = c.Time.Add(time.Hour)
+		}
+	}
+	if p.Value < r.Threshold || p.Onset.IsZero() {
+		return PreAlert{}, false
+	}
+	unit := unitOf[r.Variable]
+	if r.Variable == VarRain {
+		p.Message = fmt.Sprintf("Pre-alerta: %.0f %s de lluvia previstos en las próximas %d h desde las %s", p.Value, unit, r.Hours, p.Onset.Local().Format("15:04 del 02/01"))
+	} else {
+		p.Message = fmt.Sprintf("Pre-alerta: rachas de hasta %.0f %s previstas desde las %s", p.Value, unit, p.Onset.Local().Format("15:04 del 02/01"))
+	}
+	return p, true
+}
+
+// Active devuelve las pre-alertas no caducadas, por inicio previsto. zone
+// filtra ("" = todas). Las caducadas se descartan.
+func (f *Forecaster) Active(zone string, now time.Time) []PreAlert {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	out := make([]PreAlert, 0, len(f.active))
+	for key, p := range f.active {
+		if !now.Before(p.Expires) {
+			delete(f.active, key)
+			continue
+		}
+		if zone == "" || p.Zone == zone {
+			out = append(out, p)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if !out[i].Onset.Equal(out[j].Onset) {
+			return out[i].Onset.Before(out[j].Onset)
+		}
+		return out[i].ID < out[j].ID
+	})
+	return out
+}
+
+// ServeHTTP atiende GET /api/prealerts (?zona= filtra).
+func (f *Forecaster) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(f.Active(r.URL.Query().Get("zona"), time.Now())); err != nil {
+		log.Println("error serializando pre-alertas:", err)
+	}
+}
+
+// Run ejecuta Evaluate de inmediato y luego cada every hasta que ctx se cancela.
+func (f *Forecaster) Run(ctx context.Context, every time.Duration) {
+	f.Evaluate(time.Now())
+	t := time.NewTicker(every)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-t.C:
+			f.Evaluate(now)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package meteo
+
+import (
+	"testing"
+	"time"
+
+	"alerta_climatica/internal/integrations/weather"
+	"alerta_climatica/internal/storage"
+)
+
+func TestForecasterPreAlerts(t *testing.T) {
+	now := time.Date(2025, 3, 14, 18, 0, 0, 0, time.UTC)
+	var forecast []weather.Conditions
+	for h := 0; h < 72; h++ {
+		c := weather.Conditions{Time: now.Add(time.Duration(h) * time.Hour), WindKPH: 20}
+		if h >= 2 && h < 12 {
+			c.RainMM = 4 // 40 mm entre las 20:00 y las 06:00
+		}
+		if h == 30 {
+			c.WindKPH = 85
+		}
+		forecast = append(forecast, c)
+	}
+	client := &fakeClient{forecast: forecast}
+	zones := []storage.Zone{{Name: "Zona Sur", Geom: `{"type":"Point","coordinates":[-77.05,-12.06]}`}}
+	f := NewForecaster(client, func() ([]storage.Zone, error) { return zones, nil })
+
+	f.Evaluate(now)
+	got := f.Active("", now)
+	if len(got) != 2 {
+		t.Fatalf("expected rain 24h and wind pre-alerts, got %+v", got)
+	}
+	rain, wind := got[0], got[1]
+	if rain.Rule != "lluvia-24h" || rain.Value != 40 || !rain.Onset.Equal(now.Add(2*time.Hour)) || !rain.Expires.Equal(now.Add(12*time.Hour)) {
+		t.Fatalf("unexpected rain pre-alert %+v", rain)
+	}
+	if rain.Kind != KindPreAlert || rain.Zone != "Zona Sur" || rain.Lat != -12.06 || rain.Lon != -77.05 {
+		t.Fatalf("unexpected rain pre-alert metadata %+v", rain)
+	}
+	if wind.Rule != "viento-48h" || wind.Value != 85 || !wind.Onset.Equal(now.Add(30*time.Hour)) || !wind.Expires.Equal(now.Add(31*time.Hour)) {
+		t.Fatalf("unexpected wind pre-alert %+v", wind)
+	}
+
+	// Reevaluar con el mismo pronóstico conserva identificador y emisión.
+	later := now.Add(time.Hour)
+	f.Evaluate(later)
+	if again := f.Active("", later); len(again) != 2 || again[0].ID != rain.ID || !again[0].Issued.Equal(now) {
+		t.Fatalf("expected stable pre-alerts, got %+v", again)
+	}
+	if other := f.Active("Zona Norte", later); len(other) != 0 {
+		t.Fatalf("expected zone filter, got %+v", other)
+	}
+
+	// Pasada la ventana de lluvia solo queda la de viento.
+	if left := f.Active("", now.Add(12*time.Hour)); len(left) != 1 || left[0].Rule != "viento-48h" {
+		t.Fatalf("expected rain pre-alert to expire, got %+v", left)
+	}
+
+	// Si el pronóstico deja de sostenerla, la pre-alerta se retira.
+	client.forecast = nil
+	f.Evaluate(later)
+	if left := f.Active("", later); len(left) != 0 {
+		t.Fatalf("expected pre-alerts withdrawn, got %+v", left)
+	}
+}
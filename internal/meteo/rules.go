@@ -0,0 +1,138 @@
+package meteo
+
+import (
+	"fmt"
+	"time"
+
+	"alerta_climatica/internal/integrations/weather"
+)
+
+// Variables meteorológicas que pueden evaluar las reglas.
+const (
+	VarRain        = "lluvia_mm"
+	VarWind        = "viento_kph"
+	VarTemperature = "temperatura_c"
+	VarHumidity    = "humedad_pct"
+)
+
+// Rule es un umbral sobre una variable. Con Hours 0 se evalúan las
+// condiciones actuales y la alerta tiene fuente "sensor"; la lluvia, que es
+// un umbral por hora, se toma de la hora en curso de la serie horaria y no
+// del intervalo de 15 minutos de las condiciones actuales. Con Hours > 0 se
+// evalúan las próximas Hours horas de pronóstico (la lluvia se acumula, las
+// demás variables toman el máximo) y la fuente es "pronóstico".
+type Rule struct {
+	Name      string  `json:"nombre"`
+	Variable  string  `json:"variable"`
+	Threshold float64 `json:"umbral"`
+	Hours     int     `json:"horas"`
+	Type      string  `json:"fenomeno"`
+	Severity  string  `json:"severidad"`
+}
+
+// DefaultRules son umbrales de referencia para lluvia y viento.
+func DefaultRules() []Rule {
+	return []Rule{
+		{Name: "lluvia-intensa", Variable: VarRain, Threshold: 10, Type: "lluvia", Severity: "alta"},
+		{Name: "lluvia-acumulada-3h", Variable: VarRain, Threshold: 30, Hours: 3, Type: "lluvia", Severity: "alta"},
+		{Name: "viento-fuerte", Variable: VarWind, Threshold: 60, Type: "viento", Severity: "media"},
+		{Name: "viento-muy-fuerte-6h", Variable: VarWind, Threshold: 90, Hours: 6, Type: "viento", Severity: "alta"},
+	}
+}
+
+// Validate revisa que la regla tenga nombre, variable conocida y horas válidas.
+func (r Rule) Validate() error {
+	switch {
+	case r.Name == "":
+		return fmt.Errorf("meteo: regla sin nombre")
+	case r.Type == "" || r.Severity == "":
+		return fmt.Errorf("meteo: regla %q sin fenomeno o severidad", r.Name)
+	case r.Hours < 0 || r.Hours > maxForecastHours:
+		return fmt.Errorf("meteo: regla %q: horas debe estar entre 0 y %d", r.Name, maxForecastHours)
+	}
+	if _, ok := unitOf[r.Variable]; !ok {
+		return fmt.Errorf("meteo: regla %q: variable desconocida %q", r.Name, r.Variable)
+	}
+	return nil
+}
+
+// maxForecastHours es el horizonte del pronóstico que trae el adaptador.
+const maxForecastHours = 72
+
+var unitOf = map[string]string{VarRain: "mm", VarWind: "km/h", VarTemperature: "°C", VarHumidity: "%"}
+
+func value(c weather.Conditions, variable string) float64 {
+	switch variable {
+	case VarRain:
+		return c.RainMM
+	case VarWind:
+		return c.WindKPH
+	case VarTemperature:
+		return c.TemperatureC
+	case VarHumidity:
+		return c.HumidityPct
+	}
+	return 0
+}
+
+// needsForecast indica si evaluar la regla requiere la serie horaria.
+func (r Rule) needsForecast() bool {
+	return r.Hours > 0 || r.Variable == VarRain
+}
+
+// hourlyRain devuelve la lluvia de la hora que contiene now según la serie
+// horaria, o false si la serie no la trae.
+func hourlyRain(forecast []weather.Conditions, now time.Time) (float64, bool) {
+	for _, c := range forecast {
+		if !c.Time.After(now) && now.Before(c.Time.Add(time.Hour)) {
+			return c.RainMM, true
+		}
+	}
+	return 0, false
+}
+
+// evaluate devuelve el valor observado o pronosticado para la regla y si
+// alcanza el umbral. forecast debe empezar en la hora en curso.
+func (r Rule) evaluate(current weather.Conditions, forecast []weather.Conditions, now time.Time) (float64, bool) {
+	if r.Hours == 0 && r.Variable == VarRain {
+		v, ok := hourlyRain(forecast, now)
+		return v, ok && v >= r.Threshold
+	}
+	if r.Hours == 0 {
+		v := value(current, r.Variable)
+		return v, v >= r.Threshold
+	}
+	until := now.Add(time.Duration(r.Hours) * time.Hour)
+	var v float64
+	first := true
+	for _, c := range forecast {
+		if !c.Time.Before(until) {
+			break
+		}
+		x := value(c, r.Variable)
+		switch {
+		case r.Variable == VarRain:
+			v += x
+		case first || x > v:
+			v = x
+		}
+		first = false
+	}
+	return v, !first && v >= r.Threshold
+}
+
+// describe arma el texto de la alerta.
+func (r Rule) describe(v float64) string {
+	unit := unitOf[r.Variable]
+	name := map[string]string{VarRain: "Lluvia", VarWind: "Viento", VarTemperature: "Temperatura", VarHumidity: "Humedad"}[r.Variable]
+	if r.Hours == 0 && r.Variable == VarRain {
+		return fmt.Sprintf("Lluvia medida: %.1f %s en la hora (umbral %.1f %s)", v, unit, r.Threshold, unit)
+	}
+	if r.Hours == 0 {
+		return fmt.Sprintf("%s medida: %.1f %s (umbral %.1f %s)", name, v, unit, r.Threshold, unit)
+	}
+	if r.Variable == VarRain {
+		return fmt.Sprintf("Pronóstico: %.1f %s de lluvia en las próximas %d h (umbral %.1f %s)", v, unit, r.Hours, r.Threshold, unit)
+	}
+	return fmt.Sprintf("Pronóstico: %s hasta %.1f %s en las próximas %d h (umbral %.1f %s)", name, v, unit, r.Hours, r.Threshold, unit)
+}
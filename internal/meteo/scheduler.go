@@ -0,0 +1,176 @@
+// Package meteo consulta periódicamente el tiempo en cada zona y genera
+// alertas automáticas cuando se superan umbrales de lluvia, viento, etc.
+package meteo
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"alerta_climatica/internal/integrations/weather"
+	"alerta_climatica/internal/processing"
+	"alerta_climatica/internal/storage"
+)
+
+// DefaultCooldown es cuánto tiempo una regla que sigue superada no vuelve a
+// generar alerta en la misma zona.
+const DefaultCooldown = 3 * time.Hour
+
+// Las fuentes automáticas se tratan como verificadas: una alerta de sensor o
+// pronóstico escala la zona sin esperar corroboración.
+const automaticTrust = 1
+
+// Scheduler consulta un weather.Client por zona y entrega las alertas por
+// superación de umbrales a emit (normalmente State.AddAlert).
+type Scheduler struct {
+	client weather.Client
+	zones  func() ([]storage.Zone, error)
+	emit   func(processing.Alert)
+
+	locator locator
+
+	mu       sync.Mutex
+	rules    []Rule
+	cooldown time.Duration
+	fired    map[string]time.Time // zona|regla -> última alerta mientras sigue superada
+}
+
+// NewScheduler crea el scheduler con las reglas por defecto. zones devuelve
+// las zonas almacenadas (State.ListStoredZones).
+func NewScheduler(client weather.Client, zones func() ([]storage.Zone, error), emit func(processing.Alert)) *Scheduler {
+	return &Scheduler{
+		client:   client,
+		zones:    zones,
+		emit:     emit,
+		rules:    DefaultRules(),
+		cooldown: DefaultCooldown,
+		fired:    make(map[string]time.Time),
+	}
+}
+
+// SetRules reemplaza las reglas tras validarlas.
+func (s *Scheduler) SetRules(rules []Rule) error {
+	for _, r := range rules {
+		if err := r.Validate(); err != nil {
+			return err
+		}
+	}
+	s.mu.Lock()
+	s.rules = append([]Rule(nil), rules...)
+	s.mu.Unlock()
+	return nil
+}
+
+// Rules devuelve las reglas vigentes.
+func (s *Scheduler) Rules() []Rule {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Rule(nil), s.rules...)
+}
+
+// SetCooldown cambia cada cuánto puede repetirse la alerta de una regla que sigue superada.
+func (s *Scheduler) SetCooldown(d time.Duration) {
+	s.mu.Lock()
+	s.cooldown = d
+	s.mu.Unlock()
+}
+
+// Poll consulta todas las zonas y devuelve cuántas alertas generó. Una regla
+// alerta al superarse y no vuelve a hacerlo en esa zona mientras siga
+// superada, salvo que pase el cooldown; al normalizarse queda rearmada.
+func (s *Scheduler) Poll(now time.Time) int {
+	zones, err := s.zones()
+	if err != nil {
+		log.Println("warning: meteo: cannot list zones:", err)
+		return 0
+	}
+	s.mu.Lock()
+	rules := s.rules
+	cooldown := s.cooldown
+	s.mu.Unlock()
+
+	emitted := 0
+	for _, z := range zones {
+		loc, ok := s.locator.location(z)
+		if !ok {
+			continue
+		}
+		current, err := s.client.CurrentConditions(loc)
+		if err != nil {
+			log.Printf("warning: meteo: conditions for %s: %v", z.Name, err)
+			continue
+		}
+		var forecast []weather.Conditions
+		var forecastErr error
+		for _, r := range rules {
+			if r.needsForecast() {
+				if forecast, forecastErr = s.client.Forecast(loc); forecastErr != nil {
+					log.Printf("warning: meteo: forecast for %s: %v", z.Name, forecastErr)
+				}
+				break
+			}
+		}
+		for _, r := range rules {
+			if r.needsForecast() && forecastErr != nil {
+				continue // sin pronóstico no se sabe si la regla se normalizó
+			}
+			v, breached := r.evaluate(current, forecast, now)
+			if !s.shouldFire(z.Name+"|"+r.Name, breached, now, cooldown) {
+				continue
+			}
+			s.emit(alertFor(z.Name, r, v, now))
+			emitted++
+		}
+	}
+	return emitted
+}
+
+// shouldFire aplica la deduplicación por zona y regla.
+func (s *Scheduler) shouldFire(key string, breached bool, now time.Time, cooldown time.Duration) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !breached {
+		delete(s.fired, key)
+		return false
+	}
+	if last, ok := s.fired[key]; ok && now.Sub(last) < cooldown {
+		return false
+	}
+	s.fired[key] = now
+	return true
+}
+
+func alertFor(zone string, r Rule, v float64, now time.Time) processing.Alert {
+	source := processing.SourceSensor
+	if r.Hours > 0 {
+		source = processing.SourceForecast
+	}
+	return processing.Alert{
+		ID:            processing.NewID(),
+		Zone:          zone,
+		Type:          r.Type,
+		Severity:      r.Severity,
+		Message:       r.describe(v),
+		Extract:       r.Name,
+		Channel:       "meteo",
+		ReporterTrust: automaticTrust,
+		Source:        source,
+		Timestamp:     now,
+	}
+}
+
+// Run ejecuta Poll de inmediato y luego cada every hasta que ctx se cancela.
+func (s *Scheduler) Run(ctx context.Context, every time.Duration) {
+	s.Poll(time.Now())
+	t := time.NewTicker(every)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-t.C:
+			s.Poll(now)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package meteo
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"alerta_climatica/internal/integrations/weather"
+	"alerta_climatica/internal/processing"
+	"alerta_climatica/internal/storage"
+)
+
+// fakeClient devuelve las mismas condiciones para toda ubicación y recuerda la última consultada.
+type fakeClient struct {
+	current  weather.Conditions
+	forecast []weather.Conditions
+	lastLoc  string
+}
+
+func (f *fakeClient) CurrentConditions(loc string) (weather.Conditions, error) {
+	f.lastLoc = loc
+	return f.current, nil
+}
+
+func (f *fakeClient) Forecast(loc string) ([]weather.Conditions, error) {
+	return f.forecast, nil
+}
+
+func TestCentroid(t *testing.T) {
+	cases := []struct {
+		geom     string
+		lat, lon float64
+	}{
+		{`{"type":"Point","coordinates":[-77.05,-12.06]}`, -12.06, -77.05},
+		{`{"type":"Polygon","coordinates":[[[-77,-12],[-76,-12],[-76,-11],[-77,-11],[-77,-12]]]}`, -11.5, -76.5},
+		{`{"type":"MultiPolygon","coordinates":[[[[0,0],[2,0],[2,2],[0,2],[0,0]]],[[[4,0],[5,0],[5,1],[4,1],[4,0]]]]}`, 0.9, 1.7},
+	}
+	for _, c := range cases {
+		lat, lon, err := Centroid(c.geom)
+		if err != nil || math.Abs(lat-c.lat) > 1e-9 || math.Abs(lon-c.lon) > 1e-9 {
+			t.Errorf("Centroid(%s) = %v, %v, %v; want %v, %v", c.geom, lat, lon, err, c.lat, c.lon)
+		}
+	}
+	if _, _, err := Centroid(`null`); err == nil {
+		t.Error("expected error for null geometry")
+	}
+}
+
+func TestSchedulerFiresOncePerBreach(t *testing.T) {
+	now := time.Date(2025, 3, 14, 18, 0, 0, 0, time.UTC)
+	client := &fakeClient{
+		// La lluvia actual es la de 15 minutos; la regla horaria usa la serie.
+		current: weather.Conditions{Time: now, RainMM: 3, WindKPH: 20},
+		forecast: []weather.Conditions{
+			{Time: now, RainMM: 12}, {Time: now.Add(time.Hour), RainMM: 12}, {Time: now.Add(2 * time.Hour), RainMM: 8},
+			{Time: now.Add(3 * time.Hour), RainMM: 40},
+		},
+	}
+	zones := []storage.Zone{
+		{Name: "Zona Sur", Geom: `{"type":"Point","coordinates":[-77.05,-12.06]}`},
+		{Name: "Sin mapa", Geom: `null`},
+	}
+	var got []processing.Alert
+	s := NewScheduler(client, func() ([]storage.Zone, error) { return zones, nil }, func(a processing.Alert) { got = append(got, a) })
+
+	if n := s.Poll(now); n != 2 {
+		t.Fatalf("expected rain sensor and 3h forecast alerts, got %d: %+v", n, got)
+	}
+	if client.lastLoc != "-12.0600,-77.0500" {
+		t.Fatalf("unexpected location %q", client.lastLoc)
+	}
+	sensor, forecast := got[0], got[1]
+	if sensor.Source != processing.SourceSensor || sensor.Type != "lluvia" || sensor.Zone != "Zona Sur" || sensor.ReporterTrust != 1 ||
+		sensor.Message != "Lluvia medida: 12.0 mm en la hora (umbral 10.0 mm)" {
+		t.Fatalf("unexpected sensor alert %+v", sensor)
+	}
+	if forecast.Source != processing.SourceForecast || forecast.Message != "Pronóstico: 32.0 mm de lluvia en las próximas 3 h (umbral 30.0 mm)" {
+		t.Fatalf("unexpected forecast alert %+v", forecast)
+	}
+
+	// Sigue lloviendo: no se repite hasta el cooldown.
+	if n := s.Poll(now.Add(15 * time.Minute)); n != 0 {
+		t.Fatalf("expected no repeated alerts, got %d", n)
+	}
+	if n := s.Poll(now.Add(DefaultCooldown)); n != 2 {
+		t.Fatalf("expected alerts again after cooldown, got %d", n)
+	}
+
+	// Al normalizarse la regla queda rearmada.
+	later := now.Add(DefaultCooldown)
+	client.forecast = []weather.Conditions{{Time: later, RainMM: 1}}
+	s.Poll(later.Add(15 * time.Minute))
+	client.forecast = []weather.Conditions{{Time: later, RainMM: 11}}
+	got = nil
+	if n := s.Poll(later.Add(30 * time.Minute)); n != 1 || got[0].Source != processing.SourceSensor {
+		t.Fatalf("expected sensor alert after re-arming, got %d %+v", n, got)
+	}
+}
@@ -0,0 +1,165 @@
+package meteo
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"alerta_climatica/internal/integrations/weather"
+	"alerta_climatica/internal/processing"
+	"alerta_climatica/internal/storage"
+)
+
+// Umbrales con los que las condiciones actuales apoyan un reporte.
+const (
+	supportRainMM  = 1.0  // mm en la hora en curso, según la serie horaria
+	supportWindKPH = 30.0 // km/h de viento medio
+)
+
+// checkTimeout limita cuánto espera Check al proveedor del tiempo. Check corre
+// en el worker del shard: un proveedor lento no debe frenar las demás alertas.
+const checkTimeout = 2 * time.Second
+
+// Validator contrasta los reportes ciudadanos con el tiempo observado en el
+// centroide de su zona.
+type Validator struct {
+	client  weather.Client
+	zones   func() ([]storage.Zone, error)
+	locator locator
+	timeout time.Duration
+
+	mu       sync.Mutex
+	inflight map[string]*lookup // consultas en curso por ubicación
+}
+
+// lookup es una consulta al proveedor compartida por los Check que la esperan.
+// Si vence el plazo sigue en segundo plano y deja el dato en el caché del
+// cliente para los reportes siguientes.
+type lookup struct {
+	done        chan struct{}
+	current     weather.Conditions
+	err         error
+	forecast    []weather.Conditions
+	forecastErr error
+}
+
+// NewValidator crea un validador; zones da las geometrías de las zonas.
+func NewValidator(client weather.Client, zones func() ([]storage.Zone, error)) *Validator {
+	return &Validator{client: client, zones: zones, timeout: checkTimeout, inflight: make(map[string]*lookup)}
+}
+
+// Check devuelve la validación de a y el dato observado en que se basa.
+// Los fenómenos que el tiempo actual no puede confirmar ni desmentir (p. ej.
+// una alerta roja, o un huaico sin lluvia local) quedan sin validación ("").
+func (v *Validator) Check(a processing.Alert) (validation, note string) {
+	switch a.Type {
+	case "lluvia", "viento", "sequía", "desborde", "huaico":
+	default:
+		return "", ""
+	}
+	loc, ok := v.zoneLocation(a.Zone)
+	if !ok {
+		return processing.ValidationNoData, ""
+	}
+	obs, err := v.observe(loc)
+	if err == nil {
+		err = obs.err
+	}
+	if err != nil {
+		log.Printf("warning: meteo: cannot validate alert %s: %v", a.ID, err)
+		return processing.ValidationNoData, ""
+	}
+	c := obs.current
+	if a.Type == "viento" {
+		note = fmt.Sprintf("viento %.0f km/h", c.WindKPH)
+		if c.WindKPH >= supportWindKPH {
+			return processing.ValidationCorroborated, note
+		}
+		return processing.ValidationDoubtful, note
+	}
+
+	// El umbral de lluvia es por hora: las condiciones actuales solo traen
+	// los últimos 15 minutos, así que se usa la hora en curso de la serie.
+	mm, ok := 0.0, false
+	if obs.forecastErr != nil {
+		log.Printf("warning: meteo: cannot get hourly rain: %v", obs.forecastErr)
+	} else {
+		mm, ok = hourlyRain(obs.forecast, c.Time)
+	}
+	if !ok {
+		if a.Type == "lluvia" {
+			return processing.ValidationNoData, ""
+		}
+		return "", ""
+	}
+	rain := mm >= supportRainMM
+	switch a.Type {
+	case "lluvia":
+		note = fmt.Sprintf("lluvia %.1f mm", mm)
+		if rain {
+			return processing.ValidationCorroborated, note
+		}
+		return processing.ValidationDoubtful, note
+	case "sequía":
+		// Una hora seca no confirma una sequía, pero la lluvia la desmiente.
+		if rain {
+			return processing.ValidationDoubtful, fmt.Sprintf("lluvia %.1f mm", mm)
+		}
+	default:
+		// Desbordes y huaicos pueden venir de lluvia aguas arriba: la lluvia
+		// local los apoya, su ausencia no los desmiente.
+		if rain {
+			return processing.ValidationCorroborated, fmt.Sprintf("lluvia %.1f mm", mm)
+		}
+	}
+	return "", ""
+}
+
+// observe consulta condiciones actuales y pronóstico de loc, esperando a lo
+// sumo v.timeout. Reportes simultáneos de la misma ubicación comparten la
+// consulta.
+func (v *Validator) observe(loc string) (*lookup, error) {
+	v.mu.Lock()
+	l, ok := v.inflight[loc]
+	if !ok {
+		l = &lookup{done: make(chan struct{})}
+		v.inflight[loc] = l
+		go func() {
+			l.current, l.err = v.client.CurrentConditions(loc)
+			if l.err == nil {
+				l.forecast, l.forecastErr = v.client.Forecast(loc)
+			}
+			v.mu.Lock()
+			delete(v.inflight, loc)
+			v.mu.Unlock()
+			close(l.done)
+		}()
+	}
+	v.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), v.timeout)
+	defer cancel()
+	select {
+	case <-l.done:
+		return l, nil
+	case <-ctx.Done():
+		return nil, fmt.Errorf("proveedor del tiempo sin respuesta: %w", ctx.Err())
+	}
+}
+
+// zoneLocation busca la zona por nombre entre las almacenadas.
+func (v *Validator) zoneLocation(name string) (string, bool) {
+	zones, err := v.zones()
+	if err != nil {
+		log.Println("warning: meteo: cannot list zones:", err)
+		return "", false
+	}
+	for _, z := range zones {
+		if z.Name == name {
+			return v.locator.location(z)
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,81 @@
+package meteo
+
+import (
+	"testing"
+	"time"
+
+	"alerta_climatica/internal/integrations/weather"
+	"alerta_climatica/internal/processing"
+	"alerta_climatica/internal/storage"
+)
+
+func TestValidatorCheck(t *testing.T) {
+	client := &fakeClient{}
+	zones := []storage.Zone{{Name: "Zona Sur", Geom: `{"type":"Point","coordinates":[-77.05,-12.06]}`}}
+	v := NewValidator(client, func() ([]storage.Zone, error) { return zones, nil })
+
+	// Las condiciones actuales traen la lluvia de 15 minutos (0.5 mm); la
+	// validación usa la de la hora en curso de la serie horaria.
+	now := time.Date(2025, 3, 14, 18, 45, 0, 0, time.UTC)
+	client.current = weather.Conditions{Time: now, RainMM: 0.5}
+	hour := now.Truncate(time.Hour)
+
+	cases := []struct {
+		forecast         []weather.Conditions
+		wind             float64
+		zone, typ        string
+		validation, note string
+	}{
+		{[]weather.Conditions{{Time: hour, RainMM: 0}}, 0, "Zona Sur", "lluvia", processing.ValidationDoubtful, "lluvia 0.0 mm"},
+		{[]weather.Conditions{{Time: hour, RainMM: 4.5}}, 0, "Zona Sur", "lluvia", processing.ValidationCorroborated, "lluvia 4.5 mm"},
+		{nil, 45, "Zona Sur", "viento", processing.ValidationCorroborated, "viento 45 km/h"},
+		{[]weather.Conditions{{Time: hour, RainMM: 3}}, 0, "Zona Sur", "sequía", processing.ValidationDoubtful, "lluvia 3.0 mm"},
+		{[]weather.Conditions{{Time: hour, RainMM: 0}}, 0, "Zona Sur", "sequía", "", ""},
+		{[]weather.Conditions{{Time: hour, RainMM: 0}}, 0, "Zona Sur", "huaico", "", ""},
+		{[]weather.Conditions{{Time: hour, RainMM: 0}}, 0, "Zona Sur", "alerta-roja", "", ""},
+		{[]weather.Conditions{{Time: hour.Add(time.Hour), RainMM: 9}}, 0, "Zona Sur", "lluvia", processing.ValidationNoData, ""},
+		{[]weather.Conditions{{Time: hour, RainMM: 9}}, 0, "Zona Norte", "lluvia", processing.ValidationNoData, ""},
+	}
+	for _, c := range cases {
+		client.forecast = c.forecast
+		client.current.WindKPH = c.wind
+		validation, note := v.Check(processing.Alert{Zone: c.zone, Type: c.typ})
+		if validation != c.validation || note != c.note {
+			t.Errorf("%s in %s with %+v: got %q %q, want %q %q", c.typ, c.zone, c.forecast, validation, note, c.validation, c.note)
+		}
+	}
+}
+
+// slowClient no responde hasta que se cierra release.
+type slowClient struct {
+	fakeClient
+	release chan struct{}
+}
+
+func (s *slowClient) CurrentConditions(loc string) (weather.Conditions, error) {
+	<-s.release
+	return s.fakeClient.CurrentConditions(loc)
+}
+
+func TestValidatorCheckTimesOut(t *testing.T) {
+	client := &slowClient{release: make(chan struct{})}
+	zones := []storage.Zone{{Name: "Zona Sur", Geom: `{"type":"Point","coordinates":[-77.05,-12.06]}`}}
+	v := NewValidator(client, func() ([]storage.Zone, error) { return zones, nil })
+	v.timeout = 20 * time.Millisecond
+
+	start := time.Now()
+	if validation, _ := v.Check(processing.Alert{Zone: "Zona Sur", Type: "viento"}); validation != processing.ValidationNoData {
+		t.Fatalf("slow provider: got %q, want %q", validation, processing.ValidationNoData)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("Check waited %v for a slow provider", elapsed)
+	}
+
+	// La consulta sigue en curso; al responder, el siguiente reporte la usa.
+	client.current = weather.Conditions{WindKPH: 45}
+	close(client.release)
+	v.timeout = time.Second
+	if validation, note := v.Check(processing.Alert{Zone: "Zona Sur", Type: "viento"}); validation != processing.ValidationCorroborated || note != "viento 45 km/h" {
+		t.Fatalf("after release: got %q %q", validation, note)
+	}
+}
@@ -0,0 +1,158 @@
+package notify
+
+import (
+	"log"
+	"sort"
+	"strings"
+
+	"alerta_climatica/internal/integrations/sms"
+	"alerta_climatica/internal/processing"
+	"alerta_climatica/internal/storage"
+)
+
+// Commands atiende los mensajes de palabra clave con los que los vecinos
+// gestionan su suscripción por SMS:
+//
+//	ALTA <zona>   suscribe el número a la zona
+//	BAJA <zona>   anula la suscripción a esa zona
+//	BAJA          anula todas las suscripciones del número
+//	ZONAS         lista las zonas suscritas y las disponibles
+//
+// Se registra como interceptor del Processor para que estos mensajes no
+// lleguen a detect() ni generen alertas.
+type Commands struct {
+	store  storage.SubscriptionStore
+	sender sms.Sender
+	zones  func() []string // zonas válidas para ALTA
+}
+
+// NewCommands crea el intérprete de comandos. zones devuelve los nombres de
+// zona aceptados.
+func NewCommands(store storage.SubscriptionStore, sender sms.Sender, zones func() []string) *Commands {
+	return &Commands{store: store, sender: sender, zones: zones}
+}
+
+// Handle procesa msg si es un comando y responde al remitente. Devuelve false
+// (el mensaje sigue su curso normal) si no es un comando o no es un SMS de un
+// remitente verificado: un número que llega por la web o por un gateway sin
+// autenticación podría ser ajeno, y el comando suscribiría o daría de baja a
+// un tercero.
+// Un texto como "Baja mucha agua del cerro" no es comando: ALTA/BAJA solo se
+// aceptan solos o seguidos de un nombre de zona conocido.
+func (c *Commands) Handle(msg processing.IncomingMessage) bool {
+	keyword, zone, ok := c.parse(msg)
+	if !ok {
+		return false
+	}
+	var reply string
+	switch keyword {
+	case "alta":
+		reply = c.subscribe(msg.From, zone)
+	case "baja":
+		reply = c.unsubscribe(msg.From, zone)
+	case "zonas":
+		reply = c.list(msg.From)
+	}
+	if err := c.sender.Send(msg.From, reply); err != nil {
+		log.Printf("notify: cannot reply to %s: %v", msg.From, err)
+	}
+	return true
+}
+
+// IsCommand indica si Handle atendería msg, sin ejecutarlo. El Processor lo
+// usa para no unir un comando con los SMS vecinos del mismo remitente.
+func (c *Commands) IsCommand(msg processing.IncomingMessage) bool {
+	_, _, ok := c.parse(msg)
+	return ok
+}
+
+// parse reconoce un comando y su zona (vacía si no la lleva).
+func (c *Commands) parse(msg processing.IncomingMessage) (keyword, zone string, ok bool) {
+	if msg.From == "" || msg.Channel != "sms" || !msg.Verified {
+		return "", "", false
+	}
+	fields := strings.Fields(msg.Text)
+	if len(fields) == 0 {
+		return "", "", false
+	}
+	keyword = fold(fields[0])
+	if arg := strings.Join(fields[1:], " "); arg != "" {
+		if zone, ok = c.matchZone(arg); !ok {
+			return "", "", false
+		}
+	}
+	switch keyword {
+	case "alta", "baja":
+		return keyword, zone, true
+	case "zonas":
+		return keyword, "", zone == ""
+	}
+	return "", "", false
+}
+
+func (c *Commands) subscribe(phone, zone string) string {
+	if zone == "" {
+		return "Indique la zona: ALTA <zona>. Envíe ZONAS para ver las disponibles."
+	}
+	if _, err := c.store.AddSubscription(storage.Subscription{Phone: phone, Zone: zone}); err != nil {
+		log.Println("notify: cannot add subscription:", err)
+		return "No pudimos registrar su suscripción. Intente más tarde."
+	}
+	return "Suscrito a alertas de " + zone + ". Para anular envíe BAJA " + zone + "."
+}
+
+// unsubscribe anula la suscripción a zone, o todas si zone es "".
+func (c *Commands) unsubscribe(phone, zone string) string {
+	n, err := c.store.DeleteSubscriptionsByPhone(phone, zone)
+	if err != nil {
+		log.Println("notify: cannot delete subscription:", err)
+		return "No pudimos anular su suscripción. Intente más tarde."
+	}
+	switch {
+	case n == 0 && zone != "":
+		return "No estaba suscrito a " + zone + "."
+	case n == 0:
+		return "No tiene suscripciones activas."
+	case zone != "":
+		return "Suscripción a " + zone + " anulada."
+	default:
+		return "Se anularon todas sus suscripciones."
+	}
+}
+
+func (c *Commands) list(phone string) string {
+	subs, err := c.store.ListSubscriptionsByPhone(phone)
+	if err != nil {
+		log.Println("notify: cannot list subscriptions:", err)
+		return "No pudimos consultar sus suscripciones. Intente más tarde."
+	}
+	mine := make([]string, 0, len(subs))
+	for _, s := range subs {
+		mine = append(mine, s.Zone)
+	}
+	all := c.zones()
+	sort.Strings(all)
+	out := "Sin suscripciones."
+	if len(mine) > 0 {
+		out = "Suscrito a: " + strings.Join(mine, ", ") + "."
+	}
+	return out + " Zonas: " + strings.Join(all, ", ") + ". Envíe ALTA <zona>."
+}
+
+// matchZone busca la zona ignorando mayúsculas y tildes.
+func (c *Commands) matchZone(arg string) (string, bool) {
+	want := fold(arg)
+	for _, z := range c.zones() {
+		if fold(z) == want {
+			return z, true
+		}
+	}
+	return "", false
+}
+
+var accentFolder = strings.NewReplacer("á", "a", "é", "e", "í", "i", "ó", "o", "ú", "u", "ü", "u", "ñ", "n")
+
+// fold normaliza texto para comparar: minúsculas, sin tildes ni espacios extra.
+func fold(s string) string {
+	return strings.Join(strings.Fields(accentFolder.Replace(strings.ToLower(s))), " ")
+}
@@ -0,0 +1,74 @@
+package notify
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"alerta_climatica/internal/processing"
+	"alerta_climatica/internal/storage"
+)
+
+func TestCommandsSubscribeListUnsubscribe(t *testing.T) {
+	store, err := storage.NewSQLite(filepath.Join(t.TempDir(), "cmd.db"))
+	if err != nil {
+		t.Fatalf("NewSQLite failed: %v", err)
+	}
+	defer store.Close()
+
+	sender := &fakeSender{sent: map[string]string{}}
+	cmds := NewCommands(store, sender, func() []string { return []string{"Bellavista", "La Perla"} })
+	const phone = "+51987654321"
+	handle := func(text string) bool {
+		return cmds.Handle(processing.IncomingMessage{Text: text, From: phone, Channel: "sms", Verified: true})
+	}
+
+	if !handle("alta bellavista") {
+		t.Fatal("ALTA should be handled as a command")
+	}
+	if subs, _ := store.ListSubscriptionsByPhone(phone); len(subs) != 1 || subs[0].Zone != "Bellavista" {
+		t.Fatalf("unexpected subscriptions: %+v", subs)
+	}
+	if !strings.HasPrefix(sender.sent[phone], "Suscrito a alertas de Bellavista") {
+		t.Fatalf("unexpected reply: %q", sender.sent[phone])
+	}
+
+	if !handle("ZONAS") || !strings.Contains(sender.sent[phone], "Suscrito a: Bellavista") {
+		t.Fatalf("unexpected ZONAS reply: %q", sender.sent[phone])
+	}
+
+	if !handle("BAJA") {
+		t.Fatal("BAJA should be handled as a command")
+	}
+	if subs, _ := store.ListSubscriptionsByPhone(phone); len(subs) != 0 {
+		t.Fatalf("expected no subscriptions after BAJA, got %+v", subs)
+	}
+}
+
+func TestCommandsIgnoreRegularReports(t *testing.T) {
+	sender := &fakeSender{sent: map[string]string{}}
+	cmds := NewCommands(nil, sender, func() []string { return []string{"Bellavista"} })
+
+	for _, text := range []string{
+		"Baja mucha agua del cerro",
+		"Alta crecida del río",
+		"Lluvia intensa en Bellavista",
+	} {
+		if cmds.Handle(processing.IncomingMessage{Text: text, From: "+51987654321", Channel: "sms", Verified: true}) {
+			t.Errorf("%q should not be treated as a command", text)
+		}
+	}
+	if cmds.Handle(processing.IncomingMessage{Text: "ALTA Bellavista", Channel: "sms", Verified: true}) {
+		t.Error("commands without sender should pass through")
+	}
+	// Un número no verificado (web o gateway sin autenticación) podría ser ajeno.
+	if cmds.Handle(processing.IncomingMessage{Text: "ALTA Bellavista", From: "+51987654321", Channel: "web"}) {
+		t.Error("commands from the web should pass through")
+	}
+	if cmds.Handle(processing.IncomingMessage{Text: "BAJA", From: "+51987654321", Channel: "sms"}) {
+		t.Error("commands from an unverified gateway should pass through")
+	}
+	if len(sender.sent) != 0 {
+		t.Fatalf("no replies expected, got %v", sender.sent)
+	}
+}
@@ -0,0 +1,152 @@
+// Package notify difunde por SMS los escalamientos de zona a los vecinos suscritos.
+package notify
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+	"sync"
+	"time"
+
+	"alerta_climatica/internal/integrations/sms"
+	"alerta_climatica/internal/processing"
+	"alerta_climatica/internal/storage"
+)
+
+// Escalated indica si el paso de prev a cur es un escalamiento que amerita difusión.
+func Escalated(prev, cur string) bool {
+	return (cur == "amarillo" || cur == "rojo") && processing.StatusRank(cur) > processing.StatusRank(prev)
+}
+
+// campaign es una difusión pendiente por un escalamiento de zona.
+type campaign struct {
+	id   string
+	data TemplateData
+}
+
+// Dispatcher envía, en segundo plano, un SMS a cada suscriptor de una zona
+// cuando ésta escala. Cada envío pasa por la bandeja de salida (deliveries):
+// los fallidos se reintentan con backoff exponencial (ver RetryDue).
+type Dispatcher struct {
+	store  storage.OutboxStore
+	sender sms.Sender
+	tpl    *Renderer
+	jobs   chan campaign
+	wg     sync.WaitGroup
+	sendMu sync.Mutex // serializa difusiones y reintentos sobre la bandeja
+
+	mu     sync.Mutex // protege closed y el envío a jobs frente a Close
+	closed bool
+}
+
+// NewDispatcher crea el dispatcher, que arma los SMS con las plantillas de
+// tpl, y lanza su worker de envíos.
+func NewDispatcher(store storage.OutboxStore, sender sms.Sender, tpl *Renderer) *Dispatcher {
+	d := &Dispatcher{store: store, sender: sender, tpl: tpl, jobs: make(chan campaign, 32)}
+	d.wg.Add(1)
+	go d.run()
+	return d
+}
+
+// ZoneChanged recibe un cambio de estado de zona; si es un escalamiento encola
+// una campaña de difusión y devuelve su id ("" si no hay difusión). Corre en
+// el worker que procesó la alerta, así que nunca bloquea: con la cola llena o
+// el dispatcher cerrado la campaña se descarta y se registra en el log.
+func (d *Dispatcher) ZoneChanged(zone, prev, cur string, a processing.Alert) string {
+	if !Escalated(prev, cur) {
+		return ""
+	}
+	c := campaign{
+		id: newCampaignID(),
+		data: TemplateData{
+			Zona:      zone,
+			Estado:    cur,
+			Fenomeno:  a.Type,
+			Severidad: a.Severity,
+			Hora:      a.Timestamp,
+		},
+	}
+	if c.data.Hora.IsZero() {
+		c.data.Hora = time.Now()
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closed {
+		log.Printf("warning: notify: dispatcher closed, campaign for %s %s dropped", zone, cur)
+		return ""
+	}
+	select {
+	case d.jobs <- c:
+		return c.id
+	default:
+		log.Printf("warning: notify: campaign queue full, campaign for %s %s dropped", zone, cur)
+		return ""
+	}
+}
+
+// Close deja de aceptar campañas y espera a que terminen los envíos en curso.
+func (d *Dispatcher) Close() {
+	d.mu.Lock()
+	if !d.closed {
+		d.closed = true
+		close(d.jobs)
+	}
+	d.mu.Unlock()
+	d.wg.Wait()
+}
+
+func (d *Dispatcher) run() {
+	defer d.wg.Done()
+	for c := range d.jobs {
+		d.broadcast(c)
+	}
+}
+
+// broadcast encola un envío por suscriptor de la zona y hace el primer intento.
+func (d *Dispatcher) broadcast(c campaign) {
+	text, err := d.tpl.Render(c.data)
+	if err != nil {
+		log.Println("notify: cannot render template:", err)
+		return
+	}
+	if fit, cut := fitSegments(text); cut {
+		log.Printf("warning: notify: broadcast for %s exceeds %d SMS segments, truncated", c.data.Zona, MaxSegments)
+		text = fit
+	}
+	subs, err := d.store.ListSubscriptions(c.data.Zona)
+	if err != nil {
+		log.Println("notify: cannot list subscriptions:", err)
+		return
+	}
+	d.sendMu.Lock()
+	defer d.sendMu.Unlock()
+	sent := 0
+	now := time.Now()
+	for _, sub := range subs {
+		rec := storage.Delivery{
+			Campaign:    c.id,
+			Phone:       sub.Phone,
+			Zone:        c.data.Zona,
+			Message:     text,
+			Status:      storage.DeliveryQueued,
+			NextAttempt: now,
+			CreatedAt:   now,
+		}
+		id, err := d.store.SaveDelivery(rec)
+		if err != nil {
+			// Sin registro no hay reintento posible; se envía igual.
+			log.Println("notify: cannot save delivery:", err)
+		}
+		rec.ID = id
+		if d.attempt(&rec, now) {
+			sent++
+		}
+	}
+	log.Printf("notify: campaign %s (%s %s) sent %d/%d", c.id, c.data.Zona, c.data.Estado, sent, len(subs))
+}
+
+func newCampaignID() string {
+	b := make([]byte, 6)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,229 @@
+package notify
+
+import (
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"alerta_climatica/internal/processing"
+	"alerta_climatica/internal/storage"
+)
+
+// fakeSender registra los envíos y falla para los números en fail.
+type fakeSender struct {
+	mu   sync.Mutex
+	sent map[string]string
+	fail map[string]bool
+}
+
+func (f *fakeSender) Send(to, message string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.fail[to] {
+		return errors.New("número inválido")
+	}
+	f.sent[to] = message
+	return nil
+}
+
+func TestDispatcherBroadcastsOnEscalation(t *testing.T) {
+	store, err := storage.NewSQLite(filepath.Join(t.TempDir(), "notify.db"))
+	if err != nil {
+		t.Fatalf("NewSQLite failed: %v", err)
+	}
+	defer store.Close()
+	for _, sub := range []storage.Subscription{
+		{Phone: "+51911111111", Zone: "Bellavista"},
+		{Phone: "+51922222222", Zone: "Bellavista"},
+		{Phone: "+51933333333", Zone: "La Perla"},
+	} {
+		if _, err := store.AddSubscription(sub); err != nil {
+			t.Fatalf("AddSubscription failed: %v", err)
+		}
+	}
+
+	sender := &fakeSender{sent: map[string]string{}, fail: map[string]bool{"+51922222222": true}}
+	d := NewDispatcher(store, sender, NewRenderer())
+
+	a := processing.Alert{Zone: "Bellavista", Type: "desborde", Severity: "alta", Timestamp: time.Now()}
+	if id := d.ZoneChanged("Bellavista", "amarillo", "verde", a); id != "" {
+		t.Fatalf("de-escalation should not broadcast, got campaign %s", id)
+	}
+	campaign := d.ZoneChanged("Bellavista", "verde", "amarillo", a)
+	if campaign == "" {
+		t.Fatal("expected a campaign on escalation")
+	}
+	d.Close()
+
+	if len(sender.sent) != 1 || !strings.HasPrefix(sender.sent["+51911111111"], "ALERTA AMARILLA Bellavista: desborde") {
+		t.Fatalf("unexpected sends: %v", sender.sent)
+	}
+	recs, err := store.ListDeliveries(campaign, "")
+	if err != nil {
+		t.Fatalf("ListDeliveries failed: %v", err)
+	}
+	status := map[string]string{}
+	for _, r := range recs {
+		status[r.Phone] = r.Status
+	}
+	if len(recs) != 2 || status["+51911111111"] != storage.DeliverySent || status["+51922222222"] != storage.DeliveryRetrying {
+		t.Fatalf("unexpected delivery records: %+v", recs)
+	}
+}
+
+// TestDispatcherRetriesWithBackoff verifica que un envío fallido se reintenta
+// solo al vencer su backoff y queda fallido al agotar los intentos.
+func TestDispatcherRetriesWithBackoff(t *testing.T) {
+	store, err := storage.NewSQLite(filepath.Join(t.TempDir(), "outbox.db"))
+	if err != nil {
+		t.Fatalf("NewSQLite failed: %v", err)
+	}
+	defer store.Close()
+	for _, phone := range []string{"+51911111111", "+51922222222"} {
+		if _, err := store.AddSubscription(storage.Subscription{Phone: phone, Zone: "Bellavista"}); err != nil {
+			t.Fatalf("AddSubscription failed: %v", err)
+		}
+	}
+	sender := &fakeSender{sent: map[string]string{}, fail: map[string]bool{"+51911111111": true, "+51922222222": true}}
+	d := NewDispatcher(store, sender, NewRenderer())
+	a := processing.Alert{Zone: "Bellavista", Type: "desborde", Severity: "alta", Timestamp: time.Now()}
+	campaign := d.ZoneChanged("Bellavista", "verde", "rojo", a)
+	d.Close()
+
+	now := time.Now()
+	if n := d.RetryDue(now); n != 0 {
+		t.Fatalf("retry before backoff sent %d", n)
+	}
+	sender.fail["+51922222222"] = false
+	if n := d.RetryDue(now.Add(retryBackoff(1))); n != 1 {
+		t.Fatalf("expected 1 retry accepted, got %d", n)
+	}
+	later := now
+	for i := 2; i <= retryMaxAttempts; i++ {
+		later = later.Add(retryMaxDelay)
+		d.RetryDue(later)
+	}
+
+	recs, err := store.ListDeliveries(campaign, "")
+	if err != nil {
+		t.Fatalf("ListDeliveries failed: %v", err)
+	}
+	byPhone := map[string]storage.Delivery{}
+	for _, r := range recs {
+		byPhone[r.Phone] = r
+	}
+	if r := byPhone["+51922222222"]; r.Status != storage.DeliverySent || r.Attempts != 2 || r.Error != "" {
+		t.Fatalf("unexpected retried delivery %+v", r)
+	}
+	if r := byPhone["+51911111111"]; r.Status != storage.DeliveryFailed || r.Attempts != retryMaxAttempts {
+		t.Fatalf("expected delivery failed after %d attempts, got %+v", retryMaxAttempts, r)
+	}
+
+	if err := store.MarkDelivery(byPhone["+51922222222"].ID, "", storage.DeliveryDelivered, ""); err != nil {
+		t.Fatalf("MarkDelivery failed: %v", err)
+	}
+	stats, err := store.CampaignStats(campaign)
+	if err != nil {
+		t.Fatalf("CampaignStats failed: %v", err)
+	}
+	if len(stats) != 1 || stats[0].Total != 2 || stats[0].Delivered != 1 || stats[0].Failed != 1 {
+		t.Fatalf("unexpected campaign stats %+v", stats)
+	}
+}
+
+// TestReportDeliveryRetriesFailures verifica que un reporte de entrega
+// fallido vuelve a la cola de reintentos mientras queden intentos.
+func TestReportDeliveryRetriesFailures(t *testing.T) {
+	store, err := storage.NewSQLite(filepath.Join(t.TempDir(), "report.db"))
+	if err != nil {
+		t.Fatalf("NewSQLite failed: %v", err)
+	}
+	defer store.Close()
+	id, err := store.SaveDelivery(storage.Delivery{Campaign: "c1", Phone: "+51911111111", Zone: "Bellavista", Status: storage.DeliverySent, Attempts: 1, ProviderID: "SM1"})
+	if err != nil {
+		t.Fatalf("SaveDelivery failed: %v", err)
+	}
+	d := NewDispatcher(store, &fakeSender{sent: map[string]string{}}, NewRenderer())
+	defer d.Close()
+
+	now := time.Now()
+	if err := d.ReportDelivery(0, "SM1", storage.DeliveryFailed, "30003", now); err != nil {
+		t.Fatalf("ReportDelivery failed: %v", err)
+	}
+	rec, _ := store.GetDelivery(id, "")
+	if rec.Status != storage.DeliveryRetrying || rec.Error != "30003" || !rec.NextAttempt.Equal(now.Add(retryBackoff(1)).UTC().Truncate(time.Second)) {
+		t.Fatalf("expected delivery back in the retry queue, got %+v", rec)
+	}
+	// Un "enviado" tardío o el mismo fallo repetido no cambian el reintento programado.
+	for _, st := range []string{storage.DeliverySent, storage.DeliveryFailed} {
+		if err := d.ReportDelivery(0, "SM1", st, "", now.Add(time.Minute)); !errors.Is(err, sql.ErrNoRows) {
+			t.Fatalf("late %s report: got %v", st, err)
+		}
+		if late, _ := store.GetDelivery(id, ""); late.Status != storage.DeliveryRetrying || !late.NextAttempt.Equal(rec.NextAttempt) || late.Error != "30003" {
+			t.Fatalf("late %s report changed the delivery: %+v", st, late)
+		}
+	}
+
+	rec.Status, rec.Attempts = storage.DeliverySent, retryMaxAttempts
+	if err := store.UpdateDelivery(rec); err != nil {
+		t.Fatalf("UpdateDelivery failed: %v", err)
+	}
+	if err := d.ReportDelivery(id, "", storage.DeliveryFailed, "30003", now); err != nil {
+		t.Fatalf("ReportDelivery failed: %v", err)
+	}
+	if rec, _ = store.GetDelivery(id, ""); rec.Status != storage.DeliveryFailed {
+		t.Fatalf("expected delivery failed after the last attempt, got %+v", rec)
+	}
+	if err := store.MarkDelivery(id, "", storage.DeliverySent, ""); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("sent after failed: got %v", err)
+	}
+	if rec, _ = store.GetDelivery(id, ""); rec.Status != storage.DeliveryFailed {
+		t.Fatalf("sent after failed reopened the delivery: %+v", rec)
+	}
+
+	if err := d.ReportDelivery(0, "SM9", storage.DeliveryFailed, "", now); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("unknown delivery: got %v", err)
+	}
+}
+
+// TestDispatcherNeverBlocksTheWorker verifica que ZoneChanged descarta
+// campañas con la cola llena o tras Close en lugar de bloquear o entrar en pánico.
+func TestDispatcherNeverBlocksTheWorker(t *testing.T) {
+	d := &Dispatcher{jobs: make(chan campaign, 1)} // sin worker: la cola no se vacía
+	a := processing.Alert{Zone: "Bellavista", Type: "desborde", Severity: "alta", Timestamp: time.Now()}
+	if id := d.ZoneChanged("Bellavista", "verde", "amarillo", a); id == "" {
+		t.Fatal("expected the first campaign to be queued")
+	}
+	if id := d.ZoneChanged("Bellavista", "amarillo", "rojo", a); id != "" {
+		t.Fatalf("full queue should drop the campaign, got %s", id)
+	}
+	<-d.jobs
+	d.Close()
+	if id := d.ZoneChanged("Bellavista", "verde", "rojo", a); id != "" {
+		t.Fatalf("closed dispatcher should drop the campaign, got %s", id)
+	}
+}
+
+func TestEscalated(t *testing.T) {
+	cases := []struct {
+		prev, cur string
+		want      bool
+	}{
+		{"verde", "amarillo", true},
+		{"amarillo", "rojo", true},
+		{"", "rojo", true},
+		{"pendiente", "amarillo", true},
+		{"amarillo", "pendiente", false},
+		{"rojo", "amarillo", false},
+		{"amarillo", "verde", false},
+	}
+	for _, c := range cases {
+		if got := Escalated(c.prev, c.cur); got != c.want {
+			t.Errorf("Escalated(%q, %q) = %v, want %v", c.prev, c.cur, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package notify
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"time"
+
+	"alerta_climatica/internal/integrations/sms"
+	"alerta_climatica/internal/storage"
+)
+
+// Reintentos de envíos fallidos de la bandeja de salida.
+const (
+	retryBaseDelay   = 30 * time.Second
+	retryMaxDelay    = 30 * time.Minute
+	retryMaxAttempts = 5
+	retryBatch       = 100
+)
+
+// retryBackoff calcula la espera exponencial tras n intentos fallidos.
+func retryBackoff(attempts int) time.Duration {
+	d := retryBaseDelay
+	for i := 1; i < attempts; i++ {
+		d *= 2
+		if d >= retryMaxDelay {
+			return retryMaxDelay
+		}
+	}
+	return d
+}
+
+// attempt envía rec y actualiza su estado: enviado (con el id del proveedor
+// si el Sender lo informa), reintentando con backoff, o fallido al agotar los
+// intentos. Devuelve true si el proveedor aceptó el mensaje. Requiere sendMu.
+func (d *Dispatcher) attempt(rec *storage.Delivery, now time.Time) bool {
+	var providerID string
+	var err error
+	if ids, ok := d.sender.(sms.IDSender); ok {
+		providerID, err = ids.SendWithID(rec.Phone, rec.Message)
+	} else {
+		err = d.sender.Send(rec.Phone, rec.Message)
+	}
+	rec.Attempts++
+	rec.UpdatedAt = now
+	switch {
+	case err == nil:
+		rec.Status = storage.DeliverySent
+		rec.Error = ""
+		rec.ProviderID = providerID
+		rec.NextAttempt = time.Time{}
+	case rec.Attempts >= retryMaxAttempts:
+		rec.Status = storage.DeliveryFailed
+		rec.Error = err.Error()
+		rec.NextAttempt = time.Time{}
+	default:
+		rec.Status = storage.DeliveryRetrying
+		rec.Error = err.Error()
+		rec.NextAttempt = now.Add(retryBackoff(rec.Attempts))
+	}
+	if rec.ID != 0 {
+		if uerr := d.store.UpdateDelivery(*rec); uerr != nil {
+			log.Printf("notify: cannot update delivery %d: %v", rec.ID, uerr)
+		}
+	}
+	return err == nil
+}
+
+// RetryDue reintenta los envíos en cola o fallidos cuyo próximo intento ya
+// venció y devuelve cuántos se aceptaron.
+func (d *Dispatcher) RetryDue(now time.Time) int {
+	d.sendMu.Lock()
+	defer d.sendMu.Unlock()
+	due, err := d.store.DueDeliveries(now, retryBatch)
+	if err != nil {
+		log.Println("notify: cannot list pending deliveries:", err)
+		return 0
+	}
+	sent := 0
+	for i := range due {
+		if d.attempt(&due[i], now) {
+			sent++
+		} else if due[i].Status == storage.DeliveryFailed {
+			log.Printf("notify: delivery %d to %s failed after %d attempts: %s", due[i].ID, due[i].Phone, due[i].Attempts, due[i].Error)
+		}
+	}
+	return sent
+}
+
+// ReportDelivery aplica un reporte de entrega del proveedor al envío con ese
+// id o id de proveedor. Un fallo informado después de aceptado el mensaje se
+// reintenta con el mismo backoff mientras queden intentos; agotados, el envío
+// queda fallido. Devuelve sql.ErrNoRows si no hay envío que actualizar.
+func (d *Dispatcher) ReportDelivery(id int64, providerID, status, errText string, now time.Time) error {
+	if status != storage.DeliveryFailed {
+		return d.store.MarkDelivery(id, providerID, status, errText)
+	}
+	d.sendMu.Lock()
+	defer d.sendMu.Unlock()
+	rec, err := d.store.GetDelivery(id, providerID)
+	if err != nil {
+		return err
+	}
+	if !storage.DeliveryAdvances(rec.Status, status) {
+		return sql.ErrNoRows // igual que MarkDelivery: el reporte llegó tarde o repetido
+	}
+	if rec.Attempts >= retryMaxAttempts {
+		return d.store.MarkDelivery(rec.ID, "", status, errText)
+	}
+	rec.Status = storage.DeliveryRetrying
+	rec.Error = errText
+	rec.NextAttempt = now.Add(retryBackoff(rec.Attempts))
+	rec.UpdatedAt = now
+	return d.store.UpdateDelivery(rec)
+}
+
+// RunRetries ejecuta RetryDue periódicamente hasta que ctx se cancela.
+func (d *Dispatcher) RunRetries(ctx context.Context, every time.Duration) {
+	t := time.NewTicker(every)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-t.C:
+			d.RetryDue(now)
+		}
+	}
+}
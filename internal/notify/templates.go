@@ -0,0 +1,234 @@
+package notify
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"alerta_climatica/internal/integrations/sms"
+)
+
+// TemplateData son los campos disponibles en las plantillas de difusión.
+type TemplateData struct {
+	Zona          string
+	Estado        string // "amarillo" o "rojo"
+	Fenomeno      string // tipo de la alerta que provocó el escalamiento
+	Severidad     string
+	Instrucciones string // qué hacer; vacío usa las de la plantilla
+	Hora          time.Time
+}
+
+// Template es la plantilla de difusión de un color de zona. Texto usa la
+// sintaxis de text/template con los campos de TemplateData ({{.Zona}},
+// {{.Fenomeno}}, {{.Severidad}}, {{.Instrucciones}}, {{.Hora.Format "15:04"}}).
+type Template struct {
+	Estado        string `json:"estado"`
+	Texto         string `json:"texto"`
+	Instrucciones string `json:"instrucciones"`
+}
+
+// MaxSegments es el máximo de segmentos SMS que puede ocupar una difusión.
+const MaxSegments = 2
+
+// DefaultTemplates son las plantillas de fábrica. Textos cortos para caber en un SMS.
+var DefaultTemplates = []Template{
+	{
+		Estado:        "amarillo",
+		Texto:         `ALERTA AMARILLA {{.Zona}}: {{.Fenomeno}} reportado {{.Hora.Format "15:04"}}. {{.Instrucciones}}`,
+		Instrucciones: "Manténgase atento y tenga lista su mochila de emergencia.",
+	},
+	{
+		Estado:        "rojo",
+		Texto:         `ALERTA ROJA {{.Zona}}: {{.Fenomeno}} {{.Hora.Format "15:04"}}. {{.Instrucciones}}`,
+		Instrucciones: "Evacúe hacia zonas altas y siga las indicaciones de las autoridades.",
+	},
+}
+
+// compiled es una plantilla ya parseada.
+type compiled struct {
+	src Template
+	tpl *template.Template
+}
+
+// Renderer guarda las plantillas vigentes por color de zona y si se quitan
+// las tildes que no existen en GSM-7 antes de enviar. Lo comparten el
+// Dispatcher y la API de administración; es seguro para uso concurrente.
+type Renderer struct {
+	mu           sync.RWMutex
+	templates    map[string]compiled
+	stripAccents bool
+}
+
+// NewRenderer crea un Renderer con las plantillas de fábrica.
+func NewRenderer() *Renderer {
+	return &Renderer{templates: mustCompile(DefaultTemplates)}
+}
+
+func mustCompile(list []Template) map[string]compiled {
+	out := make(map[string]compiled, len(list))
+	for _, t := range list {
+		c, err := compile(t)
+		if err != nil {
+			panic(err)
+		}
+		out[t.Estado] = c
+	}
+	return out
+}
+
+func compile(t Template) (compiled, error) {
+	if strings.TrimSpace(t.Texto) == "" {
+		return compiled{}, fmt.Errorf("notify: plantilla %q vacía", t.Estado)
+	}
+	tpl, err := template.New(t.Estado).Parse(t.Texto)
+	if err != nil {
+		return compiled{}, fmt.Errorf("notify: plantilla %q inválida: %w", t.Estado, err)
+	}
+	return compiled{src: t, tpl: tpl}, nil
+}
+
+// sampleData es el ejemplo con el que se valida el largo de una plantilla.
+func sampleData(estado string) TemplateData {
+	return TemplateData{Zona: "Zona Centro", Estado: estado, Fenomeno: "deslizamiento", Severidad: "crítica",
+		Hora: time.Date(2025, 3, 14, 18, 45, 0, 0, time.Local)}
+}
+
+// SetTemplates reemplaza las plantillas de los estados indicados (los demás
+// no cambian). Rechaza el conjunto completo si alguna no compila, es de un
+// estado desconocido o, con datos de ejemplo, ocupa más de MaxSegments.
+func (r *Renderer) SetTemplates(list []Template) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	next := make(map[string]compiled, len(r.templates))
+	for k, v := range r.templates {
+		next[k] = v
+	}
+	for _, t := range list {
+		if !Escalated("verde", t.Estado) {
+			return fmt.Errorf("notify: estado de plantilla desconocido %q", t.Estado)
+		}
+		c, err := compile(t)
+		if err != nil {
+			return err
+		}
+		p, err := preview(c, sampleData(t.Estado), r.stripAccents)
+		if err != nil {
+			return fmt.Errorf("notify: plantilla %q: %w", t.Estado, err)
+		}
+		if p.TooLong {
+			return fmt.Errorf("notify: la plantilla %q ocupa %d segmentos %s (máximo %d)", t.Estado, p.Segments, p.Encoding, MaxSegments)
+		}
+		next[t.Estado] = c
+	}
+	r.templates = next
+	return nil
+}
+
+// Templates devuelve las plantillas vigentes.
+func (r *Renderer) Templates() []Template {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	out := make([]Template, 0, len(r.templates))
+	for _, estado := range []string{"amarillo", "rojo"} {
+		if c, ok := r.templates[estado]; ok {
+			out = append(out, c.src)
+		}
+	}
+	return out
+}
+
+// SetStripAccents activa o desactiva el quitado de tildes fuera de GSM-7
+// (á, í, ó, ú), que evita que un solo carácter pase el SMS a UCS-2.
+func (r *Renderer) SetStripAccents(on bool) {
+	r.mu.Lock()
+	r.stripAccents = on
+	r.mu.Unlock()
+}
+
+// Render genera el texto del SMS para el estado indicado.
+func (r *Renderer) Render(data TemplateData) (string, error) {
+	r.mu.RLock()
+	c, ok := r.templates[data.Estado]
+	strip := r.stripAccents
+	r.mu.RUnlock()
+	if !ok {
+		return "", fmt.Errorf("notify: no hay plantilla para estado %q", data.Estado)
+	}
+	return render(c, data, strip)
+}
+
+func render(c compiled, data TemplateData, strip bool) (string, error) {
+	if data.Fenomeno == "" || data.Fenomeno == "informativo" {
+		data.Fenomeno = "evento"
+	}
+	if data.Instrucciones == "" {
+		data.Instrucciones = c.src.Instrucciones
+	}
+	var b strings.Builder
+	if err := c.tpl.Execute(&b, data); err != nil {
+		return "", err
+	}
+	text := strings.TrimSpace(b.String())
+	if strip {
+		text = sms.StripAccents(text)
+	}
+	return text, nil
+}
+
+// Preview es el texto de una difusión con su codificación y segmentos.
+type Preview struct {
+	Text string `json:"texto"`
+	sms.SegmentInfo
+	TooLong bool `json:"excede"` // ocupa más de MaxSegments
+}
+
+// Preview renderiza data con la plantilla vigente de su estado o, si tpl no
+// es nil, con esa plantilla sin guardarla. strip nil usa la configuración
+// vigente de quitado de tildes.
+func (r *Renderer) Preview(data TemplateData, tpl *Template, strip *bool) (Preview, error) {
+	r.mu.RLock()
+	c, ok := r.templates[data.Estado]
+	s := r.stripAccents
+	r.mu.RUnlock()
+	if strip != nil {
+		s = *strip
+	}
+	if tpl != nil {
+		var err error
+		if c, err = compile(*tpl); err != nil {
+			return Preview{}, err
+		}
+	} else if !ok {
+		return Preview{}, fmt.Errorf("notify: no hay plantilla para estado %q", data.Estado)
+	}
+	return preview(c, data, s)
+}
+
+func preview(c compiled, data TemplateData, strip bool) (Preview, error) {
+	text, err := render(c, data, strip)
+	if err != nil {
+		return Preview{}, err
+	}
+	info := sms.Segments(text)
+	return Preview{Text: text, SegmentInfo: info, TooLong: info.Segments > MaxSegments}, nil
+}
+
+// fitSegments recorta text para que ocupe a lo sumo MaxSegments segmentos,
+// marcando el corte con "..." (GSM-7, no cambia la codificación). Las
+// plantillas se validan con datos de ejemplo, pero una zona o un fenómeno
+// largos pueden excederlas al enviar.
+func fitSegments(text string) (string, bool) {
+	if sms.Segments(text).Segments <= MaxSegments {
+		return text, false
+	}
+	runes := []rune(text)
+	for n := len(runes) - 1; n > 0; n-- {
+		cut := strings.TrimSpace(string(runes[:n])) + "..."
+		if sms.Segments(cut).Segments <= MaxSegments {
+			return cut, true
+		}
+	}
+	return "", true
+}
@@ -0,0 +1,77 @@
+package notify
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"alerta_climatica/internal/integrations/sms"
+)
+
+func TestPreviewReportsEncodingAndStripsAccents(t *testing.T) {
+	r := NewRenderer()
+	data := TemplateData{Zona: "Zona Sur", Estado: "rojo", Fenomeno: "huaico", Hora: time.Date(2025, 3, 14, 18, 45, 0, 0, time.Local)}
+	p, err := r.Preview(data, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(p.Text, "ALERTA ROJA Zona Sur: huaico 18:45. Evacúe") || p.Encoding != sms.EncodingUCS2 || p.Segments != 2 {
+		t.Fatalf("unexpected preview %+v", p)
+	}
+	strip := true
+	p, err = r.Preview(data, nil, &strip)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(p.Text, "Evacue") || p.Encoding != sms.EncodingGSM7 || p.Segments != 1 || p.TooLong {
+		t.Fatalf("unexpected stripped preview %+v", p)
+	}
+
+	custom := &Template{Estado: "rojo", Texto: "{{.Severidad}} en {{.Zona}}: {{.Instrucciones}}", Instrucciones: "suba al cerro"}
+	data.Severidad = "crítica"
+	if p, err = r.Preview(data, custom, nil); err != nil || p.Text != "crítica en Zona Sur: suba al cerro" {
+		t.Fatalf("custom preview = %+v, %v", p, err)
+	}
+}
+
+func TestSetTemplatesValidates(t *testing.T) {
+	r := NewRenderer()
+
+	long := Template{Estado: "amarillo", Texto: "{{.Zona}} " + strings.Repeat("ó", 150)}
+	if err := r.SetTemplates([]Template{long}); err == nil {
+		t.Fatal("expected a template over MaxSegments to be rejected")
+	}
+	for _, bad := range []Template{
+		{Estado: "verde", Texto: "todo bien"},
+		{Estado: "rojo", Texto: "{{.Zona"},
+		{Estado: "rojo", Texto: "{{.Calle}}"},
+	} {
+		if err := r.SetTemplates([]Template{bad}); err == nil {
+			t.Fatalf("expected %+v to be rejected", bad)
+		}
+	}
+
+	ok := Template{Estado: "amarillo", Texto: "AVISO {{.Zona}}: {{.Fenomeno}}. {{.Instrucciones}}", Instrucciones: "Atentos."}
+	if err := r.SetTemplates([]Template{ok}); err != nil {
+		t.Fatal(err)
+	}
+	text, err := r.Render(TemplateData{Zona: "Zona Norte", Estado: "amarillo", Fenomeno: "lluvia"})
+	if err != nil || text != "AVISO Zona Norte: lluvia. Atentos." {
+		t.Fatalf("Render = %q, %v", text, err)
+	}
+	if got := r.Templates(); len(got) != 2 || got[0] != ok || got[1] != DefaultTemplates[1] {
+		t.Fatalf("unexpected templates %+v", got)
+	}
+}
+
+func TestFitSegmentsTruncatesLongBroadcasts(t *testing.T) {
+	short := "ALERTA ROJA Zona Sur: huaico"
+	if got, cut := fitSegments(short); cut || got != short {
+		t.Fatalf("fitSegments(%q) = %q, %v", short, got, cut)
+	}
+	long := "ALERTA ROJA " + strings.Repeat("Asentamiento Humano Las Lomas ", 20)
+	got, cut := fitSegments(long)
+	if !cut || !strings.HasSuffix(got, "...") || sms.Segments(got).Segments > MaxSegments {
+		t.Fatalf("expected truncation to %d segments, got %q (%+v)", MaxSegments, got, sms.Segments(got))
+	}
+}
@@ -1,74 +1,483 @@
 package processing
 
 import (
+	"context"
 	"crypto/rand"
 	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"log"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
+// ErrClosed se devuelve al enviar mensajes a un procesador no iniciado o ya cerrado.
+var ErrClosed = errors.New("processing: procesador cerrado")
+
+// DurableQueue persiste mensajes que no alcanzaron a procesarse antes del
+// cierre, para reinyectarlos en el siguiente arranque.
+type DurableQueue interface {
+	SaveQueued(msgs []IncomingMessage) error
+	// TakeQueued devuelve y elimina los mensajes guardados, en orden de llegada.
+	TakeQueued() ([]IncomingMessage, error)
+}
+
 // Processor coordina el procesamiento concurrente de mensajes entrantes
-// usando goroutines y canales. Asigna trabajos a workers simulando zonas.
+// usando goroutines y canales. Los mensajes se reparten en shards por zona:
+// cada shard tiene su propio canal y un único worker, de modo que los mensajes
+// de una misma zona se procesan en orden de llegada mientras que zonas
+// distintas avanzan en paralelo.
 type Processor struct {
 	zones   []string
-	inCh    chan IncomingMessage
+	shards  []chan IncomingMessage
 	wg      sync.WaitGroup
 	onAlert func(Alert) // callback para notificar alertas detectadas
+	onError func(IncomingMessage, error)
+	// interceptores que pueden consumir un mensaje antes de detect() (p. ej. comandos SMS)
+	interceptors []func(IncomingMessage) bool
+
+	numShards   int
+	demoLatency time.Duration // pausa tras cada mensaje (solo modo demo)
+	queue       DurableQueue
+	joiner      *reassembler // une SMS en varias partes antes de encolarlos
+
+	// Ciclo de vida: mu protege started/closed frente a Submit concurrentes.
+	mu      sync.RWMutex
+	started bool
+	closed  bool
+	ctx     context.Context // cancelado para detener workers tras el mensaje en curso
+	cancel  context.CancelFunc
+	// closing se cierra al empezar Shutdown para liberar los Submit que
+	// esperan en un shard lleno sin que retengan mu.
+	closing     chan struct{}
+	closingOnce sync.Once
+
+	// Métricas
+	processed atomic.Uint64
+	panics    atomic.Uint64
+	restarts  []atomic.Uint64 // reinicios por worker/shard
 }
 
 // NewProcessor crea un nuevo procesador con las zonas provistas.
 func NewProcessor(zones []string, onAlert func(Alert)) *Processor {
 	return &Processor{
-		zones:   zones,
-		inCh:    make(chan IncomingMessage, 64),
-		onAlert: onAlert,
+		zones:     zones,
+		onAlert:   onAlert,
+		numShards: 1,
+		closing:   make(chan struct{}),
 	}
 }
 
-// StartWorkers inicia n workers que consumen del canal y procesan mensajes.
-func (p *Processor) StartWorkers(n int) {
+// Backoff de reinicio de un worker tras un pánico.
+const (
+	workerRestartBase = 100 * time.Millisecond
+	workerRestartMax  = 30 * time.Second
+)
+
+// SetShards fija el número de shards (workers). Debe llamarse antes de Start.
+func (p *Processor) SetShards(n int) {
 	if n <= 0 {
 		n = 1
 	}
+	p.numShards = n
+}
+
+// SetQueue registra la cola durable usada por Start y Shutdown. Debe llamarse antes de Start.
+func (p *Processor) SetQueue(q DurableQueue) {
+	p.queue = q
+}
+
+// SetReassembly activa la unión de SMS en varias partes antes de la detección:
+// las partes con referencia de concatenación esperan hasta timeout a las
+// demás. Debe llamarse antes de Start.
+func (p *Processor) SetReassembly(timeout time.Duration) {
+	p.reassembly().timeout = timeout
+}
+
+// SetJoinWindow une también los SMS sin referencia de concatenación de un
+// mismo remitente que llegan con menos de window entre uno y otro (0 lo
+// desactiva). Los mensajes para los que standalone devuelve true (p. ej. los
+// comandos de suscripción) nunca se unen. Activa el reensamblado con
+// DefaultPartsTimeout si SetReassembly no se llamó. Debe llamarse antes de Start.
+func (p *Processor) SetJoinWindow(window time.Duration, standalone func(IncomingMessage) bool) {
+	r := p.reassembly()
+	r.window, r.standalone = window, standalone
+}
+
+// reassembly devuelve el reensamblador, creándolo si hace falta.
+func (p *Processor) reassembly() *reassembler {
+	if p.joiner == nil {
+		p.joiner = newReassembler(DefaultPartsTimeout, p.submitJoined)
+	}
+	return p.joiner
+}
+
+// Start lanza un worker supervisado por shard y reinyecta los mensajes que
+// hayan quedado en la cola durable. Si ctx se cancela, los workers se detienen
+// tras el mensaje en curso; para un cierre ordenado usar Shutdown.
+func (p *Processor) Start(ctx context.Context) error {
+	p.mu.Lock()
+	if p.started || p.closed {
+		p.mu.Unlock()
+		return errors.New("processing: procesador ya iniciado")
+	}
+	p.ctx, p.cancel = context.WithCancel(ctx)
+	n := p.numShards
+	p.shards = make([]chan IncomingMessage, n)
+	p.restarts = make([]atomic.Uint64, n)
 	for i := 0; i < n; i++ {
+		ch := make(chan IncomingMessage, 64)
+		p.shards[i] = ch
 		p.wg.Add(1)
-		go func(workerID int) {
-			defer p.wg.Done()
-			for msg := range p.inCh {
-				typ, sev, extract := detect(msg.Text)
-				alert := Alert{
-					ID:        newID(),
-					Zone:      msg.Zone,
-					Type:      typ,
-					Severity:  sev,
-					Message:   msg.Text,
-					Extract:   extract,
-					Timestamp: time.Now(),
-				}
-				// Entregar al callback para que el servidor actualice estado.
-				if p.onAlert != nil {
-					p.onAlert(alert)
-				}
-				// Simular latencia variable entre sensores/zonas.
-				time.Sleep(50 * time.Millisecond)
+		go p.supervise(i, ch)
+	}
+	p.started = true
+	p.mu.Unlock()
+
+	if p.queue == nil {
+		return nil
+	}
+	pending, err := p.queue.TakeQueued()
+	if err != nil {
+		log.Println("warning: cannot load queued messages:", err)
+		return nil
+	}
+	for _, msg := range pending {
+		if err := p.Submit(msg); err != nil {
+			return err
+		}
+	}
+	if len(pending) > 0 {
+		log.Printf("requeued %d messages from durable queue", len(pending))
+	}
+	return nil
+}
+
+// StartWorkers inicia n shards con un contexto de fondo. Equivale a
+// SetShards(n) seguido de Start(context.Background()).
+func (p *Processor) StartWorkers(n int) {
+	p.SetShards(n)
+	if err := p.Start(context.Background()); err != nil {
+		log.Println("warning: cannot start processor:", err)
+	}
+}
+
+// SetDemoLatency activa una pausa artificial tras cada mensaje para simular
+// sensores lentos en demos. Por defecto no hay pausa. Debe llamarse antes de Start.
+func (p *Processor) SetDemoLatency(d time.Duration) {
+	p.demoLatency = d
+}
+
+// SetErrorHandler registra un callback para mensajes cuyo procesamiento falla
+// (p. ej. un pánico en detect() o en onAlert). Debe llamarse antes de Start.
+func (p *Processor) SetErrorHandler(fn func(IncomingMessage, error)) {
+	p.onError = fn
+}
+
+// supervise mantiene vivo el worker de un shard. Si el worker entra en pánico,
+// se reinicia tras un backoff exponencial que se reinicia cuando el worker
+// vuelve a procesar mensajes con éxito. Termina cuando el canal se cierra.
+func (p *Processor) supervise(workerID int, in <-chan IncomingMessage) {
+	defer p.wg.Done()
+	failures := 0
+	for {
+		processed, finished := p.runWorker(workerID, in)
+		if finished {
+			return
+		}
+		if processed > 0 {
+			failures = 0
+		}
+		failures++
+		p.restarts[workerID].Add(1)
+		delay := restartBackoff(failures)
+		log.Printf("warning: restarting worker %d in %v (restart #%d)", workerID, delay, p.restarts[workerID].Load())
+		select {
+		case <-time.After(delay):
+		case <-p.ctx.Done():
+			return
+		}
+	}
+}
+
+// runWorker consume mensajes hasta que el canal se cierra o el contexto se
+// cancela (finished=true), o hasta un pánico, que se recupera y se reporta con
+// el mensaje en curso.
+func (p *Processor) runWorker(workerID int, in <-chan IncomingMessage) (processed int, finished bool) {
+	var current IncomingMessage
+	busy := false
+	defer func() {
+		if r := recover(); r != nil {
+			p.panics.Add(1)
+			err := fmt.Errorf("panic: %v", r)
+			log.Printf("warning: worker %d panicked processing message for %s: %v", workerID, current.Zone, err)
+			if busy {
+				p.reportError(current, err)
+			}
+			finished = false
+		}
+	}()
+	for {
+		if p.ctx.Err() != nil {
+			return processed, true
+		}
+		var msg IncomingMessage
+		select {
+		case <-p.ctx.Done():
+			return processed, true
+		case m, ok := <-in:
+			if !ok {
+				return processed, true
+			}
+			msg = m
+		}
+		current, busy = msg, true
+		p.process(msg)
+		busy = false
+		processed++
+		p.processed.Add(1)
+		if p.demoLatency > 0 {
+			// Modo demo: simular latencia variable entre sensores/zonas.
+			time.Sleep(p.demoLatency)
+		}
+	}
+}
+
+// reportError entrega el mensaje fallido al error handler sin dejar que un
+// pánico dentro del propio handler derribe al supervisor.
+func (p *Processor) reportError(msg IncomingMessage, err error) {
+	if p.onError == nil {
+		return
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("warning: error handler panicked: %v", r)
+		}
+	}()
+	p.onError(msg, err)
+}
+
+// restartBackoff calcula la espera antes del reinicio número n consecutivo.
+func restartBackoff(n int) time.Duration {
+	d := workerRestartBase
+	for i := 1; i < n; i++ {
+		d *= 2
+		if d >= workerRestartMax {
+			return workerRestartMax
+		}
+	}
+	return d
+}
+
+// AddInterceptor registra una función que ve cada mensaje antes de la
+// detección; si devuelve true el mensaje se considera atendido y no genera
+// alerta. Se evalúan en orden de registro. Debe llamarse antes de Start.
+func (p *Processor) AddInterceptor(fn func(IncomingMessage) bool) {
+	p.interceptors = append(p.interceptors, fn)
+}
+
+// process analiza un mensaje y entrega la alerta resultante.
+func (p *Processor) process(msg IncomingMessage) {
+	for _, fn := range p.interceptors {
+		if fn(msg) {
+			return
+		}
+	}
+	typ, sev, extract := detect(msg.Text)
+	alert := Alert{
+		ID:        NewID(),
+		Zone:      msg.Zone,
+		Type:      typ,
+		Severity:  sev,
+		Message:   msg.Text,
+		Extract:   extract,
+		Channel:   msg.Channel,
+		Timestamp: time.Now(),
+	}
+	// Un remitente no verificado podría suplantar a un vigía: se trata como anónimo.
+	if msg.Verified {
+		alert.Reporter = msg.From
+	}
+	// Entregar al callback para que el servidor actualice estado.
+	if p.onAlert != nil {
+		p.onAlert(alert)
+	}
+}
+
+// Stats resume contadores del procesador para exponer como métricas.
+type Stats struct {
+	Shards         int      `json:"shards"`
+	Processed      uint64   `json:"procesados"`
+	Panics         uint64   `json:"panicos"`
+	Restarts       uint64   `json:"reinicios"`
+	WorkerRestarts []uint64 `json:"reinicios_por_worker"`
+}
+
+// Stats devuelve una instantánea de los contadores del procesador.
+func (p *Processor) Stats() Stats {
+	st := Stats{
+		Shards:         len(p.shards),
+		Processed:      p.processed.Load(),
+		Panics:         p.panics.Load(),
+		WorkerRestarts: make([]uint64, len(p.restarts)),
+	}
+	for i := range p.restarts {
+		n := p.restarts[i].Load()
+		st.WorkerRestarts[i] = n
+		st.Restarts += n
+	}
+	return st
+}
+
+// Submit envía un mensaje entrante al shard correspondiente a su zona. Con
+// reensamblado activo, las partes de un SMS largo se retienen hasta
+// completarlo. Devuelve ErrClosed si el procesador no está en marcha.
+func (p *Processor) Submit(msg IncomingMessage) error {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if !p.started || p.closed {
+		return ErrClosed
+	}
+	if p.joiner != nil {
+		done, held := p.joiner.add(msg)
+		for _, m := range done {
+			if err := p.sendLocked(m); err != nil {
+				return err
 			}
-		}(i)
+		}
+		if held {
+			return nil
+		}
 	}
+	return p.sendLocked(msg)
 }
 
-// Submit envía un mensaje entrante al pool de workers.
-func (p *Processor) Submit(msg IncomingMessage) {
-	// Asignación simple: se envía al canal común y los workers compiten.
-	p.inCh <- msg
+// enqueue encola un mensaje ya reensamblado.
+func (p *Processor) enqueue(msg IncomingMessage) error {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if !p.started || p.closed {
+		return ErrClosed
+	}
+	return p.sendLocked(msg)
 }
 
-// Close cierra el canal y espera a que terminen los workers.
+// submitJoined encola un mensaje reensamblado cuyo plazo venció. Si el
+// procesador ya se cerró, lo guarda en la cola durable para el próximo Start.
+func (p *Processor) submitJoined(msg IncomingMessage) error {
+	err := p.enqueue(msg)
+	if !errors.Is(err, ErrClosed) || p.queue == nil {
+		return err
+	}
+	if err := p.queue.SaveQueued([]IncomingMessage{msg}); err != nil {
+		return fmt.Errorf("processing: no se pudo guardar el mensaje reensamblado: %w", err)
+	}
+	log.Printf("processor closed, saved reassembled SMS from %s to durable queue", msg.From)
+	return nil
+}
+
+// sendLocked entrega msg a su shard; requiere p.mu tomado en lectura. Si el
+// shard está lleno espera hasta que haya sitio o empiece el cierre.
+func (p *Processor) sendLocked(msg IncomingMessage) error {
+	select {
+	case p.shards[p.shardFor(msg.Zone)] <- msg:
+		return nil
+	case <-p.closing:
+		return ErrClosed
+	case <-p.ctx.Done():
+		return ErrClosed
+	}
+}
+
+// Shutdown deja de aceptar mensajes y espera a que los workers vacíen los
+// shards. Si ctx vence antes, deja de esperar: pide a los workers detenerse
+// tras el mensaje en curso, guarda los mensajes restantes en la cola durable y
+// devuelve ctx.Err(). Un worker bloqueado en su mensaje no retrasa el cierre.
+func (p *Processor) Shutdown(ctx context.Context) error {
+	p.closingOnce.Do(func() { close(p.closing) })
+	p.mu.Lock()
+	if !p.started || p.closed {
+		p.closed = true
+		p.mu.Unlock()
+		return nil
+	}
+	p.closed = true
+	// Las partes retenidas se procesan con lo recibido; si su shard está
+	// lleno van a la cola durable junto con el resto.
+	var rest []IncomingMessage
+	if p.joiner != nil {
+		for _, msg := range p.joiner.drain() {
+			select {
+			case p.shards[p.shardFor(msg.Zone)] <- msg:
+			default:
+				rest = append(rest, msg)
+			}
+		}
+	}
+	for _, ch := range p.shards {
+		close(ch)
+	}
+	p.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
+	var expired error
+	select {
+	case <-done:
+		p.cancel()
+		if len(rest) == 0 {
+			return nil
+		}
+	case <-ctx.Done():
+		expired = ctx.Err()
+		p.cancel()
+		log.Println("warning: drain deadline reached with workers still busy")
+	}
+
+	// Los shards ya están cerrados: cada mensaje lo recibe o un worker que
+	// aún no vio la cancelación o este bucle, nunca ambos.
+	for _, ch := range p.shards {
+		for msg := range ch {
+			rest = append(rest, msg)
+		}
+	}
+	if len(rest) == 0 {
+		return expired
+	}
+	if p.queue == nil {
+		if expired != nil {
+			return fmt.Errorf("processing: %d mensajes sin procesar al cerrar: %w", len(rest), expired)
+		}
+		return fmt.Errorf("processing: %d mensajes sin procesar al cerrar", len(rest))
+	}
+	if err := p.queue.SaveQueued(rest); err != nil {
+		return fmt.Errorf("processing: no se pudieron guardar %d mensajes: %w", len(rest), err)
+	}
+	log.Printf("drain deadline reached, saved %d messages to durable queue", len(rest))
+	return expired
+}
+
+// Close cierra el procesador esperando sin límite a que se vacíen los shards.
 func (p *Processor) Close() {
-	close(p.inCh)
-	p.wg.Wait()
+	if err := p.Shutdown(context.Background()); err != nil {
+		log.Println("warning: processor shutdown:", err)
+	}
+}
+
+// shardFor asigna una zona a un shard mediante hash FNV-1a, estable entre mensajes.
+func (p *Processor) shardFor(zone string) int {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(zone))
+	return int(h.Sum32() % uint32(len(p.shards)))
 }
 
-func newID() string {
+// NewID genera un identificador aleatorio para alertas.
+func NewID() string {
 	b := make([]byte, 8)
 	_, _ = rand.Read(b)
 	return hex.EncodeToString(b)
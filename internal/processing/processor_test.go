@@ -0,0 +1,275 @@
+package processing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestZoneOrdering verifica que los mensajes de una misma zona se procesan en
+// orden de llegada aunque haya varios shards trabajando en paralelo.
+func TestZoneOrdering(t *testing.T) {
+	var mu sync.Mutex
+	seen := make(map[string][]int)
+
+	p := NewProcessor(nil, func(a Alert) {
+		n, err := strconv.Atoi(strings.TrimPrefix(a.Message, "msg "))
+		if err != nil {
+			t.Errorf("unexpected message %q", a.Message)
+			return
+		}
+		mu.Lock()
+		seen[a.Zone] = append(seen[a.Zone], n)
+		mu.Unlock()
+	})
+	p.StartWorkers(2)
+
+	zones := []string{"Zona Norte", "Zona Centro", "Zona Sur"}
+	const perZone = 8
+	for i := 0; i < perZone; i++ {
+		for _, z := range zones {
+			p.Submit(IncomingMessage{Zone: z, Text: fmt.Sprintf("msg %d", i)})
+		}
+	}
+	p.Close()
+
+	for _, z := range zones {
+		got := seen[z]
+		if len(got) != perZone {
+			t.Fatalf("zone %s: expected %d messages, got %d", z, perZone, len(got))
+		}
+		for i, n := range got {
+			if n != i {
+				t.Fatalf("zone %s: out of order at %d: %v", z, i, got)
+			}
+		}
+	}
+}
+
+func TestShardForIsStable(t *testing.T) {
+	p := NewProcessor(nil, nil)
+	p.StartWorkers(4)
+	defer p.Close()
+
+	first := p.shardFor("Zona Norte")
+	for i := 0; i < 10; i++ {
+		if got := p.shardFor("Zona Norte"); got != first {
+			t.Fatalf("shardFor not stable: %d != %d", got, first)
+		}
+	}
+}
+
+// TestWorkerPanicIsSupervised verifica que un pánico en onAlert no derriba el
+// procesador: el mensaje se reporta al error handler y el worker se reinicia.
+func TestWorkerPanicIsSupervised(t *testing.T) {
+	var mu sync.Mutex
+	var failed []IncomingMessage
+	var delivered []string
+
+	p := NewProcessor(nil, func(a Alert) {
+		if a.Message == "boom" {
+			panic("callback roto")
+		}
+		mu.Lock()
+		delivered = append(delivered, a.Message)
+		mu.Unlock()
+	})
+	p.SetErrorHandler(func(msg IncomingMessage, err error) {
+		mu.Lock()
+		failed = append(failed, msg)
+		mu.Unlock()
+	})
+	p.StartWorkers(1)
+
+	p.Submit(IncomingMessage{Zone: "Zona Sur", Text: "boom"})
+	p.Submit(IncomingMessage{Zone: "Zona Sur", Text: "lluvia intensa"})
+	p.Close()
+
+	if len(failed) != 1 || failed[0].Text != "boom" {
+		t.Fatalf("expected the panicking message in error handler, got %+v", failed)
+	}
+	if len(delivered) != 1 || delivered[0] != "lluvia intensa" {
+		t.Fatalf("expected worker to keep processing after restart, got %v", delivered)
+	}
+	st := p.Stats()
+	if st.Panics != 1 || st.Restarts != 1 || st.WorkerRestarts[0] != 1 {
+		t.Fatalf("unexpected stats: %+v", st)
+	}
+}
+
+func TestRestartBackoff(t *testing.T) {
+	if got := restartBackoff(1); got != workerRestartBase {
+		t.Fatalf("restartBackoff(1) = %v", got)
+	}
+	if got := restartBackoff(2); got != 2*workerRestartBase {
+		t.Fatalf("restartBackoff(2) = %v", got)
+	}
+	if got := restartBackoff(100); got != workerRestartMax {
+		t.Fatalf("restartBackoff(100) = %v", got)
+	}
+}
+
+// BenchmarkPipeline mide el throughput de extremo a extremo: Submit, shard,
+// detect() y entrega al callback.
+func BenchmarkPipeline(b *testing.B) {
+	zones := []string{"Zona Norte", "Zona Centro", "Zona Sur", "Zona Este"}
+	var wg sync.WaitGroup
+	p := NewProcessor(zones, func(Alert) { wg.Done() })
+	p.StartWorkers(4)
+	defer p.Close()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	wg.Add(b.N)
+	for i := 0; i < b.N; i++ {
+		p.Submit(IncomingMessage{Zone: zones[i%len(zones)], Text: "Se reporta desborde del río"})
+	}
+	wg.Wait()
+}
+
+// memQueue es una DurableQueue en memoria para tests.
+type memQueue struct {
+	mu   sync.Mutex
+	msgs []IncomingMessage
+}
+
+func (q *memQueue) SaveQueued(msgs []IncomingMessage) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.msgs = append(q.msgs, msgs...)
+	return nil
+}
+
+func (q *memQueue) TakeQueued() ([]IncomingMessage, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	out := q.msgs
+	q.msgs = nil
+	return out, nil
+}
+
+func TestSubmitAfterShutdownReturnsErrClosed(t *testing.T) {
+	p := NewProcessor(nil, nil)
+	if err := p.Submit(IncomingMessage{Zone: "Zona Sur"}); err != ErrClosed {
+		t.Fatalf("Submit before Start: expected ErrClosed, got %v", err)
+	}
+	if err := p.Start(context.Background()); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	if err := p.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown failed: %v", err)
+	}
+	if err := p.Submit(IncomingMessage{Zone: "Zona Sur"}); err != ErrClosed {
+		t.Fatalf("Submit after Shutdown: expected ErrClosed, got %v", err)
+	}
+}
+
+// TestShutdownDeadlinePersistsPending fuerza un worker bloqueado para que el
+// plazo de cierre venza con mensajes en cola: Shutdown debe volver con el error
+// del plazo sin esperar al worker, guardar los pendientes en la cola durable y
+// éstos reprocesarse en el siguiente Start.
+func TestShutdownDeadlinePersistsPending(t *testing.T) {
+	q := &memQueue{}
+	release := make(chan struct{})
+	started := make(chan struct{}, 1)
+	p := NewProcessor(nil, func(a Alert) {
+		select {
+		case started <- struct{}{}:
+		default:
+		}
+		<-release
+	})
+	p.SetQueue(q)
+	if err := p.Start(context.Background()); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	for i := 0; i < 5; i++ {
+		if err := p.Submit(IncomingMessage{Zone: "Zona Norte", Text: fmt.Sprintf("msg %d", i)}); err != nil {
+			t.Fatalf("Submit failed: %v", err)
+		}
+	}
+
+	<-started // el worker quedó bloqueado en "msg 0"
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err := p.Shutdown(ctx)
+	close(release) // liberar el worker solo después de que Shutdown volvió
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline error, got %v", err)
+	}
+	if len(q.msgs) != 4 || q.msgs[0].Text != "msg 1" {
+		t.Fatalf("expected 4 pending messages starting at msg 1, got %+v", q.msgs)
+	}
+
+	var mu sync.Mutex
+	var got []string
+	p2 := NewProcessor(nil, func(a Alert) {
+		mu.Lock()
+		got = append(got, a.Message)
+		mu.Unlock()
+	})
+	p2.SetQueue(q)
+	if err := p2.Start(context.Background()); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	p2.Close()
+	if len(got) != 4 || got[0] != "msg 1" || got[3] != "msg 4" {
+		t.Fatalf("expected requeued messages in order, got %v", got)
+	}
+}
+
+func TestShutdownReleasesBlockedSubmit(t *testing.T) {
+	q := &memQueue{}
+	release := make(chan struct{})
+	defer close(release)
+	started := make(chan struct{}, 1)
+	p := NewProcessor(nil, func(a Alert) {
+		select {
+		case started <- struct{}{}:
+		default:
+		}
+		<-release
+	})
+	p.SetQueue(q)
+	if err := p.Start(context.Background()); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	if err := p.Submit(IncomingMessage{Zone: "Zona Norte", Text: "atascado"}); err != nil {
+		t.Fatalf("Submit failed: %v", err)
+	}
+	<-started
+	for i := 0; i < cap(p.shards[0]); i++ {
+		if err := p.Submit(IncomingMessage{Zone: "Zona Norte", Text: fmt.Sprintf("msg %d", i)}); err != nil {
+			t.Fatalf("Submit failed: %v", err)
+		}
+	}
+	// El shard está lleno: este Submit queda esperando con el lock tomado.
+	blocked := make(chan error, 1)
+	go func() { blocked <- p.Submit(IncomingMessage{Zone: "Zona Norte", Text: "bloqueado"}) }()
+	time.Sleep(20 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	done := make(chan error, 1)
+	go func() { done <- p.Shutdown(ctx) }()
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("expected deadline error, got %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Shutdown blocked behind a pending Submit")
+	}
+	if err := <-blocked; !errors.Is(err, ErrClosed) {
+		t.Fatalf("blocked Submit = %v, want ErrClosed", err)
+	}
+	if len(q.msgs) != cap(p.shards[0]) {
+		t.Fatalf("expected %d queued messages, got %d", cap(p.shards[0]), len(q.msgs))
+	}
+}
@@ -0,0 +1,170 @@
+package processing
+
+import (
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Límites de reensamblado de SMS en varias partes.
+const (
+	// DefaultPartsTimeout es el plazo para recibir todas las partes de un SMS
+	// concatenado (UDH); vencido se entrega lo que haya llegado.
+	DefaultPartsTimeout = 2 * time.Minute
+	// maxJoinedParts limita cuántos SMS sueltos se unen en un solo mensaje.
+	maxJoinedParts = 10
+)
+
+// reassembler une las partes de un SMS largo antes de la detección, para que
+// una descripción que llegó en varios SMS genere una sola alerta. Las partes
+// con referencia de concatenación (Ref/Part/Parts) se agrupan por remitente y
+// referencia. Con window > 0, los SMS sin ella de un mismo remitente se unen
+// si llegan con menos de window entre uno y otro, salvo los que standalone
+// reconoce como mensajes completos (p. ej. un comando "ALTA Zona Sur"), que
+// nunca se mezclan con un reporte.
+type reassembler struct {
+	timeout    time.Duration
+	window     time.Duration
+	standalone func(IncomingMessage) bool
+	emit       func(IncomingMessage) error // entrega un mensaje completo al flujo normal
+
+	mu   sync.Mutex
+	sets map[string]*partSet
+}
+
+// partSet acumula las partes recibidas de un mismo mensaje.
+type partSet struct {
+	first IncomingMessage // primera parte recibida: remitente, canal, zona
+	parts map[int]string  // con UDH: texto por número de parte
+	total int             // con UDH: partes anunciadas
+	texts []string        // sin UDH: textos en orden de llegada
+	timer *time.Timer
+}
+
+func newReassembler(timeout time.Duration, emit func(IncomingMessage) error) *reassembler {
+	return &reassembler{timeout: timeout, emit: emit, sets: make(map[string]*partSet)}
+}
+
+// add retiene msg si es parte de un mensaje más largo. Devuelve held=false si
+// el mensaje debe seguir su curso tal cual; done trae los mensajes que msg
+// completó o cerró y deben procesarse antes que él.
+func (r *reassembler) add(msg IncomingMessage) (done []IncomingMessage, held bool) {
+	udh := msg.Parts > 1 && msg.Part >= 1 && msg.Part <= msg.Parts
+	if !udh && (r.window <= 0 || msg.From == "" || msg.Channel != "sms") {
+		return nil, false
+	}
+	// Las partes verificadas y las que no lo están nunca se mezclan.
+	from := strconv.FormatBool(msg.Verified) + "|" + msg.From
+	key := "sms|" + from
+	wait := r.window
+	if udh {
+		key = "udh|" + from + "|" + strconv.Itoa(msg.Ref) + "|" + strconv.Itoa(msg.Parts)
+		wait = r.timeout
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !udh && r.standalone != nil && r.standalone(msg) {
+		// Un comando cierra lo retenido del remitente y sigue solo.
+		if set := r.sets[key]; set != nil {
+			set.timer.Stop()
+			delete(r.sets, key)
+			done = append(done, set.message())
+		}
+		return done, false
+	}
+	set := r.sets[key]
+	if set == nil {
+		set = &partSet{first: msg, total: msg.Parts, parts: make(map[int]string)}
+		r.sets[key] = set
+		set.timer = time.AfterFunc(wait, func() { r.expire(key, set) })
+	} else if msg.ReceivedAt.Before(set.first.ReceivedAt) {
+		set.first.ReceivedAt = msg.ReceivedAt
+	}
+
+	if udh {
+		if _, dup := set.parts[msg.Part]; !dup {
+			set.parts[msg.Part] = msg.Text
+		}
+		if msg.Part == 1 && msg.Zone != "" {
+			set.first.Zone = msg.Zone // la zona viene al inicio del texto
+		}
+		if len(set.parts) < set.total {
+			return nil, true
+		}
+	} else {
+		set.texts = append(set.texts, msg.Text)
+		if len(set.texts) < maxJoinedParts {
+			set.timer.Reset(r.window)
+			return nil, true
+		}
+	}
+	set.timer.Stop()
+	delete(r.sets, key)
+	return []IncomingMessage{set.message()}, true
+}
+
+// expire entrega un conjunto cuyo plazo venció, salvo que ya se haya entregado.
+func (r *reassembler) expire(key string, set *partSet) {
+	r.mu.Lock()
+	if r.sets[key] != set {
+		r.mu.Unlock()
+		return
+	}
+	delete(r.sets, key)
+	r.mu.Unlock()
+	if err := r.emit(set.message()); err != nil {
+		log.Printf("warning: cannot submit reassembled SMS from %s: %v", set.first.From, err)
+	}
+}
+
+// drain devuelve todos los conjuntos pendientes (completos o no) y los olvida.
+func (r *reassembler) drain() []IncomingMessage {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := make([]IncomingMessage, 0, len(r.sets))
+	for key, set := range r.sets {
+		set.timer.Stop()
+		delete(r.sets, key)
+		out = append(out, set.message())
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ReceivedAt.Before(out[j].ReceivedAt) })
+	return out
+}
+
+// message arma el mensaje completo. Las partes UDH se concatenan sin
+// separador (el corte puede caer en medio de una palabra) y un hueco por una
+// parte perdida se marca con "…"; los SMS sueltos se unen con un espacio.
+func (s *partSet) message() IncomingMessage {
+	msg := s.first
+	msg.Ref, msg.Part, msg.Parts = 0, 0, 0
+	if s.texts != nil {
+		msg.Text = strings.Join(s.texts, " ")
+		return msg
+	}
+	var b strings.Builder
+	gap := false
+	for i := 1; i <= s.total; i++ {
+		text, ok := s.parts[i]
+		if !ok {
+			gap = true
+			continue
+		}
+		if gap {
+			if b.Len() > 0 {
+				b.WriteString(" ")
+			}
+			b.WriteString("… ")
+		}
+		gap = false
+		b.WriteString(text)
+	}
+	if gap {
+		b.WriteString(" …")
+	}
+	msg.Text = b.String()
+	return msg
+}
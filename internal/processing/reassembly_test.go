@@ -0,0 +1,150 @@
+package processing
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func collectAlerts() (func(Alert), func() []Alert) {
+	var mu sync.Mutex
+	var got []Alert
+	return func(a Alert) {
+			mu.Lock()
+			got = append(got, a)
+			mu.Unlock()
+		}, func() []Alert {
+			mu.Lock()
+			defer mu.Unlock()
+			return append([]Alert(nil), got...)
+		}
+}
+
+func TestReassemblyJoinsConcatenatedParts(t *testing.T) {
+	onAlert, alerts := collectAlerts()
+	p := NewProcessor(nil, onAlert)
+	p.SetReassembly(time.Minute)
+	if err := p.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	parts := []IncomingMessage{
+		{Zone: "Zona Centro", Text: "hasta la plaza, hay familias atrapa", From: "+51987654321", Channel: "sms", Verified: true, Ref: 7, Part: 2, Parts: 3, ReceivedAt: now},
+		{Zone: "Zona Sur", Text: "El río se desborda y el agua llega ", From: "+51987654321", Channel: "sms", Verified: true, Ref: 7, Part: 1, Parts: 3, ReceivedAt: now.Add(-time.Second)},
+		{Zone: "Zona Norte", Text: "lluvia leve", From: "+51911111111", Channel: "sms"},
+		{Zone: "Zona Centro", Text: "das", From: "+51987654321", Channel: "sms", Verified: true, Ref: 7, Part: 3, Parts: 3, ReceivedAt: now},
+	}
+	for _, m := range parts {
+		if err := p.Submit(m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	p.Close()
+
+	got := alerts()
+	if len(got) != 2 {
+		t.Fatalf("expected 2 alerts, got %+v", got)
+	}
+	var joined Alert
+	for _, a := range got {
+		if a.Reporter == "+51987654321" {
+			joined = a
+		}
+	}
+	if joined.Message != "El río se desborda y el agua llega hasta la plaza, hay familias atrapadas" || joined.Zone != "Zona Sur" {
+		t.Fatalf("unexpected reassembled alert %+v", joined)
+	}
+}
+
+// TestReassemblyKeepsPlainSMSApart verifica que SMS sin referencia de
+// concatenación de un mismo remitente no se unen: un comando seguido de un
+// reporte son dos mensajes.
+func TestReassemblyKeepsPlainSMSApart(t *testing.T) {
+	onAlert, alerts := collectAlerts()
+	p := NewProcessor(nil, onAlert)
+	p.SetReassembly(time.Minute)
+	if err := p.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	p.Submit(IncomingMessage{Zone: "Zona Sur", Text: "ALTA Zona Sur", From: "+51987654321", Channel: "sms", Verified: true})
+	p.Submit(IncomingMessage{Zone: "Zona Sur", Text: "Se reporta desborde del río", From: "+51987654321", Channel: "sms", Verified: true})
+	p.Close()
+
+	got := alerts()
+	if len(got) != 2 || got[0].Message != "ALTA Zona Sur" || got[1].Message != "Se reporta desborde del río" {
+		t.Fatalf("expected 2 separate alerts, got %+v", got)
+	}
+}
+
+// TestReassemblyJoinWindow verifica que con ventana los SMS sueltos seguidos
+// de un remitente se unen, y que un comando no se une: cierra lo retenido y
+// se procesa solo.
+func TestReassemblyJoinWindow(t *testing.T) {
+	onAlert, alerts := collectAlerts()
+	p := NewProcessor(nil, onAlert)
+	p.SetJoinWindow(time.Minute, func(m IncomingMessage) bool { return strings.HasPrefix(m.Text, "ALTA") })
+	if err := p.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	from := "+51987654321"
+	p.Submit(IncomingMessage{Zone: "Zona Sur", Text: "el río se desborda", From: from, Channel: "sms", Verified: true})
+	p.Submit(IncomingMessage{Zone: "Zona Sur", Text: "junto al puente", From: from, Channel: "sms", Verified: true})
+	p.Submit(IncomingMessage{Zone: "Zona Sur", Text: "ALTA Zona Sur", From: from, Channel: "sms", Verified: true})
+	p.Submit(IncomingMessage{Zone: "Zona Sur", Text: "sigue lloviendo", From: from, Channel: "web"})
+	p.Close()
+
+	got := alerts()
+	want := []string{"el río se desborda junto al puente", "ALTA Zona Sur", "sigue lloviendo"}
+	if len(got) != len(want) {
+		t.Fatalf("expected %d alerts, got %+v", len(want), got)
+	}
+	for i, a := range got {
+		if a.Message != want[i] {
+			t.Fatalf("alert %d: expected %q, got %q", i, want[i], a.Message)
+		}
+	}
+}
+
+// TestReassemblyExpiredAfterShutdownIsQueued cubre un plazo que vence con el
+// procesador ya cerrado: el mensaje va a la cola durable en vez de perderse.
+func TestReassemblyExpiredAfterShutdownIsQueued(t *testing.T) {
+	q := &memQueue{}
+	p := NewProcessor(nil, nil)
+	p.SetQueue(q)
+	p.SetReassembly(time.Minute)
+	if err := p.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	p.Close()
+
+	msg := IncomingMessage{Zone: "Zona Sur", Text: "huaico en la quebrada", From: "+51987654321", Channel: "sms"}
+	if err := p.submitJoined(msg); err != nil {
+		t.Fatalf("submitJoined after shutdown: %v", err)
+	}
+	if len(q.msgs) != 1 || q.msgs[0].Text != msg.Text {
+		t.Fatalf("expected message in durable queue, got %+v", q.msgs)
+	}
+}
+
+func TestReassemblyFlushesIncompleteSets(t *testing.T) {
+	onAlert, alerts := collectAlerts()
+	p := NewProcessor(nil, onAlert)
+	p.SetReassembly(30 * time.Millisecond)
+	if err := p.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+	p.Submit(IncomingMessage{Zone: "Zona Sur", Text: "huaico en la quebrada", From: "+51987654321", Channel: "sms", Ref: 3, Part: 1, Parts: 3})
+	p.Submit(IncomingMessage{Zone: "Zona Sur", Text: "evacuen", From: "+51987654321", Channel: "sms", Ref: 3, Part: 3, Parts: 3})
+
+	deadline := time.Now().Add(2 * time.Second)
+	for len(alerts()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	got := alerts()
+	if len(got) != 1 || got[0].Message != "huaico en la quebrada … evacuen" {
+		t.Fatalf("expected incomplete set flushed after timeout, got %+v", got)
+	}
+}
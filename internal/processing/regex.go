@@ -12,6 +12,9 @@ type pattern struct {
 	severity string
 }
 
+// patterns se compila una sola vez al cargar el paquete.
+var patterns = buildPatterns()
+
 // Compila patrones de búsqueda para fenómenos críticos.
 // Sensibles a acentos y variantes comunes; modo case-insensitive.
 func buildPatterns() []pattern {
@@ -33,7 +36,7 @@ func detect(text string) (typ, sev, extract string) {
 	if t == "" {
 		return "", "", ""
 	}
-	for _, p := range buildPatterns() {
+	for _, p := range patterns {
 		if loc := p.re.FindStringIndex(t); loc != nil {
 			return p.alertTyp, p.severity, t[loc[0]:loc[1]]
 		}
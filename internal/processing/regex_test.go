@@ -0,0 +1,36 @@
+package processing
+
+import "testing"
+
+func TestDetect(t *testing.T) {
+	cases := []struct {
+		text, typ, sev string
+	}{
+		{"Lluvia intensa en el barrio San Pedro", "lluvia", "alta"},
+		{"Se reporta desborde del río", "desborde", "alta"},
+		{"Hay SEQUIA en la parte alta", "sequía", "media"},
+		{"Cayó un huaico en la quebrada", "huaico", "alta"},
+		{"Se declaró alerta roja", "alerta-roja", "crítica"},
+		{"rachas de viento en la costa", "viento", "media"},
+		{"Todo tranquilo por aquí", "informativo", "baja"},
+		{"   ", "", ""},
+	}
+	for _, c := range cases {
+		typ, sev, _ := detect(c.text)
+		if typ != c.typ || sev != c.sev {
+			t.Errorf("detect(%q) = %q/%q, want %q/%q", c.text, typ, sev, c.typ, c.sev)
+		}
+	}
+}
+
+func BenchmarkDetect(b *testing.B) {
+	texts := []string{
+		"Lluvia intensa en el barrio San Pedro desde la madrugada",
+		"Se reporta crecida del río y posible desborde en la parte baja",
+		"Todo tranquilo por aquí, sin novedades en la comunidad",
+	}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		detect(texts[i%len(texts)])
+	}
+}
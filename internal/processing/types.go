@@ -4,18 +4,63 @@ import "time"
 
 // IncomingMessage representa un "SMS" simulado recibido por el sistema.
 type IncomingMessage struct {
-	Zone       string    `json:"zona"`
-	Text       string    `json:"texto"`
+	Zone    string `json:"zona"`
+	Text    string `json:"texto"`
+	From    string `json:"telefono,omitempty"` // número del remitente en E.164, si se conoce
+	Channel string `json:"canal,omitempty"`    // vía de entrada: "web", "api", "sms", ...
+	// Verified indica que el mensaje llegó por un receptor autenticado
+	// (webhook de Twilio con firma, módem GSM o gateway HTTP con token): solo
+	// entonces From identifica al remitente para su confianza y sus comandos.
+	Verified   bool      `json:"verificado,omitempty"`
 	ReceivedAt time.Time `json:"recibido_en"`
+	// Concatenación (UDH) de un SMS largo: referencia común, número de parte
+	// (desde 1) y total de partes. En cero para mensajes de una sola parte.
+	Ref   int `json:"ref_concat,omitempty"`
+	Part  int `json:"parte,omitempty"`
+	Parts int `json:"partes,omitempty"`
+}
+
+// Fuentes de alertas generadas automáticamente (no por un reporte ciudadano).
+const (
+	SourceSensor   = "sensor"     // condición medida u observada
+	SourceForecast = "pronóstico" // condición pronosticada
+)
+
+// Resultado de contrastar un reporte ciudadano con el tiempo observado en su zona.
+const (
+	ValidationCorroborated = "corroborada" // los datos apoyan el reporte
+	ValidationDoubtful     = "dudosa"      // los datos lo contradicen
+	ValidationNoData       = "sin_datos"   // no hubo datos de la zona
+)
+
+// Estado de una zona con reportes graves aún sin corroborar.
+const StatusPending = "pendiente"
+
+// statusRank ordena los estados de zona de menor a mayor gravedad.
+var statusRank = map[string]int{"verde": 0, StatusPending: 1, "amarillo": 2, "rojo": 3}
+
+// StatusRank devuelve la gravedad de un estado de zona; los desconocidos
+// (incluido "") cuentan como "verde". Es la única escala de estados que usan
+// el escalamiento de zonas y la difusión.
+func StatusRank(status string) int {
+	return statusRank[status]
 }
 
 // Alert representa una alerta resultante del análisis del mensaje.
 type Alert struct {
-	ID        string    `json:"id"`
-	Zone      string    `json:"zona"`
-	Type      string    `json:"tipo"`
-	Severity  string    `json:"severidad"`
-	Message   string    `json:"mensaje"`
-	Extract   string    `json:"extracto"`
-	Timestamp time.Time `json:"timestamp"`
+	ID            string    `json:"id"`
+	Zone          string    `json:"zona"`
+	Type          string    `json:"tipo"`
+	Severity      string    `json:"severidad"`
+	Message       string    `json:"mensaje"`
+	Extract       string    `json:"extracto"`
+	Reporter      string    `json:"telefono,omitempty"` // remitente del mensaje original
+	Channel       string    `json:"canal,omitempty"`
+	ReporterTrust float64   `json:"confianza_reportero"`  // 0..1 al momento del reporte; 1 = vigía verificado
+	Review        string    `json:"revision,omitempty"`   // revisión del operador: "confirmada" o "descartada"
+	Source        string    `json:"fuente,omitempty"`     // origen automático: "sensor" o "pronóstico"; vacío = reporte ciudadano
+	Validation    string    `json:"validacion,omitempty"` // contraste con el tiempo: "corroborada", "dudosa" o "sin_datos"
+	WeatherNote   string    `json:"dato_meteo,omitempty"` // dato observado usado en la validación
+	Confidence    float64   `json:"confianza"`            // 0..1: confianza del remitente ajustada por la validación
+	Timestamp     time.Time `json:"timestamp"`
 }
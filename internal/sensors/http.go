@@ -0,0 +1,183 @@
+package sensors
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"alerta_climatica/internal/storage"
+)
+
+// maxReadingsBody limita el cuerpo de un envío de lecturas.
+const maxReadingsBody = 1 << 20
+
+// ServeRegistry atiende /api/admin/sensors: GET lista (?zona= filtra), PUT
+// registra o actualiza un sensor (JSON storage.Sensor) y DELETE ?id= lo da de
+// baja junto con sus lecturas.
+func (s *Service) ServeRegistry(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		list, err := s.store.ListSensors(r.URL.Query().Get("zona"))
+		if err != nil {
+			log.Println("error listing sensors:", err)
+			http.Error(w, "error leyendo sensores", http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, http.StatusOK, list)
+	case http.MethodPut, http.MethodPost:
+		var sn storage.Sensor
+		if err := json.NewDecoder(r.Body).Decode(&sn); err != nil {
+			http.Error(w, "JSON inválido", http.StatusBadRequest)
+			return
+		}
+		sn.ID, sn.Zone = strings.TrimSpace(sn.ID), strings.TrimSpace(sn.Zone)
+		if err := Validate(&sn); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if sn.CreatedAt.IsZero() {
+			sn.CreatedAt = s.now()
+		}
+		if err := s.store.SaveSensor(sn); err != nil {
+			log.Println("error saving sensor:", err)
+			http.Error(w, "no se pudo guardar el sensor", http.StatusInternalServerError)
+			return
+		}
+		saved, err := s.store.GetSensor(sn.ID)
+		if err != nil {
+			log.Println("error reading sensor:", err)
+			saved = sn
+		}
+		writeJSON(w, http.StatusOK, saved)
+	case http.MethodDelete:
+		id := r.URL.Query().Get("id")
+		if id == "" {
+			http.Error(w, "id requerido", http.StatusBadRequest)
+			return
+		}
+		if err := s.store.DeleteSensor(id); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				http.NotFound(w, r)
+				return
+			}
+			log.Println("error deleting sensor:", err)
+			http.Error(w, "no se pudo eliminar el sensor", http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// ServeReadings atiende /api/sensors/{id}/readings. POST recibe un lote de
+// lecturas, como arreglo JSON [{"hora": "...", "valor": 1.2}] o como
+// {"lecturas": [...]}, y responde cuántas eran nuevas y cuántas alertas
+// generaron. GET devuelve la serie desde ?desde= (RFC 3339; por defecto las
+// últimas 24 horas).
+func (s *Service) ServeReadings(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	switch r.Method {
+	case http.MethodGet:
+		since := s.now().Add(-24 * time.Hour)
+		if v := r.URL.Query().Get("desde"); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				http.Error(w, "desde debe ser una fecha RFC 3339", http.StatusBadRequest)
+				return
+			}
+			since = t
+		}
+		if _, err := s.store.GetSensor(id); err != nil {
+			writeSensorError(w, err)
+			return
+		}
+		list, err := s.store.ListReadings(id, since)
+		if err != nil {
+			log.Println("error listing readings:", err)
+			http.Error(w, "error leyendo lecturas", http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, http.StatusOK, list)
+	case http.MethodPost:
+		if s.token != "" {
+			got := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if subtle.ConstantTimeCompare([]byte(got), []byte(s.token)) != 1 {
+				http.Error(w, "no autorizado", http.StatusUnauthorized)
+				return
+			}
+		}
+		payload, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxReadingsBody))
+		if err != nil {
+			http.Error(w, "cuerpo demasiado grande", http.StatusRequestEntityTooLarge)
+			return
+		}
+		readings, err := decodeReadings(payload)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		saved, alerts, err := s.Ingest(id, readings)
+		if errors.Is(err, ErrFutureReading) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			writeSensorError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]int{"guardadas": saved, "alertas": alerts})
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// decodeReadings acepta un arreglo de lecturas o un objeto con "lecturas".
+func decodeReadings(payload []byte) ([]storage.Reading, error) {
+	var readings []storage.Reading
+	trimmed := bytes.TrimSpace(payload)
+	if len(trimmed) > 0 && trimmed[0] == '[' {
+		if err := json.Unmarshal(trimmed, &readings); err != nil {
+			return nil, errors.New("JSON inválido")
+		}
+	} else {
+		var batch struct {
+			Readings []storage.Reading `json:"lecturas"`
+		}
+		if err := json.Unmarshal(trimmed, &batch); err != nil {
+			return nil, errors.New("JSON inválido")
+		}
+		readings = batch.Readings
+	}
+	switch {
+	case len(readings) == 0:
+		return nil, errors.New("se requiere al menos una lectura")
+	case len(readings) > maxBatch:
+		return nil, errors.New("demasiadas lecturas en un envío")
+	}
+	return readings, nil
+}
+
+func writeSensorError(w http.ResponseWriter, err error) {
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "sensor no registrado", http.StatusNotFound)
+		return
+	}
+	log.Println("error accessing sensor:", err)
+	http.Error(w, "error accediendo al sensor", http.StatusInternalServerError)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Println("error serializando respuesta de sensores:", err)
+	}
+}
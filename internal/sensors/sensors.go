@@ -0,0 +1,303 @@
+// Package sensors recibe lecturas de estaciones automáticas (limnímetros y
+// pluviómetros), las guarda como series de tiempo y genera alertas cuando se
+// superan los umbrales propios de cada sensor.
+package sensors
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"alerta_climatica/internal/processing"
+	"alerta_climatica/internal/storage"
+)
+
+// DefaultWindow es la ventana de las reglas de subida y acumulado sin ventana_min.
+const DefaultWindow = time.Hour
+
+// DefaultCooldown es cuánto tiempo una regla que sigue superada no vuelve a
+// alertar para el mismo sensor.
+const DefaultCooldown = 3 * time.Hour
+
+// maxBatch limita las lecturas de un envío.
+const maxBatch = 1000
+
+// maxClockSkew es cuánto puede adelantarse la hora de una lectura a la del
+// servidor (relojes de estación algo corridos).
+const maxClockSkew = 5 * time.Minute
+
+// ErrFutureReading indica una lectura con hora posterior a la del servidor.
+var ErrFutureReading = errors.New("lectura con hora futura")
+
+// Las lecturas de una estación automática se tratan como verificadas.
+const sensorTrust = 1
+
+var validID = regexp.MustCompile(`^[A-Za-z0-9_.-]{1,64}$`)
+
+var severities = map[string]bool{"media": true, "alta": true, "crítica": true}
+
+// Validate revisa un sensor antes de registrarlo y completa los valores por
+// defecto de sus reglas.
+func Validate(sn *storage.Sensor) error {
+	switch {
+	case !validID.MatchString(sn.ID):
+		return fmt.Errorf("id %q inválido: letras, números, '.', '-' o '_'", sn.ID)
+	case sn.Kind != storage.SensorRiver && sn.Kind != storage.SensorRain:
+		return fmt.Errorf("tipo %q inválido: debe ser %s o %s", sn.Kind, storage.SensorRiver, storage.SensorRain)
+	case sn.Zone == "":
+		return fmt.Errorf("sensor %s sin zona", sn.ID)
+	case sn.Lat < -90 || sn.Lat > 90 || sn.Lon < -180 || sn.Lon > 180:
+		return fmt.Errorf("sensor %s: coordenadas fuera de rango", sn.ID)
+	}
+	if sn.Rules == nil {
+		sn.Rules = []storage.SensorRule{}
+	}
+	for i := range sn.Rules {
+		r := &sn.Rules[i]
+		switch r.Kind {
+		case storage.SensorRuleLevel, storage.SensorRuleRise, storage.SensorRuleTotal:
+		default:
+			return fmt.Errorf("regla %d: tipo %q inválido", i+1, r.Kind)
+		}
+		if r.Value <= 0 {
+			return fmt.Errorf("regla %d: valor debe ser positivo", i+1)
+		}
+		if r.WindowMin < 0 {
+			return fmt.Errorf("regla %d: ventana_min no puede ser negativa", i+1)
+		}
+		if r.WindowMin == 0 && r.Kind != storage.SensorRuleLevel {
+			r.WindowMin = int(DefaultWindow / time.Minute)
+		}
+		if r.Severity == "" {
+			r.Severity = "alta"
+		}
+		if !severities[r.Severity] {
+			return fmt.Errorf("regla %d: severidad %q inválida", i+1, r.Severity)
+		}
+	}
+	return nil
+}
+
+// Service guarda lecturas y evalúa las reglas de cada sensor; entrega las
+// alertas a emit (normalmente State.AddAlert).
+type Service struct {
+	store storage.SensorStore
+	emit  func(processing.Alert)
+	token string // si no está vacío, exigido en Authorization: Bearer
+
+	mu        sync.Mutex // serializa la evaluación
+	cooldown  time.Duration
+	fired     map[string]time.Time // sensor|regla -> última alerta mientras sigue superada
+	evaluated map[string]time.Time // sensor -> lectura más reciente evaluada; se carga del store al reiniciar
+	now       func() time.Time
+}
+
+// NewService crea el servicio de sensores.
+func NewService(store storage.SensorStore, emit func(processing.Alert)) *Service {
+	return &Service{
+		store:     store,
+		emit:      emit,
+		cooldown:  DefaultCooldown,
+		fired:     make(map[string]time.Time),
+		evaluated: make(map[string]time.Time),
+		now:       time.Now,
+	}
+}
+
+// SetToken exige un token de portador a las estaciones que envían lecturas.
+func (s *Service) SetToken(token string) {
+	s.token = token
+}
+
+// SetCooldown cambia cada cuánto puede repetirse la alerta de una regla que sigue superada.
+func (s *Service) SetCooldown(d time.Duration) {
+	s.mu.Lock()
+	s.cooldown = d
+	s.mu.Unlock()
+}
+
+// Ingest guarda un lote de lecturas del sensor id y evalúa sus reglas en cada
+// lectura nueva, en orden cronológico. Devuelve cuántas lecturas eran nuevas y
+// cuántas alertas se generaron. Las lecturas sin hora toman la actual; las
+// anteriores a la última evaluada se guardan pero no alertan (relleno de huecos).
+// Un lote con alguna lectura más de maxClockSkew por delante de la hora del
+// servidor se rechaza entero con ErrFutureReading: una hora futura dejaría al
+// sensor sin evaluar hasta alcanzarla.
+func (s *Service) Ingest(id string, readings []storage.Reading) (saved, alerts int, err error) {
+	sn, err := s.store.GetSensor(id)
+	if err != nil {
+		return 0, 0, err
+	}
+	now := s.now()
+	for i := range readings {
+		readings[i].SensorID = id
+		if readings[i].Time.IsZero() {
+			readings[i].Time = now
+		}
+		if readings[i].Time.After(now.Add(maxClockSkew)) {
+			return 0, 0, fmt.Errorf("%w: %s", ErrFutureReading, readings[i].Time.UTC().Format(time.RFC3339))
+		}
+		readings[i].Time = readings[i].Time.UTC().Truncate(time.Second)
+	}
+	sort.SliceStable(readings, func(i, j int) bool { return readings[i].Time.Before(readings[j].Time) })
+
+	s.mu.Lock()
+	out, saved, err := s.evaluateLocked(sn, readings, now)
+	s.mu.Unlock()
+	if err != nil {
+		return saved, 0, err
+	}
+	for _, a := range out {
+		s.emit(a)
+	}
+	return saved, len(out), nil
+}
+
+func (s *Service) evaluateLocked(sn storage.Sensor, readings []storage.Reading, now time.Time) ([]processing.Alert, int, error) {
+	last, ok := s.evaluated[sn.ID]
+	if !ok {
+		// Tras un reinicio, lo ya guardado se evaluó al llegar.
+		prev, err := s.store.LatestReading(sn.ID, now.Add(maxClockSkew))
+		switch {
+		case err == nil:
+			last = prev.Time
+		case !errors.Is(err, sql.ErrNoRows):
+			return nil, 0, err
+		}
+	}
+	saved, err := s.store.SaveReadings(sn.ID, readings)
+	if err != nil || saved == 0 || len(sn.Rules) == 0 {
+		return nil, saved, err
+	}
+	var fresh []storage.Reading
+	for _, r := range readings {
+		if r.Time.After(last) && (len(fresh) == 0 || r.Time.After(fresh[len(fresh)-1].Time)) {
+			fresh = append(fresh, r)
+		}
+	}
+	if len(fresh) == 0 {
+		return nil, saved, nil
+	}
+	window := time.Duration(0)
+	for _, rule := range sn.Rules {
+		if w := ruleWindow(rule); w > window {
+			window = w
+		}
+	}
+	history, err := s.store.ListReadings(sn.ID, fresh[0].Time.Add(-window))
+	if err != nil {
+		return nil, saved, err
+	}
+	var out []processing.Alert
+	for _, r := range fresh {
+		upTo := sort.Search(len(history), func(i int) bool { return history[i].Time.After(r.Time) })
+		for i, rule := range sn.Rules {
+			v, breached, ok := evaluate(rule, history[:upTo], r.Time)
+			if !ok {
+				continue // sin datos suficientes no cambia el estado de la regla
+			}
+			if s.shouldFireLocked(fmt.Sprintf("%s|%d", sn.ID, i), breached, r.Time) {
+				out = append(out, alertFor(sn, rule, v, r.Time))
+			}
+		}
+	}
+	s.evaluated[sn.ID] = fresh[len(fresh)-1].Time
+	return out, saved, nil
+}
+
+// shouldFireLocked deduplica por sensor y regla: alerta al superarse y no
+// repite mientras siga superada, salvo pasado el cooldown.
+func (s *Service) shouldFireLocked(key string, breached bool, at time.Time) bool {
+	if !breached {
+		delete(s.fired, key)
+		return false
+	}
+	if last, ok := s.fired[key]; ok && at.Sub(last) < s.cooldown {
+		return false
+	}
+	s.fired[key] = at
+	return true
+}
+
+func ruleWindow(r storage.SensorRule) time.Duration {
+	if r.Kind == storage.SensorRuleLevel {
+		return 0
+	}
+	if r.WindowMin == 0 {
+		return DefaultWindow
+	}
+	return time.Duration(r.WindowMin) * time.Minute
+}
+
+// evaluate aplica r sobre history (en orden cronológico y terminando en la
+// lectura de la hora at). Devuelve el valor comparado, si supera el umbral y
+// si había datos suficientes: la subida necesita lecturas que cubran al
+// menos media ventana.
+func evaluate(r storage.SensorRule, history []storage.Reading, at time.Time) (float64, bool, bool) {
+	if len(history) == 0 {
+		return 0, false, false
+	}
+	latest := history[len(history)-1]
+	window := ruleWindow(r)
+	switch r.Kind {
+	case storage.SensorRuleLevel:
+		return latest.Value, latest.Value >= r.Value, true
+	case storage.SensorRuleTotal:
+		var sum float64
+		for _, h := range history {
+			if h.Time.After(at.Add(-window)) {
+				sum += h.Value
+			}
+		}
+		return sum, sum >= r.Value, true
+	case storage.SensorRuleRise:
+		i := sort.Search(len(history), func(i int) bool { return !history[i].Time.Before(at.Add(-window)) })
+		base := history[i]
+		elapsed := latest.Time.Sub(base.Time)
+		if elapsed < window/2 {
+			return 0, false, false
+		}
+		rate := (latest.Value - base.Value) / elapsed.Hours()
+		return rate, rate >= r.Value, true
+	}
+	return 0, false, false
+}
+
+// phenomenon es el fenómeno de las alertas de cada tipo de sensor.
+var phenomenon = map[string]string{storage.SensorRiver: "desborde", storage.SensorRain: "lluvia"}
+
+func alertFor(sn storage.Sensor, r storage.SensorRule, v float64, at time.Time) processing.Alert {
+	name := sn.Name
+	if name == "" {
+		name = sn.ID
+	}
+	unit := ""
+	if sn.Unit != "" {
+		unit = " " + sn.Unit
+	}
+	var msg string
+	switch r.Kind {
+	case storage.SensorRuleLevel:
+		msg = fmt.Sprintf("Sensor %s: lectura %.2f%s (umbral %.2f%s)", name, v, unit, r.Value, unit)
+	case storage.SensorRuleRise:
+		msg = fmt.Sprintf("Sensor %s: sube %.2f%s/h en %d min (umbral %.2f%s/h)", name, v, unit, r.WindowMin, r.Value, unit)
+	default:
+		msg = fmt.Sprintf("Sensor %s: %.1f%s acumulados en %d min (umbral %.1f%s)", name, v, unit, r.WindowMin, r.Value, unit)
+	}
+	return processing.Alert{
+		ID:            processing.NewID(),
+		Zone:          sn.Zone,
+		Type:          phenomenon[sn.Kind],
+		Severity:      r.Severity,
+		Message:       msg,
+		Extract:       sn.ID + " " + r.Kind,
+		Channel:       "sensor",
+		ReporterTrust: sensorTrust,
+		Source:        processing.SourceSensor,
+		Timestamp:     at,
+	}
+}
@@ -0,0 +1,149 @@
+package sensors
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"alerta_climatica/internal/processing"
+	"alerta_climatica/internal/storage"
+)
+
+func TestRiverGaugeLevelAndRise(t *testing.T) {
+	store, err := storage.NewSQLite(filepath.Join(t.TempDir(), "sensors.db"))
+	if err != nil {
+		t.Fatalf("NewSQLite: %v", err)
+	}
+	defer store.Close()
+	var got []processing.Alert
+	svc := NewService(store, func(a processing.Alert) { got = append(got, a) })
+	svc.SetToken("secreto")
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/admin/sensors", svc.ServeRegistry)
+	mux.HandleFunc("/api/sensors/{id}/readings", svc.ServeReadings)
+
+	do := func(method, path, body, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec
+	}
+
+	sensor := `{"id": "rimac-01", "nombre": "Río Rímac - Chosica", "tipo": "nivel_rio", "zona": "Zona Sur", "lat": -11.94, "lon": -76.69, "unidad": "cm",
+		"reglas": [{"tipo": "umbral", "valor": 300, "severidad": "crítica"}, {"tipo": "subida", "valor": 50}]}`
+	if rec := do(http.MethodPut, "/api/admin/sensors", sensor, ""); rec.Code != http.StatusOK {
+		t.Fatalf("register: %d %s", rec.Code, rec.Body)
+	}
+	if rec := do(http.MethodPut, "/api/admin/sensors", `{"id": "x", "tipo": "termometro", "zona": "Zona Sur"}`, ""); rec.Code != http.StatusBadRequest {
+		t.Fatalf("invalid kind accepted: %d", rec.Code)
+	}
+
+	// Subida de 60 cm en una hora, por debajo del nivel de alerta.
+	t0 := time.Date(2026, 2, 10, 6, 0, 0, 0, time.UTC)
+	batch := func(levels ...float64) string {
+		var rs []storage.Reading
+		for i, v := range levels {
+			rs = append(rs, storage.Reading{Time: t0.Add(time.Duration(i) * 15 * time.Minute), Value: v})
+		}
+		b, _ := json.Marshal(map[string]any{"lecturas": rs})
+		return string(b)
+	}
+	if rec := do(http.MethodPost, "/api/sensors/rimac-01/readings", batch(180), "otro"); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("wrong token: %d", rec.Code)
+	}
+	if rec := do(http.MethodPost, "/api/sensors/desconocido/readings", batch(180), "secreto"); rec.Code != http.StatusNotFound {
+		t.Fatalf("unknown sensor: %d", rec.Code)
+	}
+	rec := do(http.MethodPost, "/api/sensors/rimac-01/readings", batch(180, 190, 200, 215, 240), "secreto")
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"alertas":1`) || !strings.Contains(rec.Body.String(), `"guardadas":5`) {
+		t.Fatalf("ingest: %d %s", rec.Code, rec.Body)
+	}
+	rise := got[0]
+	if rise.Type != "desborde" || rise.Source != processing.SourceSensor || rise.Zone != "Zona Sur" || rise.Severity != "alta" ||
+		rise.Message != "Sensor Río Rímac - Chosica: sube 60.00 cm/h en 60 min (umbral 50.00 cm/h)" || !rise.Timestamp.Equal(t0.Add(time.Hour)) {
+		t.Fatalf("unexpected rise alert %+v", rise)
+	}
+
+	// Reenviar el mismo lote es idempotente y no repite alertas.
+	if rec := do(http.MethodPost, "/api/sensors/rimac-01/readings", batch(180, 190, 200, 215, 240), "secreto"); !strings.Contains(rec.Body.String(), `"guardadas":0`) || len(got) != 1 {
+		t.Fatalf("repeated batch: %s, %d alerts", rec.Body, len(got))
+	}
+
+	// Supera el nivel de alerta: la subida sigue superada y no se repite.
+	if _, n, err := svc.Ingest("rimac-01", []storage.Reading{{Time: t0.Add(75 * time.Minute), Value: 310}}); err != nil || n != 1 {
+		t.Fatalf("level breach: %d alerts, %v", n, err)
+	}
+	if level := got[1]; level.Severity != "crítica" || level.Message != "Sensor Río Rímac - Chosica: lectura 310.00 cm (umbral 300.00 cm)" {
+		t.Fatalf("unexpected level alert %+v", level)
+	}
+
+	rec = do(http.MethodGet, "/api/sensors/rimac-01/readings?desde="+t0.Format(time.RFC3339), "", "")
+	var series []storage.Reading
+	if err := json.Unmarshal(rec.Body.Bytes(), &series); err != nil || len(series) != 6 || series[5].Value != 310 {
+		t.Fatalf("series: %v %s", err, rec.Body)
+	}
+}
+
+func TestRainGaugeAccumulation(t *testing.T) {
+	sn := storage.Sensor{ID: "pluvio", Kind: storage.SensorRain, Zone: "Zona Norte", Rules: []storage.SensorRule{{Kind: storage.SensorRuleTotal, Value: 20}}}
+	if err := Validate(&sn); err != nil || sn.Rules[0].WindowMin != 60 || sn.Rules[0].Severity != "alta" {
+		t.Fatalf("Validate: %v %+v", err, sn.Rules)
+	}
+	t0 := time.Date(2026, 2, 10, 6, 0, 0, 0, time.UTC)
+	var history []storage.Reading
+	for i, v := range []float64{4, 6, 5, 3, 7} {
+		history = append(history, storage.Reading{Time: t0.Add(time.Duration(i) * 15 * time.Minute), Value: v})
+	}
+	// A las 07:00 la ventana de una hora excluye la lectura de las 06:00.
+	if v, breached, ok := evaluate(sn.Rules[0], history, t0.Add(time.Hour)); !ok || !breached || v != 21 {
+		t.Fatalf("accumulated = %v %v %v, want 21 breached", v, breached, ok)
+	}
+}
+
+func TestFutureReadingsAndRestart(t *testing.T) {
+	store, err := storage.NewSQLite(filepath.Join(t.TempDir(), "sensors.db"))
+	if err != nil {
+		t.Fatalf("NewSQLite: %v", err)
+	}
+	defer store.Close()
+	sn := storage.Sensor{ID: "rimac-01", Kind: storage.SensorRiver, Zone: "Zona Sur", Rules: []storage.SensorRule{{Kind: storage.SensorRuleLevel, Value: 300}}}
+	if err := Validate(&sn); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+	if err := store.SaveSensor(sn); err != nil {
+		t.Fatalf("SaveSensor: %v", err)
+	}
+	t0 := time.Date(2026, 2, 10, 6, 0, 0, 0, time.UTC)
+	var got []processing.Alert
+	start := func() *Service {
+		svc := NewService(store, func(a processing.Alert) { got = append(got, a) })
+		svc.now = func() time.Time { return t0.Add(2 * time.Hour) }
+		return svc
+	}
+	svc := start()
+
+	// Una lectura con el reloj adelantado se rechaza y no bloquea las siguientes.
+	if _, _, err := svc.Ingest(sn.ID, []storage.Reading{{Time: t0.Add(3 * time.Hour), Value: 320}}); !errors.Is(err, ErrFutureReading) {
+		t.Fatalf("future reading: %v", err)
+	}
+	if _, n, err := svc.Ingest(sn.ID, []storage.Reading{{Time: t0.Add(90 * time.Minute), Value: 310}}); err != nil || n != 1 {
+		t.Fatalf("ingest: %d alerts, %v", n, err)
+	}
+
+	// Tras reiniciar, una lectura atrasada ya no reevalúa lo alertado.
+	svc = start()
+	if _, n, err := svc.Ingest(sn.ID, []storage.Reading{{Time: t0.Add(75 * time.Minute), Value: 305}}); err != nil || n != 0 {
+		t.Fatalf("late reading after restart: %d alerts, %v", n, err)
+	}
+	if _, n, err := svc.Ingest(sn.ID, []storage.Reading{{Time: t0.Add(105 * time.Minute), Value: 315}}); err != nil || n != 1 || len(got) != 2 {
+		t.Fatalf("new reading after restart: %d alerts, %v, %d total", n, err, len(got))
+	}
+}
@@ -0,0 +1,319 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"alerta_climatica/internal/processing"
+	"alerta_climatica/internal/storage"
+)
+
+// Parámetros del reintento automático de persistencias fallidas.
+const (
+	deadLetterBaseDelay   = 5 * time.Second
+	deadLetterMaxDelay    = 10 * time.Minute
+	deadLetterMaxAttempts = 10
+)
+
+// ErrDeadLetterBusy indica una entrada que ya se está reintentando (a mano o
+// en la pasada automática).
+var ErrDeadLetterBusy = errors.New("dead-letter: reintento en curso")
+
+// deadLetterBackoff calcula la espera exponencial tras n intentos fallidos.
+func deadLetterBackoff(attempts int) time.Duration {
+	d := deadLetterBaseDelay
+	for i := 1; i < attempts; i++ {
+		d *= 2
+		if d >= deadLetterMaxDelay {
+			return deadLetterMaxDelay
+		}
+	}
+	return d
+}
+
+// SetReplayer registra la función usada para reinyectar mensajes cuyo
+// procesamiento falló (normalmente Processor.Submit).
+func (s *State) SetReplayer(fn func(processing.IncomingMessage) error) {
+	s.mu.Lock()
+	s.replay = fn
+	s.mu.Unlock()
+}
+
+// RecordFailedMessage guarda en dead-letter un mensaje cuyo procesamiento
+// entró en pánico. Se usa como error handler del Processor.
+func (s *State) RecordFailedMessage(msg processing.IncomingMessage, cause error) {
+	s.addDeadLetter(storage.DeadLetterPanic, msg, cause, 1)
+}
+
+// addDeadLetter registra una entrada. Si el store no acepta la escritura, la
+// entrada queda en memoria con un id temporal negativo, con el que puede
+// reintentarse a mano, y se vuelca al store en la siguiente pasada de
+// RetryDeadLetters.
+func (s *State) addDeadLetter(kind string, payload any, cause error, attempts int) {
+	b, err := json.Marshal(payload)
+	if err != nil {
+		log.Println("warning: cannot encode dead-letter payload:", err)
+		return
+	}
+	now := time.Now()
+	d := storage.DeadLetter{
+		Kind:        kind,
+		Payload:     string(b),
+		Error:       cause.Error(),
+		Attempts:    attempts,
+		CreatedAt:   now,
+		NextAttempt: now.Add(deadLetterBackoff(attempts)),
+	}
+	if s.store != nil {
+		if _, err := s.store.AddDeadLetter(d); err == nil {
+			return
+		} else {
+			log.Println("warning: failed to persist dead-letter, keeping in memory:", err)
+		}
+	}
+	s.mu.Lock()
+	s.lastTempID--
+	d.ID = s.lastTempID
+	s.pendingDead = append(s.pendingDead, d)
+	s.mu.Unlock()
+}
+
+// ListDeadLetters devuelve las entradas dead-letter (persistidas y pendientes
+// en memoria, éstas con id negativo).
+func (s *State) ListDeadLetters(includeResolved bool) ([]storage.DeadLetter, error) {
+	s.mu.RLock()
+	out := make([]storage.DeadLetter, 0, len(s.pendingDead))
+	for _, d := range s.pendingDead {
+		if includeResolved || !d.Resolved {
+			out = append(out, d)
+		}
+	}
+	s.mu.RUnlock()
+	if s.store == nil {
+		return out, nil
+	}
+	list, err := s.store.ListDeadLetters(includeResolved)
+	if err != nil {
+		return nil, err
+	}
+	return append(out, list...), nil
+}
+
+// RetryDeadLetters ejecuta una pasada de reintentos: vuelca al store las
+// entradas pendientes en memoria y reintenta las persistencias vencidas.
+// Los pánicos no se reintentan automáticamente; se reinyectan con ReplayDeadLetter.
+func (s *State) RetryDeadLetters(now time.Time) {
+	if s.store == nil {
+		return
+	}
+
+	// Las entradas que se están reintentando a mano siguen en memoria hasta
+	// la próxima pasada.
+	s.mu.Lock()
+	var pending, busy []storage.DeadLetter
+	for _, d := range s.pendingDead {
+		if s.deadBusy[d.ID] {
+			busy = append(busy, d)
+		} else {
+			pending = append(pending, d)
+		}
+	}
+	s.pendingDead = busy
+	s.mu.Unlock()
+	for i, d := range pending {
+		if _, err := s.store.AddDeadLetter(d); err != nil {
+			s.mu.Lock()
+			s.pendingDead = append(s.pendingDead, pending[i:]...)
+			s.mu.Unlock()
+			return
+		}
+	}
+
+	list, err := s.store.ListDeadLetters(false)
+	if err != nil {
+		log.Println("warning: cannot list dead-letters:", err)
+		return
+	}
+	for _, d := range list {
+		if d.Kind != storage.DeadLetterPersist || d.Attempts >= deadLetterMaxAttempts || now.Before(d.NextAttempt) {
+			continue
+		}
+		s.retryStored(d.ID, now)
+	}
+}
+
+// retryStored reintenta una persistencia guardada si nadie más la tiene
+// tomada. Se relee tras tomarla: un reintento manual pudo resolverla.
+func (s *State) retryStored(id int64, now time.Time) {
+	if !s.claimDeadLetter(id) {
+		return
+	}
+	defer s.releaseDeadLetter(id)
+	d, err := s.store.GetDeadLetter(id)
+	if err != nil || d.Resolved {
+		return
+	}
+	err = s.retryPersist(&d, now)
+	if uerr := s.store.UpdateDeadLetter(d); uerr != nil {
+		log.Printf("warning: cannot update dead-letter %d: %v", d.ID, uerr)
+	}
+	if err != nil {
+		log.Printf("warning: dead-letter %d retry %d failed: %v", d.ID, d.Attempts, err)
+	}
+}
+
+// claimDeadLetter marca id como en curso; devuelve false si otro reintento
+// ya lo tomó.
+func (s *State) claimDeadLetter(id int64) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.claimDeadLetterLocked(id)
+}
+
+// claimDeadLetterLocked es claimDeadLetter con s.mu ya tomado.
+func (s *State) claimDeadLetterLocked(id int64) bool {
+	if s.deadBusy[id] {
+		return false
+	}
+	s.deadBusy[id] = true
+	return true
+}
+
+// releaseDeadLetter libera una entrada tomada con claimDeadLetter.
+func (s *State) releaseDeadLetter(id int64) {
+	s.mu.Lock()
+	delete(s.deadBusy, id)
+	s.mu.Unlock()
+}
+
+// retryPersist intenta guardar de nuevo la alerta de una entrada "persist" y
+// actualiza intentos/backoff o la marca como resuelta; quien llama guarda la
+// entrada. Solo escribe en la base: la alerta ya surtió efecto en AddAlert.
+func (s *State) retryPersist(d *storage.DeadLetter, now time.Time) error {
+	if s.store == nil {
+		return errors.New("dead-letter requiere almacenamiento")
+	}
+	var a processing.Alert
+	if err := json.Unmarshal([]byte(d.Payload), &a); err != nil {
+		return err
+	}
+	if err := s.store.SaveAlert(a); err != nil {
+		d.Attempts++
+		d.Error = err.Error()
+		d.NextAttempt = now.Add(deadLetterBackoff(d.Attempts))
+		return err
+	}
+	d.Resolved = true
+	return nil
+}
+
+// RunDeadLetterRetry ejecuta RetryDeadLetters periódicamente hasta que ctx se cancela.
+func (s *State) RunDeadLetterRetry(ctx context.Context, every time.Duration) {
+	t := time.NewTicker(every)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-t.C:
+			s.RetryDeadLetters(now)
+		}
+	}
+}
+
+// ReplayDeadLetter reintenta manualmente una entrada: vuelve a persistir la
+// alerta o reinyecta el mensaje en el procesador. Un id negativo es una
+// entrada aún en memoria (ver addDeadLetter). Devuelve ErrDeadLetterBusy si
+// la entrada ya se está reintentando.
+func (s *State) ReplayDeadLetter(id int64) error {
+	if id < 0 {
+		return s.replayPending(id)
+	}
+	if s.store == nil {
+		return errors.New("dead-letter requiere almacenamiento")
+	}
+	if !s.claimDeadLetter(id) {
+		return ErrDeadLetterBusy
+	}
+	defer s.releaseDeadLetter(id)
+	d, err := s.store.GetDeadLetter(id)
+	if err != nil {
+		return err
+	}
+	if d.Resolved {
+		return nil
+	}
+	err = s.replayEntry(&d)
+	if uerr := s.store.UpdateDeadLetter(d); uerr != nil {
+		return uerr
+	}
+	return err
+}
+
+// replayPending reintenta una entrada en memoria. Mientras está tomada,
+// RetryDeadLetters no la vuelca al store; resuelta, sigue en memoria hasta
+// volcarse como historial.
+func (s *State) replayPending(id int64) error {
+	s.mu.Lock()
+	i := 0
+	for i < len(s.pendingDead) && s.pendingDead[i].ID != id {
+		i++
+	}
+	if i == len(s.pendingDead) {
+		s.mu.Unlock()
+		return sql.ErrNoRows
+	}
+	d := s.pendingDead[i]
+	if d.Resolved {
+		s.mu.Unlock()
+		return nil
+	}
+	if !s.claimDeadLetterLocked(id) {
+		s.mu.Unlock()
+		return ErrDeadLetterBusy
+	}
+	s.mu.Unlock()
+	defer s.releaseDeadLetter(id)
+
+	err := s.replayEntry(&d)
+	s.mu.Lock()
+	for i := range s.pendingDead {
+		if s.pendingDead[i].ID == id {
+			s.pendingDead[i] = d
+		}
+	}
+	s.mu.Unlock()
+	return err
+}
+
+// replayEntry reintenta d según su tipo y actualiza su estado en memoria.
+func (s *State) replayEntry(d *storage.DeadLetter) error {
+	switch d.Kind {
+	case storage.DeadLetterPersist:
+		return s.retryPersist(d, time.Now())
+	case storage.DeadLetterPanic:
+		s.mu.RLock()
+		replay := s.replay
+		s.mu.RUnlock()
+		if replay == nil {
+			return errors.New("no hay procesador para reinyectar mensajes")
+		}
+		var msg processing.IncomingMessage
+		if err := json.Unmarshal([]byte(d.Payload), &msg); err != nil {
+			return err
+		}
+		if err := replay(msg); err != nil {
+			return err
+		}
+		// Si el mensaje vuelve a fallar, el error handler registra una entrada nueva.
+		d.Resolved = true
+		return nil
+	default:
+		return fmt.Errorf("tipo de dead-letter desconocido: %q", d.Kind)
+	}
+}
@@ -0,0 +1,179 @@
+package server
+
+import (
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"alerta_climatica/internal/processing"
+	"alerta_climatica/internal/storage"
+)
+
+// flakyStore falla SaveAlert mientras fail esté activo, y AddDeadLetter
+// mientras failDead lo esté.
+type flakyStore struct {
+	storage.Store
+	fail     atomic.Bool
+	failDead atomic.Bool
+}
+
+func (f *flakyStore) AddDeadLetter(d storage.DeadLetter) (int64, error) {
+	if f.failDead.Load() {
+		return 0, errors.New("database is locked")
+	}
+	return f.Store.AddDeadLetter(d)
+}
+
+func (f *flakyStore) SaveAlert(a processing.Alert) error {
+	if f.fail.Load() {
+		return errors.New("database is locked")
+	}
+	return f.Store.SaveAlert(a)
+}
+
+func TestFailedPersistGoesToDeadLetterAndRetries(t *testing.T) {
+	base, err := storage.NewSQLite(filepath.Join(t.TempDir(), "dl.db"))
+	if err != nil {
+		t.Fatalf("NewSQLite failed: %v", err)
+	}
+	fs := &flakyStore{Store: base}
+	fs.fail.Store(true)
+	st := NewState(fs)
+	defer st.Close()
+	var changes atomic.Int32
+	st.OnZoneChange(func(ZoneStatusChange, processing.Alert) { changes.Add(1) })
+
+	st.AddAlert(processing.Alert{ID: "a1", Zone: "Zona Norte", Severity: "alta", ReporterTrust: 1, Timestamp: time.Now()})
+	// Aunque no se persista, la alerta escala la zona y dispara la difusión.
+	if n := changes.Load(); n != 1 || st.Zones()["Zona Norte"] != "amarillo" {
+		t.Fatalf("unpersisted alert should still take effect: %d hooks, zone %q", n, st.Zones()["Zona Norte"])
+	}
+
+	list, err := st.ListDeadLetters(false)
+	if err != nil || len(list) != 1 {
+		t.Fatalf("expected 1 dead-letter, got %v (err %v)", list, err)
+	}
+
+	// Un reintento antes de tiempo no debe hacer nada; uno vencido que falla aumenta intentos.
+	st.RetryDeadLetters(time.Now())
+	st.RetryDeadLetters(time.Now().Add(time.Hour))
+	list, _ = st.ListDeadLetters(false)
+	if len(list) != 1 || list[0].Attempts != 2 {
+		t.Fatalf("expected attempts=2 after failed retry, got %+v", list)
+	}
+
+	fs.fail.Store(false)
+	if err := st.ReplayDeadLetter(list[0].ID); err != nil {
+		t.Fatalf("ReplayDeadLetter failed: %v", err)
+	}
+	list, _ = st.ListDeadLetters(false)
+	if len(list) != 0 {
+		t.Fatalf("expected dead-letter resolved, got %+v", list)
+	}
+	alerts, _ := base.ListAlerts()
+	if len(alerts) != 1 || alerts[0].ID != "a1" {
+		t.Fatalf("alert not persisted after replay: %+v", alerts)
+	}
+	// Guardarla más tarde no repite la escalada.
+	if n := changes.Load(); n != 1 || st.Zones()["Zona Norte"] != "amarillo" {
+		t.Fatalf("persisted alert should escalate once: %d hooks, zone %q", n, st.Zones()["Zona Norte"])
+	}
+}
+
+// TestPendingDeadLettersHaveTemporaryIDs verifica que las entradas que no
+// llegaron al store tienen ids negativos únicos y se pueden reintentar.
+func TestPendingDeadLettersHaveTemporaryIDs(t *testing.T) {
+	st := NewState(nil)
+	defer st.Close()
+	var replayed []string
+	st.SetReplayer(func(m processing.IncomingMessage) error {
+		replayed = append(replayed, m.Text)
+		return nil
+	})
+	st.RecordFailedMessage(processing.IncomingMessage{Zone: "Zona Sur", Text: "uno"}, errors.New("panic"))
+	st.RecordFailedMessage(processing.IncomingMessage{Zone: "Zona Sur", Text: "dos"}, errors.New("panic"))
+
+	list, _ := st.ListDeadLetters(false)
+	if len(list) != 2 || list[0].ID != -1 || list[1].ID != -2 {
+		t.Fatalf("expected temporary ids -1 and -2, got %+v", list)
+	}
+	if err := st.ReplayDeadLetter(-2); err != nil {
+		t.Fatalf("replay of pending entry failed: %v", err)
+	}
+	if len(replayed) != 1 || replayed[0] != "dos" {
+		t.Fatalf("unexpected replayed messages %v", replayed)
+	}
+	if list, _ = st.ListDeadLetters(false); len(list) != 1 || list[0].ID != -1 {
+		t.Fatalf("replayed entry should be resolved, got %+v", list)
+	}
+	if err := st.ReplayDeadLetter(-9); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("unknown temporary id: got %v", err)
+	}
+}
+
+// TestConcurrentReplaysRunOnce reintenta la misma entrada dos veces a la vez,
+// en memoria y en el store: solo el primer reintento reinyecta el mensaje.
+func TestConcurrentReplaysRunOnce(t *testing.T) {
+	base, err := storage.NewSQLite(filepath.Join(t.TempDir(), "dl.db"))
+	if err != nil {
+		t.Fatalf("NewSQLite failed: %v", err)
+	}
+	fs := &flakyStore{Store: base}
+	st := NewState(fs)
+	defer st.Close()
+	entered, release := make(chan string), make(chan struct{})
+	var replayed atomic.Int32
+	st.SetReplayer(func(m processing.IncomingMessage) error {
+		replayed.Add(1)
+		entered <- m.Text
+		<-release
+		return nil
+	})
+	fs.failDead.Store(true)
+	st.RecordFailedMessage(processing.IncomingMessage{Zone: "Zona Sur", Text: "en memoria"}, errors.New("panic"))
+	fs.failDead.Store(false)
+	st.RecordFailedMessage(processing.IncomingMessage{Zone: "Zona Sur", Text: "guardado"}, errors.New("panic"))
+	list, _ := st.ListDeadLetters(false)
+	if len(list) != 2 || list[0].ID != -1 || list[1].ID <= 0 {
+		t.Fatalf("expected one pending and one stored entry, got %+v", list)
+	}
+
+	done := make(chan error, 2)
+	for _, d := range list {
+		go func(id int64) { done <- st.ReplayDeadLetter(id) }(d.ID)
+		<-entered
+		if err := st.ReplayDeadLetter(d.ID); !errors.Is(err, ErrDeadLetterBusy) {
+			t.Fatalf("second replay of %d: got %v", d.ID, err)
+		}
+	}
+	// La pasada automática no vuelca la entrada en memoria que se está reintentando.
+	st.RetryDeadLetters(time.Now())
+	if list, _ := st.ListDeadLetters(false); len(list) != 2 || list[0].ID != -1 {
+		t.Fatalf("busy pending entry was flushed: %+v", list)
+	}
+	close(release)
+	for i := 0; i < 2; i++ {
+		if err := <-done; err != nil {
+			t.Fatalf("replay failed: %v", err)
+		}
+	}
+	st.RetryDeadLetters(time.Now())
+	if list, _ := st.ListDeadLetters(false); len(list) != 0 || replayed.Load() != 2 {
+		t.Fatalf("expected both entries resolved once, got %+v after %d replays", list, replayed.Load())
+	}
+}
+
+func TestDeadLetterBackoff(t *testing.T) {
+	if got := deadLetterBackoff(1); got != deadLetterBaseDelay {
+		t.Fatalf("backoff(1) = %v", got)
+	}
+	if got := deadLetterBackoff(3); got != 4*deadLetterBaseDelay {
+		t.Fatalf("backoff(3) = %v", got)
+	}
+	if got := deadLetterBackoff(50); got != deadLetterMaxDelay {
+		t.Fatalf("backoff(50) = %v", got)
+	}
+}
@@ -0,0 +1,180 @@
+package server
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"alerta_climatica/internal/processing"
+	"alerta_climatica/internal/storage"
+)
+
+// Estado de una zona con reportes graves aún sin corroborar.
+const StatusPending = processing.StatusPending
+
+// EscalationRule lleva una zona a Status cuando al menos Reporters remitentes
+// distintos reportan, dentro de Window, alertas que coinciden con la regla.
+// Zone, Type y Severity vacíos coinciden con cualquier valor; sin Type, los
+// reportes deben ser del mismo fenómeno que la alerta evaluada.
+type EscalationRule struct {
+	Zone      string        `json:"zona,omitempty"`
+	Type      string        `json:"fenomeno,omitempty"`
+	Severity  string        `json:"severidad,omitempty"`
+	Reporters int           `json:"reportantes"`
+	Window    time.Duration `json:"-"`
+	WindowMin int           `json:"ventana_min"`
+	Status    string        `json:"estado"`
+}
+
+// DefaultEscalationRules: dos remitentes distintos en 30 minutos escalan
+// según la severidad detectada.
+func DefaultEscalationRules() []EscalationRule {
+	return []EscalationRule{
+		{Severity: "crítica", Reporters: 2, WindowMin: 30, Status: "rojo"},
+		{Severity: "alta", Reporters: 2, WindowMin: 30, Status: "amarillo"},
+	}
+}
+
+// validate normaliza la ventana y comprueba los campos de la regla.
+func (r *EscalationRule) validate() error {
+	if r.Status != "amarillo" && r.Status != "rojo" {
+		return fmt.Errorf("estado %q inválido: debe ser amarillo o rojo", r.Status)
+	}
+	if r.Reporters < 1 {
+		return errors.New("reportantes debe ser al menos 1")
+	}
+	if r.WindowMin < 1 {
+		return errors.New("ventana_min debe ser al menos 1")
+	}
+	r.Window = time.Duration(r.WindowMin) * time.Minute
+	return nil
+}
+
+func (r EscalationRule) matches(a processing.Alert) bool {
+	return (r.Zone == "" || r.Zone == a.Zone) &&
+		(r.Type == "" || r.Type == a.Type) &&
+		(r.Severity == "" || r.Severity == a.Severity)
+}
+
+// Además del número de remitentes, la confianza sumada debe llegar al umbral:
+// un vigía (confianza 1) cumple cualquier regla por sí solo, mientras que los
+// reportes anónimos necesitan más apoyo que los de números conocidos.
+const corroborationThreshold = 1.0
+
+// SetEscalationRules reemplaza las reglas de escalamiento.
+func (s *State) SetEscalationRules(rules []EscalationRule) error {
+	out := make([]EscalationRule, len(rules))
+	for i, r := range rules {
+		if err := r.validate(); err != nil {
+			return fmt.Errorf("regla %d: %w", i+1, err)
+		}
+		out[i] = r
+	}
+	s.mu.Lock()
+	s.rules = out
+	s.mu.Unlock()
+	return nil
+}
+
+// EscalationRules devuelve las reglas vigentes.
+func (s *State) EscalationRules() []EscalationRule {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make([]EscalationRule, len(s.rules))
+	copy(out, s.rules)
+	return out
+}
+
+// reporterTrust devuelve la confianza del remitente de a consultando el store.
+// Un número aún no registrado cuenta como comunitario.
+func (s *State) reporterTrust(a processing.Alert) float64 {
+	if a.Reporter == "" {
+		return storage.TrustScore(storage.TrustAnonymous, 0, 0)
+	}
+	if s.store == nil {
+		return storage.TrustScore(storage.TrustCommunity, 0, 0)
+	}
+	rep, err := s.store.GetReporter(a.Reporter)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			log.Println("warning: cannot read reporter trust:", err)
+		}
+		return storage.TrustScore(storage.TrustCommunity, 0, 0)
+	}
+	return rep.Trust
+}
+
+// alertConfidence combina la confianza del remitente con la validación
+// meteorológica: un dato que apoya el reporte recorre la mitad de lo que le
+// falta para 1 y uno que lo contradice la reduce a la mitad.
+func alertConfidence(trust float64, validation string) float64 {
+	switch validation {
+	case processing.ValidationCorroborated:
+		return trust + (1-trust)/2
+	case processing.ValidationDoubtful:
+		return trust / 2
+	}
+	return trust
+}
+
+// escalationLocked evalúa las reglas para a (que ya está en s.alerts) y
+// devuelve el estado al que debe pasar la zona: el más grave de las reglas
+// cumplidas, StatusPending si alguna regla aplica pero falta corroboración, o
+// "" si ninguna aplica. Requiere s.mu tomado.
+func (s *State) escalationLocked(a processing.Alert) string {
+	status := ""
+	for _, r := range s.rules {
+		if !r.matches(a) {
+			continue
+		}
+		if s.ruleSatisfiedLocked(r, a) {
+			if processing.StatusRank(r.Status) > processing.StatusRank(status) {
+				status = r.Status
+			}
+		} else if status == "" {
+			status = StatusPending
+		}
+	}
+	return status
+}
+
+// ruleSatisfiedLocked cuenta los remitentes distintos de reportes recientes que
+// coinciden con r en la zona de a, tomando la confianza máxima por remitente
+// para que un mismo número no se corrobore a sí mismo. Cada reporte anónimo
+// cuenta por separado y las alertas descartadas no cuentan.
+func (s *State) ruleSatisfiedLocked(r EscalationRule, a processing.Alert) bool {
+	if a.ReporterTrust >= corroborationThreshold {
+		return true
+	}
+	byReporter := make(map[string]float64)
+	since := a.Timestamp.Add(-r.Window)
+	for _, prev := range s.alerts {
+		if prev.Zone != a.Zone || !r.matches(processing.Alert{Zone: prev.Zone, Type: prev.Type, Severity: prev.Severity}) {
+			continue
+		}
+		if r.Type == "" && prev.Type != a.Type {
+			continue
+		}
+		if prev.Timestamp.Before(since) || prev.Timestamp.After(a.Timestamp) || prev.Review == storage.ReviewDismissed {
+			continue
+		}
+		key := prev.Reporter
+		if key == "" {
+			key = "anon:" + prev.ID
+		}
+		if prev.ReporterTrust > byReporter[key] {
+			byReporter[key] = prev.ReporterTrust
+		}
+	}
+	var total float64
+	for _, t := range byReporter {
+		if t >= corroborationThreshold {
+			return true
+		}
+		total += t
+	}
+	// Tolerancia para sumas como 0.3+0.3+0.4 en coma flotante.
+	return len(byReporter) >= r.Reporters && total >= corroborationThreshold-1e-9
+}
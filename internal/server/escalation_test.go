@@ -0,0 +1,107 @@
+package server
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"alerta_climatica/internal/processing"
+)
+
+func TestAnonymousReportsNeedCorroboration(t *testing.T) {
+	st := NewState(nil)
+	now := time.Now()
+	for i := 0; i < 3; i++ {
+		st.AddAlert(processing.Alert{ID: fmt.Sprint("a", i), Zone: "Zona Sur", Type: "inundacion", Severity: "crítica", Timestamp: now})
+		if got := st.Zones()["Zona Sur"]; got != StatusPending {
+			t.Fatalf("after %d anonymous reports zone is %q, want pendiente", i+1, got)
+		}
+	}
+	// Un reporte de otro fenómeno no corrobora.
+	st.AddAlert(processing.Alert{ID: "x", Zone: "Zona Sur", Type: "huaico", Severity: "crítica", Timestamp: now})
+	if got := st.Zones()["Zona Sur"]; got != StatusPending {
+		t.Fatalf("unrelated report escalated zone to %q", got)
+	}
+	st.AddAlert(processing.Alert{ID: "a3", Zone: "Zona Sur", Type: "inundacion", Severity: "crítica", Timestamp: now})
+	if got := st.Zones()["Zona Sur"]; got != "rojo" {
+		t.Fatalf("corroborated reports: zone is %q, want rojo", got)
+	}
+}
+
+func TestSameReporterDoesNotCorroborateItself(t *testing.T) {
+	st := NewState(nil)
+	now := time.Now()
+	for i := 0; i < 5; i++ {
+		st.AddAlert(processing.Alert{ID: fmt.Sprint("r", i), Zone: "Zona Norte", Type: "inundacion", Severity: "alta", Reporter: "+51987654321", Timestamp: now})
+	}
+	if got := st.Zones()["Zona Norte"]; got != StatusPending {
+		t.Fatalf("repeated reports from one number escalated zone to %q", got)
+	}
+	st.AddAlert(processing.Alert{ID: "r5", Zone: "Zona Norte", Type: "inundacion", Severity: "alta", Reporter: "+51911111111", Timestamp: now})
+	if got := st.Zones()["Zona Norte"]; got != "amarillo" {
+		t.Fatalf("two community reporters: zone is %q, want amarillo", got)
+	}
+}
+
+func TestTrustedSourceEscalatesImmediately(t *testing.T) {
+	st := NewState(nil)
+	st.AddAlert(processing.Alert{ID: "v", Zone: "Zona Centro", Type: "inundacion", Severity: "alta", ReporterTrust: 1, Timestamp: time.Now()})
+	if got := st.Zones()["Zona Centro"]; got != "amarillo" {
+		t.Fatalf("trusted report: zone is %q, want amarillo", got)
+	}
+}
+
+func TestCustomRuleByPhenomenon(t *testing.T) {
+	st := NewState(nil)
+	rules := append(DefaultEscalationRules(), EscalationRule{Zone: "Zona Sur", Type: "desborde", Reporters: 3, WindowMin: 10, Status: "rojo"})
+	if err := st.SetEscalationRules(rules); err != nil {
+		t.Fatalf("SetEscalationRules: %v", err)
+	}
+	now := time.Now()
+	phones := []string{"+51911111111", "+51922222222", "+51933333333"}
+	want := []string{StatusPending, "amarillo", "rojo"}
+	for i, p := range phones {
+		st.AddAlert(processing.Alert{ID: p, Zone: "Zona Sur", Type: "desborde", Severity: "alta", Reporter: p, ReporterTrust: 0.5, Timestamp: now.Add(time.Duration(i) * time.Minute)})
+		if got := st.Zones()["Zona Sur"]; got != want[i] {
+			t.Fatalf("after %d reporters zone is %q, want %q", i+1, got, want[i])
+		}
+	}
+	// Fuera de la ventana de la regla no se acumulan reportes.
+	st.AddAlert(processing.Alert{ID: "old", Zone: "Zona Norte", Type: "desborde", Severity: "alta", Reporter: phones[0], ReporterTrust: 0.5, Timestamp: now.Add(-time.Hour)})
+	st.AddAlert(processing.Alert{ID: "new", Zone: "Zona Norte", Type: "desborde", Severity: "alta", Reporter: phones[1], ReporterTrust: 0.5, Timestamp: now})
+	if got := st.Zones()["Zona Norte"]; got != StatusPending {
+		t.Fatalf("reports outside window escalated zone to %q", got)
+	}
+
+	if err := st.SetEscalationRules([]EscalationRule{{Reporters: 2, WindowMin: 5, Status: "violeta"}}); err == nil {
+		t.Fatal("expected error for invalid status")
+	}
+}
+
+func TestWeatherCheckAdjustsConfidence(t *testing.T) {
+	st := NewState(nil)
+	st.SetWeatherCheck(func(a processing.Alert) (string, string) {
+		if a.Zone == "Zona Sur" {
+			return processing.ValidationDoubtful, "lluvia 0.0 mm"
+		}
+		return processing.ValidationCorroborated, "lluvia 6.2 mm"
+	})
+	now := time.Now()
+	st.AddAlert(processing.Alert{ID: "s", Zone: "Zona Sur", Type: "lluvia", Severity: "alta", Timestamp: now})
+	st.AddAlert(processing.Alert{ID: "n", Zone: "Zona Norte", Type: "lluvia", Severity: "alta", Reporter: "+51987654321", Timestamp: now})
+	st.AddAlert(processing.Alert{ID: "m", Zone: "Zona Norte", Type: "lluvia", Severity: "alta", ReporterTrust: 1, Source: processing.SourceSensor, Timestamp: now})
+
+	got := make(map[string]processing.Alert)
+	for _, a := range st.ListAlerts() {
+		got[a.ID] = a
+	}
+	if a := got["s"]; a.Validation != processing.ValidationDoubtful || a.WeatherNote != "lluvia 0.0 mm" || a.Confidence != a.ReporterTrust/2 {
+		t.Fatalf("doubtful report: %+v", a)
+	}
+	if a := got["n"]; a.Validation != processing.ValidationCorroborated || a.Confidence != a.ReporterTrust+(1-a.ReporterTrust)/2 {
+		t.Fatalf("corroborated report: %+v", a)
+	}
+	if a := got["m"]; a.Validation != "" || a.Confidence != 1 {
+		t.Fatalf("automatic alerts are not validated: %+v", a)
+	}
+}
@@ -0,0 +1,94 @@
+package server
+
+import (
+	"sync"
+)
+
+// Tipos de evento difundidos en tiempo real.
+const (
+	EventAlert      = "alert"
+	EventZoneStatus = "zone_status"
+	EventHeartbeat  = "heartbeat"
+	EventAck        = "ack"
+)
+
+// Event es un evento difundido a los clientes en tiempo real (SSE y WebSocket).
+// ID crece de forma monótona y permite reanudar con Last-Event-ID.
+type Event struct {
+	ID   uint64 `json:"id"`
+	Type string `json:"type"`
+	Data any    `json:"data"`
+}
+
+// ZoneStatusChange es el payload de un evento zone_status.
+type ZoneStatusChange struct {
+	Zone   string `json:"zona"`
+	Status string `json:"estado"`
+	Prev   string `json:"anterior"`
+}
+
+// Hub difunde eventos a los suscriptores conectados y conserva un historial
+// acotado para que un cliente que se reconecta recupere lo que se perdió.
+type Hub struct {
+	mu      sync.Mutex
+	nextID  uint64
+	history []Event // buffer circular de los últimos eventos
+	size    int
+	subs    map[chan Event]struct{}
+}
+
+// NewHub crea un hub que recuerda hasta historySize eventos.
+func NewHub(historySize int) *Hub {
+	if historySize <= 0 {
+		historySize = 1
+	}
+	return &Hub{size: historySize, subs: make(map[chan Event]struct{})}
+}
+
+// Publish asigna id al evento, lo guarda en el historial y lo entrega a cada
+// suscriptor. Un suscriptor con el buffer lleno se desconecta (se cierra su
+// canal) en lugar de bloquear al resto; puede reconectarse con su último id.
+func (h *Hub) Publish(typ string, data any) Event {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.nextID++
+	ev := Event{ID: h.nextID, Type: typ, Data: data}
+	if len(h.history) == h.size {
+		copy(h.history, h.history[1:])
+		h.history = h.history[:h.size-1]
+	}
+	h.history = append(h.history, ev)
+	for ch := range h.subs {
+		select {
+		case ch <- ev:
+		default:
+			delete(h.subs, ch)
+			close(ch)
+		}
+	}
+	return ev
+}
+
+// Subscribe registra un suscriptor con un buffer de buf eventos y devuelve los
+// eventos del historial posteriores a lastID. cancel debe llamarse al terminar.
+func (h *Hub) Subscribe(lastID uint64, buf int) (events <-chan Event, backlog []Event, cancel func()) {
+	ch := make(chan Event, buf)
+	h.mu.Lock()
+	for _, ev := range h.history {
+		if ev.ID > lastID {
+			backlog = append(backlog, ev)
+		}
+	}
+	h.subs[ch] = struct{}{}
+	h.mu.Unlock()
+
+	cancel = func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		if _, ok := h.subs[ch]; ok {
+			delete(h.subs, ch)
+			close(ch)
+		}
+	}
+	return ch, backlog, cancel
+}
@@ -0,0 +1,32 @@
+package server
+
+import "testing"
+
+func TestHubResumeFromLastEventID(t *testing.T) {
+	h := NewHub(3)
+	for i := 0; i < 5; i++ {
+		h.Publish(EventAlert, i)
+	}
+	// Historial de 3: ids 3, 4 y 5. Desde el 3 quedan 4 y 5.
+	_, backlog, cancel := h.Subscribe(3, 4)
+	defer cancel()
+	if len(backlog) != 2 || backlog[0].ID != 4 || backlog[1].ID != 5 {
+		t.Fatalf("unexpected backlog: %+v", backlog)
+	}
+}
+
+func TestHubDisconnectsSlowConsumer(t *testing.T) {
+	h := NewHub(10)
+	ch, _, cancel := h.Subscribe(0, 1)
+	defer cancel()
+
+	h.Publish(EventAlert, "a") // llena el buffer
+	h.Publish(EventAlert, "b") // desborda: el suscriptor se desconecta
+
+	if ev, ok := <-ch; !ok || ev.ID != 1 {
+		t.Fatalf("expected first event, got %+v ok=%v", ev, ok)
+	}
+	if _, ok := <-ch; ok {
+		t.Fatal("expected channel closed for slow consumer")
+	}
+}
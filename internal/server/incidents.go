@@ -0,0 +1,126 @@
+package server
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"alerta_climatica/internal/storage"
+)
+
+// GET /api/incidents: incidentes (alertas agrupadas por zona, fenómeno y
+// cercanía en el tiempo), más recientes primero. ?zona= filtra por zona.
+func (s *Server) handleIncidents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	list, err := s.state.ListIncidents(r.URL.Query().Get("zona"))
+	if err != nil {
+		log.Println("error listing incidents:", err)
+		http.Error(w, "error leyendo incidentes", http.StatusInternalServerError)
+		return
+	}
+	if list == nil {
+		list = []storage.Incident{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(list); err != nil {
+		log.Println("error serializando incidentes:", err)
+	}
+}
+
+// GET /api/incidents/{id}: un incidente con los ids de sus alertas.
+func (s *Server) handleIncident(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "id inválido", http.StatusBadRequest)
+		return
+	}
+	inc, err := s.state.GetIncident(id)
+	if err != nil {
+		writeIncidentError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(inc); err != nil {
+		log.Println("error serializando incidente:", err)
+	}
+}
+
+// POST /api/admin/incidents/merge: JSON {"destino": 1, "origen": [2, 3]} mueve
+// las alertas de los incidentes origen al destino y elimina los origen.
+func (s *Server) handleMergeIncidents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	var req struct {
+		Target  int64   `json:"destino"`
+		Sources []int64 `json:"origen"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Target == 0 || len(req.Sources) == 0 {
+		http.Error(w, "se requiere destino y origen", http.StatusBadRequest)
+		return
+	}
+	if err := s.state.MergeIncidents(req.Target, req.Sources); err != nil {
+		writeIncidentError(w, r, err)
+		return
+	}
+	s.writeIncident(w, r, req.Target, http.StatusOK)
+}
+
+// POST /api/admin/incidents/split: JSON {"id": 1, "alertas": ["..."]} separa
+// esas alertas en un incidente nuevo, que se devuelve.
+func (s *Server) handleSplitIncident(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	var req struct {
+		ID     int64    `json:"id"`
+		Alerts []string `json:"alertas"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.ID == 0 || len(req.Alerts) == 0 {
+		http.Error(w, "se requiere id y alertas", http.StatusBadRequest)
+		return
+	}
+	newID, err := s.state.SplitIncident(req.ID, req.Alerts)
+	if err != nil {
+		writeIncidentError(w, r, err)
+		return
+	}
+	s.writeIncident(w, r, newID, http.StatusCreated)
+}
+
+func (s *Server) writeIncident(w http.ResponseWriter, r *http.Request, id int64, status int) {
+	inc, err := s.state.GetIncident(id)
+	if err != nil {
+		writeIncidentError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(inc); err != nil {
+		log.Println("error serializando incidente:", err)
+	}
+}
+
+func writeIncidentError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		http.NotFound(w, r)
+	case errors.Is(err, storage.ErrInvalidIncidentOp):
+		http.Error(w, "operación inválida: las alertas deben pertenecer al incidente y debe quedar al menos una", http.StatusConflict)
+	default:
+		log.Println("error handling incident:", err)
+		http.Error(w, "error procesando incidente", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,183 @@
+package server
+
+import (
+	"crypto/subtle"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"alerta_climatica/internal/integrations/sms"
+	"alerta_climatica/internal/storage"
+)
+
+// Outbox resume la bandeja de salida: estadísticas por campaña y envíos.
+type Outbox struct {
+	Campaigns  []storage.CampaignStats `json:"campanias"`
+	Deliveries []storage.Delivery      `json:"envios"`
+}
+
+// Outbox devuelve la bandeja de salida, filtrada por campaña y/o estado.
+func (s *State) Outbox(campaign, status string) (Outbox, error) {
+	out := Outbox{Campaigns: []storage.CampaignStats{}, Deliveries: []storage.Delivery{}}
+	if s.store == nil {
+		return out, nil
+	}
+	var err error
+	if out.Campaigns, err = s.store.CampaignStats(campaign); err != nil {
+		return out, err
+	}
+	out.Deliveries, err = s.store.ListDeliveries(campaign, status)
+	return out, err
+}
+
+// SetDeliveryReporter registra la función que aplica los reportes de entrega
+// (normalmente Dispatcher.ReportDelivery, que reintenta los fallidos).
+func (s *State) SetDeliveryReporter(fn func(id int64, providerID, status, errText string, now time.Time) error) {
+	s.mu.Lock()
+	s.reportDelivery = fn
+	s.mu.Unlock()
+}
+
+// MarkDelivery aplica un reporte de entrega a un envío (por id o id del proveedor).
+func (s *State) MarkDelivery(id int64, providerID, status, errText string) error {
+	s.mu.RLock()
+	report := s.reportDelivery
+	s.mu.RUnlock()
+	if report != nil {
+		return report(id, providerID, status, errText, time.Now())
+	}
+	if s.store == nil {
+		return errors.New("bandeja de salida requiere almacenamiento")
+	}
+	return s.store.MarkDelivery(id, providerID, status, errText)
+}
+
+// deliveryStatus traduce el estado de un reporte de entrega, en español o
+// con los nombres habituales de los proveedores, a un estado de la bandeja.
+func deliveryStatus(v string) (string, bool) {
+	switch strings.ToLower(strings.TrimSpace(v)) {
+	case storage.DeliveryDelivered, "delivered", "delivrd":
+		return storage.DeliveryDelivered, true
+	case storage.DeliverySent, "sent", "accepted", "sending", "queued":
+		return storage.DeliverySent, true
+	case storage.DeliveryFailed, "failed", "undelivered", "undeliv", "rejected", "expired":
+		return storage.DeliveryFailed, true
+	}
+	return "", false
+}
+
+// GET /api/outbox: estadísticas por campaña y envíos SMS. ?campania= y
+// ?estado= filtran (en_cola, enviado, entregado, reintentando, fallido).
+func (s *Server) handleOutbox(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	q := r.URL.Query()
+	out, err := s.state.Outbox(q.Get("campania"), q.Get("estado"))
+	if err != nil {
+		log.Println("error listing outbox:", err)
+		http.Error(w, "error leyendo bandeja de salida", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(out); err != nil {
+		log.Println("error serializando bandeja de salida:", err)
+	}
+}
+
+// SetDeliveryAuth configura quién puede enviar reportes de entrega: los
+// callbacks firmados por Twilio (twilio verifica X-Twilio-Signature) y los
+// que traen Authorization: Bearer token. Sin ninguno, /api/sms/status
+// rechaza todos los reportes.
+func (s *Server) SetDeliveryAuth(twilio *sms.TwilioReceiver, token string) {
+	s.statusTwilio = twilio
+	s.statusToken = token
+}
+
+// deliveryAuthorized verifica un reporte de entrega; los de Twilio se
+// reconocen por su cabecera de firma y ya traen el formulario parseado.
+func (s *Server) deliveryAuthorized(r *http.Request) bool {
+	if r.Header.Get("X-Twilio-Signature") != "" {
+		return s.statusTwilio != nil && s.statusTwilio.Verify(r, r.PostForm)
+	}
+	if s.statusToken == "" {
+		return false
+	}
+	got := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return subtle.ConstantTimeCompare([]byte(got), []byte(s.statusToken)) == 1
+}
+
+// POST /api/sms/status: reporte de entrega de un proveedor. Acepta JSON
+// {"id": 12, "id_proveedor": "...", "estado": "entregado", "error": "..."} o
+// formulario con los mismos campos; también los de Twilio (MessageSid,
+// MessageStatus, ErrorCode). Exige firma de Twilio o token (ver SetDeliveryAuth).
+func (s *Server) handleDeliveryStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if r.Header.Get("X-Twilio-Signature") != "" {
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, "Formulario inválido", http.StatusBadRequest)
+			return
+		}
+	}
+	if !s.deliveryAuthorized(r) {
+		http.Error(w, "no autorizado", http.StatusUnauthorized)
+		return
+	}
+	var req struct {
+		ID         int64  `json:"id"`
+		ProviderID string `json:"id_proveedor"`
+		Status     string `json:"estado"`
+		Error      string `json:"error"`
+	}
+	if strings.Contains(r.Header.Get("Content-Type"), "application/json") {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "JSON inválido", http.StatusBadRequest)
+			return
+		}
+	} else {
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, "Formulario inválido", http.StatusBadRequest)
+			return
+		}
+		req.ID, _ = strconv.ParseInt(r.FormValue("id"), 10, 64)
+		req.ProviderID = firstNonEmpty(r.FormValue("id_proveedor"), r.FormValue("MessageSid"))
+		req.Status = firstNonEmpty(r.FormValue("estado"), r.FormValue("MessageStatus"))
+		req.Error = firstNonEmpty(r.FormValue("error"), r.FormValue("ErrorCode"))
+	}
+	status, ok := deliveryStatus(req.Status)
+	if !ok {
+		http.Error(w, "estado inválido", http.StatusBadRequest)
+		return
+	}
+	if status != storage.DeliveryFailed {
+		req.Error = ""
+	}
+	if err := s.state.MarkDelivery(req.ID, req.ProviderID, status, req.Error); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.NotFound(w, r)
+			return
+		}
+		log.Println("error updating delivery status:", err)
+		http.Error(w, "no se pudo actualizar el envío", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func firstNonEmpty(vals ...string) string {
+	for _, v := range vals {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
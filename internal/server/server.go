@@ -1,27 +1,42 @@
 package server
 
 import (
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"io"
 	"log"
 	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
+	"alerta_climatica/internal/integrations/sms"
+	"alerta_climatica/internal/notify"
 	"alerta_climatica/internal/processing"
+	"alerta_climatica/internal/storage"
 )
 
 // Server HTTP: sirve UI y API.
 type Server struct {
-	state *State
-	proc  *processing.Processor
-	mux   *http.ServeMux
+	state     *State
+	proc      *processing.Processor
+	mux       *http.ServeMux
+	heartbeat time.Duration // intervalo de heartbeat SSE
+	templates *notify.Renderer
+
+	statusTwilio *sms.TwilioReceiver // verifica reportes de entrega de Twilio
+	statusToken  string              // token de los demás reportes de entrega
+
+	done     chan struct{} // cerrado por CloseStreams para terminar SSE y WebSocket
+	doneOnce sync.Once
 }
 
 func NewServer(state *State, proc *processing.Processor) *Server {
-	s := &Server{state: state, proc: proc, mux: http.NewServeMux()}
+	s := &Server{state: state, proc: proc, mux: http.NewServeMux(), heartbeat: streamHeartbeat, templates: notify.NewRenderer(), done: make(chan struct{})}
 	s.routes()
 	// Semilla de demo para que la UI no esté vacía al iniciar.
 	s.state.Seed(time.Now())
@@ -30,6 +45,19 @@ func NewServer(state *State, proc *processing.Processor) *Server {
 
 func (s *Server) Router() http.Handler { return s.mux }
 
+// SetRenderer hace que la API de plantillas administre r, el mismo Renderer
+// que usa el Dispatcher para las difusiones.
+func (s *Server) SetRenderer(r *notify.Renderer) { s.templates = r }
+
+// CloseStreams termina las conexiones SSE y WebSocket abiertas.
+// http.Server.Shutdown no cancela peticiones de larga duración ni conexiones
+// secuestradas; registrarla con RegisterOnShutdown para que no consuman el
+// plazo de cierre.
+func (s *Server) CloseStreams() { s.doneOnce.Do(func() { close(s.done) }) }
+
+// Handle monta un handler adicional (p. ej. webhooks de proveedores SMS).
+func (s *Server) Handle(pattern string, h http.Handler) { s.mux.Handle(pattern, h) }
+
 func (s *Server) routes() {
 	// UI
 	s.mux.HandleFunc("/", s.handleIndex)
@@ -43,7 +71,25 @@ func (s *Server) routes() {
 	s.mux.HandleFunc("/api/zones", s.handleZones)
 	s.mux.HandleFunc("/api/zones_geojson", s.handleZonesGeoJSON)
 	s.mux.HandleFunc("/api/admin/import_zones", s.handleImportZones)
+	s.mux.HandleFunc("/api/admin/deadletter", s.handleDeadLetter)
+	s.mux.HandleFunc("/api/admin/subscriptions", s.handleSubscriptions)
+	s.mux.HandleFunc("/api/admin/reporters", s.handleReporters)
+	s.mux.HandleFunc("/api/admin/alerts/review", s.handleReviewAlert)
+	s.mux.HandleFunc("/api/admin/escalation_rules", s.handleEscalationRules)
+	s.mux.HandleFunc("/api/admin/suppressed", s.handleSuppressed)
+	s.mux.HandleFunc("/api/admin/blocklist", s.handleBlocklist)
+	s.mux.HandleFunc("/api/incidents", s.handleIncidents)
+	s.mux.HandleFunc("/api/incidents/{id}", s.handleIncident)
+	s.mux.HandleFunc("/api/admin/incidents/merge", s.handleMergeIncidents)
+	s.mux.HandleFunc("/api/admin/incidents/split", s.handleSplitIncident)
+	s.mux.HandleFunc("/api/admin/templates", s.handleTemplates)
+	s.mux.HandleFunc("/api/admin/templates/preview", s.handleTemplatePreview)
+	s.mux.HandleFunc("/api/outbox", s.handleOutbox)
+	s.mux.HandleFunc("/api/sms/status", s.handleDeliveryStatus)
 	s.mux.HandleFunc("/api/reset", s.handleReset)
+	s.mux.HandleFunc("/api/metrics", s.handleMetrics)
+	s.mux.HandleFunc("/api/stream", s.handleStream)
+	s.mux.HandleFunc("/api/ws", s.handleWS)
 }
 
 // GET /api/zones_geojson: devuelve el GeoJSON de zonas enriquecido con el estado actual.
@@ -138,6 +184,261 @@ func (s *Server) handleImportZones(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// GET /api/admin/deadletter: lista entradas dead-letter (?all=1 incluye resueltas).
+// POST /api/admin/deadletter: reintenta una entrada, JSON {"id": N} o formulario id=N.
+func (s *Server) handleDeadLetter(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		list, err := s.state.ListDeadLetters(r.URL.Query().Get("all") == "1")
+		if err != nil {
+			log.Println("error listing dead-letters:", err)
+			http.Error(w, "error leyendo dead-letters", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(list); err != nil {
+			log.Println("error serializando dead-letters:", err)
+		}
+	case http.MethodPost:
+		var req struct {
+			ID int64 `json:"id"`
+		}
+		if strings.Contains(r.Header.Get("Content-Type"), "application/json") {
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				http.Error(w, "JSON inválido", http.StatusBadRequest)
+				return
+			}
+		} else {
+			id, err := strconv.ParseInt(r.FormValue("id"), 10, 64)
+			if err != nil {
+				http.Error(w, "id inválido", http.StatusBadRequest)
+				return
+			}
+			req.ID = id
+		}
+		if err := s.state.ReplayDeadLetter(req.ID); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				http.NotFound(w, r)
+				return
+			}
+			if errors.Is(err, ErrDeadLetterBusy) {
+				http.Error(w, "reintento en curso", http.StatusConflict)
+				return
+			}
+			log.Printf("dead-letter %d replay failed: %v", req.ID, err)
+			http.Error(w, "reintento fallido: "+err.Error(), http.StatusBadGateway)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// CRUD de suscripciones SMS:
+//
+//	GET    /api/admin/subscriptions[?zona=]             lista (filtra por zona)
+//	POST   /api/admin/subscriptions {"telefono","zona"}  crea
+//	PUT    /api/admin/subscriptions {"id","telefono","zona"} actualiza
+//	DELETE /api/admin/subscriptions?id=N                 elimina
+func (s *Server) handleSubscriptions(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		ID    int64  `json:"id"`
+		Phone string `json:"telefono"`
+		Zone  string `json:"zona"`
+	}
+	if r.Method == http.MethodPost || r.Method == http.MethodPut {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "JSON inválido", http.StatusBadRequest)
+			return
+		}
+		req.Phone, req.Zone = strings.TrimSpace(req.Phone), strings.TrimSpace(req.Zone)
+		if req.Phone == "" || req.Zone == "" {
+			http.Error(w, "telefono y zona son obligatorios", http.StatusBadRequest)
+			return
+		}
+		phone, err := sms.NormalizeE164(req.Phone, sms.DefaultCountryCode)
+		if err != nil {
+			http.Error(w, "telefono inválido", http.StatusBadRequest)
+			return
+		}
+		req.Phone = phone
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		list, err := s.state.ListSubscriptions(r.URL.Query().Get("zona"))
+		if err != nil {
+			log.Println("error listing subscriptions:", err)
+			http.Error(w, "error leyendo suscripciones", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(list); err != nil {
+			log.Println("error serializando suscripciones:", err)
+		}
+	case http.MethodPost:
+		sub, err := s.state.AddSubscription(req.Phone, req.Zone)
+		if err != nil {
+			log.Println("error creating subscription:", err)
+			http.Error(w, "no se pudo crear la suscripción", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(sub)
+	case http.MethodPut:
+		sub := storage.Subscription{ID: req.ID, Phone: req.Phone, Zone: req.Zone}
+		if err := s.state.UpdateSubscription(sub); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				http.NotFound(w, r)
+				return
+			}
+			log.Println("error updating subscription:", err)
+			http.Error(w, "no se pudo actualizar la suscripción", http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	case http.MethodDelete:
+		id, err := strconv.ParseInt(r.URL.Query().Get("id"), 10, 64)
+		if err != nil {
+			http.Error(w, "id inválido", http.StatusBadRequest)
+			return
+		}
+		if err := s.state.DeleteSubscription(id); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				http.NotFound(w, r)
+				return
+			}
+			log.Println("error deleting subscription:", err)
+			http.Error(w, "no se pudo eliminar la suscripción", http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// GET /api/admin/reporters: remitentes con primer/último reporte, conteo,
+// nivel y confianza.
+// PUT /api/admin/reporters: JSON {"telefono": "...", "nombre": "...", "nivel": "vigia"}
+// asigna nombre visible y/o nivel ("comunitario" o "vigia"). Fijar el nivel
+// registra el número aunque aún no haya reportado.
+func (s *Server) handleReporters(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		list, err := s.state.ListReporters()
+		if err != nil {
+			log.Println("error listing reporters:", err)
+			http.Error(w, "error leyendo reporteros", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(list); err != nil {
+			log.Println("error serializando reporteros:", err)
+		}
+	case http.MethodPut:
+		var req struct {
+			Phone string  `json:"telefono"`
+			Name  *string `json:"nombre"`
+			Level *string `json:"nivel"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "JSON inválido", http.StatusBadRequest)
+			return
+		}
+		phone, err := sms.NormalizeE164(req.Phone, sms.DefaultCountryCode)
+		if err != nil {
+			http.Error(w, "telefono inválido", http.StatusBadRequest)
+			return
+		}
+		if req.Level != nil {
+			level := strings.ToLower(strings.TrimSpace(*req.Level))
+			if level != storage.TrustCommunity && level != storage.TrustMonitor {
+				http.Error(w, "nivel debe ser comunitario o vigia", http.StatusBadRequest)
+				return
+			}
+			if err := s.state.SetReporterLevel(phone, level); err != nil {
+				log.Println("error updating reporter level:", err)
+				http.Error(w, "no se pudo actualizar el reportero", http.StatusInternalServerError)
+				return
+			}
+		}
+		if req.Name != nil {
+			if err := s.state.SetReporterName(phone, strings.TrimSpace(*req.Name)); err != nil {
+				if errors.Is(err, sql.ErrNoRows) {
+					http.NotFound(w, r)
+					return
+				}
+				log.Println("error updating reporter:", err)
+				http.Error(w, "no se pudo actualizar el reportero", http.StatusInternalServerError)
+				return
+			}
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// POST /api/admin/alerts/review: JSON {"id": "...", "resultado": "confirmada"|"descartada"}.
+// La revisión ajusta la confianza del remitente de la alerta.
+func (s *Server) handleReviewAlert(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	var req struct {
+		ID     string `json:"id"`
+		Result string `json:"resultado"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "JSON inválido", http.StatusBadRequest)
+		return
+	}
+	if req.ID == "" || (req.Result != storage.ReviewConfirmed && req.Result != storage.ReviewDismissed) {
+		http.Error(w, "se requiere id y resultado (confirmada o descartada)", http.StatusBadRequest)
+		return
+	}
+	if err := s.state.ReviewAlert(req.ID, req.Result); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.NotFound(w, r)
+			return
+		}
+		log.Println("error reviewing alert:", err)
+		http.Error(w, "no se pudo registrar la revisión", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GET /api/admin/escalation_rules: reglas de corroboración vigentes.
+// PUT /api/admin/escalation_rules: reemplaza las reglas con un arreglo JSON de
+// {"zona", "fenomeno", "severidad", "reportantes", "ventana_min", "estado"}.
+func (s *Server) handleEscalationRules(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(s.state.EscalationRules()); err != nil {
+			log.Println("error serializando reglas:", err)
+		}
+	case http.MethodPut:
+		var rules []EscalationRule
+		if err := json.NewDecoder(r.Body).Decode(&rules); err != nil {
+			http.Error(w, "JSON inválido", http.StatusBadRequest)
+			return
+		}
+		if err := s.state.SetEscalationRules(rules); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
 // GET /
 func (s *Server) handleIndex(w http.ResponseWriter, r *http.Request) {
 	if r.URL.Path != "/" {
@@ -147,8 +448,15 @@ func (s *Server) handleIndex(w http.ResponseWriter, r *http.Request) {
 	http.ServeFile(w, r, filepath.Join("web", "index.html"))
 }
 
+// serverFields son campos de processing.IncomingMessage que solo asignan los
+// receptores (canal, partes de un SMS concatenado); /api/sms los rechaza para
+// que un envío web no pase por tráfico de un gateway.
+var serverFields = []string{"canal", "ref_concat", "parte", "partes"}
+
 // POST /api/sms: recibe JSON o application/x-www-form-urlencoded con campos
-// "texto" y "zona". Encola el mensaje para procesamiento concurrente.
+// "texto" y "zona". El canal lo fija el servidor: "api" para JSON, "web" para
+// formularios. Un "telefono" enviado se ignora: nada autentica al remitente,
+// así que el reporte es anónimo. Encola el mensaje para procesamiento concurrente.
 func (s *Server) handleSMS(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		w.WriteHeader(http.StatusMethodNotAllowed)
@@ -159,24 +467,51 @@ func (s *Server) handleSMS(w http.ResponseWriter, r *http.Request) {
 
 	ct := r.Header.Get("Content-Type")
 	if strings.Contains(ct, "application/json") {
-		if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		var fields map[string]json.RawMessage
+		if err := json.NewDecoder(r.Body).Decode(&fields); err != nil {
 			http.Error(w, "JSON inválido", http.StatusBadRequest)
 			return
 		}
+		for _, f := range serverFields {
+			if _, ok := fields[f]; ok {
+				http.Error(w, "campo no permitido: "+f, http.StatusBadRequest)
+				return
+			}
+		}
+		for key, dst := range map[string]*string{"zona": &in.Zone, "texto": &in.Text} {
+			if raw, ok := fields[key]; ok {
+				if err := json.Unmarshal(raw, dst); err != nil {
+					http.Error(w, "JSON inválido", http.StatusBadRequest)
+					return
+				}
+			}
+		}
+		in.Channel = "api"
 	} else {
 		if err := r.ParseForm(); err != nil {
 			http.Error(w, "Formulario inválido", http.StatusBadRequest)
 			return
 		}
+		for _, f := range serverFields {
+			if r.Form.Has(f) {
+				http.Error(w, "campo no permitido: "+f, http.StatusBadRequest)
+				return
+			}
+		}
 		in.Zone = r.FormValue("zona")
 		in.Text = r.FormValue("texto")
+		in.Channel = "web"
 	}
 	if strings.TrimSpace(in.Zone) == "" {
 		in.Zone = "Zona Centro" // por defecto
 	}
 	in.ReceivedAt = time.Now()
 
-	s.proc.Submit(in)
+	if err := s.proc.Submit(in); err != nil {
+		log.Println("sms rejected:", err)
+		http.Error(w, "servicio cerrándose, reintente", http.StatusServiceUnavailable)
+		return
+	}
 	w.Header().Set("Content-Type", "application/json")
 	_ = json.NewEncoder(w).Encode(map[string]any{"status": "enviado"})
 }
@@ -208,3 +543,12 @@ func (s *Server) handleReset(w http.ResponseWriter, r *http.Request) {
 	s.state.ResetZones()
 	w.WriteHeader(http.StatusNoContent)
 }
+
+// GET /api/metrics: contadores del procesador (mensajes, pánicos, reinicios de workers).
+func (s *Server) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	m := map[string]any{"processor": s.proc.Stats()}
+	if err := json.NewEncoder(w).Encode(m); err != nil {
+		log.Println("error serializando metrics:", err)
+	}
+}
@@ -1,13 +1,21 @@
 package server_test
 
 import (
+	"bufio"
 	"bytes"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strings"
 	"testing"
 	"time"
 
+	"alerta_climatica/internal/integrations/sms"
 	"alerta_climatica/internal/processing"
 	srvpkg "alerta_climatica/internal/server"
 	"alerta_climatica/internal/storage"
@@ -80,3 +88,213 @@ func TestEndToEnd(t *testing.T) {
 		t.Fatal("alert not found in GET /api/alerts within timeout")
 	}
 }
+
+// TestStreamPushesAlerts se suscribe a GET /api/stream y envía un reporte web
+// con el número de un vigía, que cuenta como anónimo (zona pendiente), y luego
+// un SMS verificado del vigía, que escala la zona a amarillo.
+func TestStreamPushesAlerts(t *testing.T) {
+	store, err := storage.NewSQLite(t.TempDir() + "/stream.db")
+	if err != nil {
+		t.Fatalf("failed to open sqlite: %v", err)
+	}
+	defer store.Close()
+
+	st := srvpkg.NewState(store)
+	proc := processing.NewProcessor(nil, st.AddAlert)
+	proc.StartWorkers(1)
+	defer proc.Close()
+
+	ts := httptest.NewServer(srvpkg.NewServer(st, proc).Router())
+	defer ts.Close()
+
+	// Un vigía escala sin necesidad de corroboración.
+	req, _ := http.NewRequest(http.MethodPut, ts.URL+"/api/admin/reporters",
+		strings.NewReader(`{"telefono":"987654321","nivel":"vigia"}`))
+	put, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("put reporter failed: %v", err)
+	}
+	put.Body.Close()
+	if put.StatusCode != http.StatusNoContent {
+		t.Fatalf("unexpected status registering vigía: %d", put.StatusCode)
+	}
+
+	resp, err := http.Get(ts.URL + "/api/stream")
+	if err != nil {
+		t.Fatalf("stream request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("unexpected content type %q", ct)
+	}
+
+	b, _ := json.Marshal(map[string]string{"zona": "Zona Sur", "texto": "Se reporta desborde del río", "telefono": "987654321"})
+	post, err := http.Post(ts.URL+"/api/sms", "application/json", bytes.NewReader(b))
+	if err != nil {
+		t.Fatalf("post request failed: %v", err)
+	}
+	post.Body.Close()
+
+	events := make(chan string, 8)
+	go func() {
+		sc := bufio.NewScanner(resp.Body)
+		for sc.Scan() {
+			if line := sc.Text(); strings.HasPrefix(line, "event: ") || strings.HasPrefix(line, "data: ") {
+				events <- line
+			}
+		}
+		close(events)
+	}()
+
+	var got []string
+	timeout := time.After(3 * time.Second)
+	next := func(n int) {
+		for len(got) < n {
+			select {
+			case line, ok := <-events:
+				if !ok {
+					t.Fatalf("stream closed early, got %v", got)
+				}
+				got = append(got, line)
+			case <-timeout:
+				t.Fatalf("timeout waiting for events, got %v", got)
+			}
+		}
+	}
+	next(4)
+	if got[0] != "event: alert" || !strings.Contains(got[1], `"zona":"Zona Sur"`) || strings.Contains(got[1], "telefono") {
+		t.Fatalf("expected anonymous alert event first, got %v", got)
+	}
+	if got[2] != "event: zone_status" || !strings.Contains(got[3], `"estado":"pendiente"`) {
+		t.Fatalf("web report with a vigía's number escalated the zone: %v", got)
+	}
+
+	if err := proc.Submit(processing.IncomingMessage{Zone: "Zona Sur", Text: "Se reporta desborde del río", From: "+51987654321", Channel: "sms", Verified: true, ReceivedAt: time.Now()}); err != nil {
+		t.Fatalf("submit failed: %v", err)
+	}
+	next(8)
+	if got[4] != "event: alert" || !strings.Contains(got[5], `"telefono":"+51987654321"`) {
+		t.Fatalf("expected verified alert event, got %v", got)
+	}
+	if got[6] != "event: zone_status" || !strings.Contains(got[7], `"estado":"amarillo"`) {
+		t.Fatalf("expected zone_status amarillo, got %v", got)
+	}
+}
+
+// TestDeliveryStatusCallback aplica un reporte de entrega estilo Twilio y
+// verifica el resultado en GET /api/outbox.
+func TestDeliveryStatusCallback(t *testing.T) {
+	store, err := storage.NewSQLite(t.TempDir() + "/outbox.db")
+	if err != nil {
+		t.Fatalf("failed to open sqlite: %v", err)
+	}
+	defer store.Close()
+	if _, err := store.SaveDelivery(storage.Delivery{Campaign: "c1", Phone: "+51911111111", Zone: "Zona Sur", Status: storage.DeliverySent, ProviderID: "SM123"}); err != nil {
+		t.Fatalf("SaveDelivery failed: %v", err)
+	}
+
+	st := srvpkg.NewState(store)
+	proc := processing.NewProcessor(nil, st.AddAlert)
+	srv := srvpkg.NewServer(st, proc)
+	srv.SetDeliveryAuth(sms.NewTwilioReceiver("twilio-token", "", nil), "gateway-token")
+	ts := httptest.NewServer(srv.Router())
+	defer ts.Close()
+
+	// send publica un reporte; sign firma el cuerpo como lo hace Twilio
+	// (HMAC-SHA1 de la URL más los parámetros ordenados) y bearer lo
+	// acompaña del token de gateways.
+	send := func(body, sig, bearer string) int {
+		req, _ := http.NewRequest(http.MethodPost, ts.URL+"/api/sms/status", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if sig != "" {
+			req.Header.Set("X-Twilio-Signature", sig)
+		}
+		if bearer != "" {
+			req.Header.Set("Authorization", "Bearer "+bearer)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("post status failed: %v", err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	sign := func(body string) string {
+		params, _ := url.ParseQuery(body)
+		keys := make([]string, 0, len(params))
+		for k := range params {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		data := ts.URL + "/api/sms/status"
+		for _, k := range keys {
+			data += k + params.Get(k)
+		}
+		mac := hmac.New(sha1.New, []byte("twilio-token"))
+		mac.Write([]byte(data))
+		return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+	}
+	post := func(body string) int { return send(body, sign(body), "") }
+
+	if code := send("MessageSid=SM123&MessageStatus=delivered", "", ""); code != http.StatusUnauthorized {
+		t.Fatalf("unsigned report: got %d", code)
+	}
+	if code := send("MessageSid=SM123&MessageStatus=delivered", sign("MessageSid=SM123&MessageStatus=failed"), ""); code != http.StatusUnauthorized {
+		t.Fatalf("bad signature: got %d", code)
+	}
+	if code := send("id_proveedor=SM123&estado=entregado", "", "otro"); code != http.StatusUnauthorized {
+		t.Fatalf("wrong token: got %d", code)
+	}
+	if code := send("MessageSid=SM999&MessageStatus=delivered", "", "gateway-token"); code != http.StatusNotFound {
+		t.Fatalf("gateway report with token: got %d", code)
+	}
+	if code := post("MessageSid=SM123&MessageStatus=delivered"); code != http.StatusNoContent {
+		t.Fatalf("status callback: got %d", code)
+	}
+	if code := post("MessageSid=SM999&MessageStatus=delivered"); code != http.StatusNotFound {
+		t.Fatalf("unknown message: got %d", code)
+	}
+	if code := post("MessageSid=SM123&MessageStatus=perdido"); code != http.StatusBadRequest {
+		t.Fatalf("invalid status: got %d", code)
+	}
+
+	resp, err := http.Get(ts.URL + "/api/outbox?campania=c1")
+	if err != nil {
+		t.Fatalf("get outbox failed: %v", err)
+	}
+	defer resp.Body.Close()
+	var out srvpkg.Outbox
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		t.Fatalf("decode outbox: %v", err)
+	}
+	if len(out.Deliveries) != 1 || out.Deliveries[0].Status != storage.DeliveryDelivered ||
+		len(out.Campaigns) != 1 || out.Campaigns[0].Delivered != 1 {
+		t.Fatalf("unexpected outbox %+v", out)
+	}
+}
+
+// TestSMSRejectsServerFields verifica que /api/sms no acepta campos que solo
+// asignan los receptores de SMS.
+func TestSMSRejectsServerFields(t *testing.T) {
+	st := srvpkg.NewState(nil)
+	proc := processing.NewProcessor(nil, st.AddAlert)
+	ts := httptest.NewServer(srvpkg.NewServer(st, proc).Router())
+	defer ts.Close()
+
+	cases := []struct{ ct, body string }{
+		{"application/json", `{"zona": "Zona Sur", "texto": "desborde", "canal": "sms"}`},
+		{"application/json", `{"zona": "Zona Sur", "texto": "desborde", "ref_concat": 7, "parte": 1, "partes": 2}`},
+		{"application/x-www-form-urlencoded", "zona=Zona+Sur&texto=desborde&canal=sms"},
+		{"application/x-www-form-urlencoded", "zona=Zona+Sur&texto=desborde&partes=2"},
+	}
+	for _, c := range cases {
+		resp, err := http.Post(ts.URL+"/api/sms", c.ct, strings.NewReader(c.body))
+		if err != nil {
+			t.Fatalf("post failed: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("%s: got %d, want 400", c.body, resp.StatusCode)
+		}
+	}
+}
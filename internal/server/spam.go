@@ -0,0 +1,126 @@
+package server
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"alerta_climatica/internal/integrations/sms"
+	"alerta_climatica/internal/storage"
+)
+
+// ListSuppressed devuelve los mensajes descartados por el filtro anti-spam.
+func (s *State) ListSuppressed(reason string) ([]storage.SuppressedMessage, error) {
+	if s.store == nil {
+		return nil, nil
+	}
+	return s.store.ListSuppressed(reason)
+}
+
+// ListBlocked devuelve la lista de bloqueo.
+func (s *State) ListBlocked() ([]storage.BlockedSender, error) {
+	if s.store == nil {
+		return nil, nil
+	}
+	return s.store.ListBlocked()
+}
+
+// BlockSender agrega un número a la lista de bloqueo.
+func (s *State) BlockSender(phone, reason string) (storage.BlockedSender, error) {
+	b := storage.BlockedSender{Phone: phone, Reason: reason, CreatedAt: time.Now()}
+	if s.store == nil {
+		return b, errors.New("lista de bloqueo requiere almacenamiento")
+	}
+	return b, s.store.BlockSender(b)
+}
+
+// UnblockSender quita un número de la lista de bloqueo.
+func (s *State) UnblockSender(phone string) error {
+	if s.store == nil {
+		return errors.New("lista de bloqueo requiere almacenamiento")
+	}
+	return s.store.UnblockSender(phone)
+}
+
+// GET /api/admin/suppressed: mensajes descartados (duplicados, cadenas,
+// exceso de envíos o remitentes bloqueados). ?motivo= filtra por motivo.
+func (s *Server) handleSuppressed(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	list, err := s.state.ListSuppressed(r.URL.Query().Get("motivo"))
+	if err != nil {
+		log.Println("error listing suppressed messages:", err)
+		http.Error(w, "error leyendo mensajes descartados", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(list); err != nil {
+		log.Println("error serializando mensajes descartados:", err)
+	}
+}
+
+// GET /api/admin/blocklist: números bloqueados.
+// POST /api/admin/blocklist: JSON {"telefono": "...", "motivo": "..."} bloquea un número.
+// DELETE /api/admin/blocklist?telefono=...: lo desbloquea.
+func (s *Server) handleBlocklist(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		list, err := s.state.ListBlocked()
+		if err != nil {
+			log.Println("error listing blocklist:", err)
+			http.Error(w, "error leyendo lista de bloqueo", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(list); err != nil {
+			log.Println("error serializando lista de bloqueo:", err)
+		}
+	case http.MethodPost:
+		var req struct {
+			Phone  string `json:"telefono"`
+			Reason string `json:"motivo"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "JSON inválido", http.StatusBadRequest)
+			return
+		}
+		phone, err := sms.NormalizeE164(req.Phone, sms.DefaultCountryCode)
+		if err != nil {
+			http.Error(w, "telefono inválido", http.StatusBadRequest)
+			return
+		}
+		b, err := s.state.BlockSender(phone, strings.TrimSpace(req.Reason))
+		if err != nil {
+			log.Println("error blocking sender:", err)
+			http.Error(w, "no se pudo bloquear el número", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(b)
+	case http.MethodDelete:
+		phone, err := sms.NormalizeE164(r.URL.Query().Get("telefono"), sms.DefaultCountryCode)
+		if err != nil {
+			http.Error(w, "telefono inválido", http.StatusBadRequest)
+			return
+		}
+		if err := s.state.UnblockSender(phone); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				http.NotFound(w, r)
+				return
+			}
+			log.Println("error unblocking sender:", err)
+			http.Error(w, "no se pudo desbloquear el número", http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
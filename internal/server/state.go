@@ -1,6 +1,7 @@
 package server
 
 import (
+	"errors"
 	"log"
 	"sort"
 	"sync"
@@ -14,17 +15,66 @@ import (
 type State struct {
 	mu         sync.RWMutex
 	alerts     []processing.Alert
-	zoneStatus map[string]string // zona -> color ("verde", "amarillo", "rojo")
+	zoneStatus map[string]string // zona -> color ("verde", "pendiente", "amarillo", "rojo")
 	store      storage.Store
+	events     *Hub // difusión en tiempo real (SSE)
+	zoneHooks  []func(ZoneStatusChange, processing.Alert)
+	alertHooks []func(processing.Alert)
+	rules      []EscalationRule // reglas de corroboración para escalar zonas
+
+	replay      func(processing.IncomingMessage) error  // reinyección de dead-letters
+	weather     func(processing.Alert) (string, string) // validación meteorológica de reportes
+	pendingDead []storage.DeadLetter                    // dead-letters aún no persistidos
+	lastTempID  int64                                   // ids temporales (negativos) de pendingDead
+	deadBusy    map[int64]bool                          // dead-letters con un reintento en curso
+
+	// reportes de entrega SMS (normalmente Dispatcher.ReportDelivery)
+	reportDelivery func(id int64, providerID, status, errText string, now time.Time) error
 }
 
 // NewState crea el estado y puede recibir un storage.Store (nil para solo memoria).
 func NewState(store storage.Store) *State {
-	return &State{
+	s := &State{
 		alerts:     make([]processing.Alert, 0, 256),
 		zoneStatus: map[string]string{"Zona Norte": "verde", "Zona Centro": "verde", "Zona Sur": "verde"},
 		store:      store,
+		events:     NewHub(256),
+		deadBusy:   make(map[int64]bool),
 	}
+	if err := s.SetEscalationRules(DefaultEscalationRules()); err != nil {
+		panic(err) // las reglas por defecto son fijas
+	}
+	return s
+}
+
+// OnZoneChange registra un callback invocado cuando una alerta cambia el color
+// de una zona (p. ej. para difundir SMS a los suscriptores).
+func (s *State) OnZoneChange(fn func(ZoneStatusChange, processing.Alert)) {
+	s.mu.Lock()
+	s.zoneHooks = append(s.zoneHooks, fn)
+	s.mu.Unlock()
+}
+
+// SetWeatherCheck registra la validación meteorológica de los reportes
+// ciudadanos (normalmente meteo.Validator.Check). Devuelve la validación y el
+// dato observado; su resultado ajusta la confianza de la alerta.
+func (s *State) SetWeatherCheck(fn func(processing.Alert) (validation, note string)) {
+	s.mu.Lock()
+	s.weather = fn
+	s.mu.Unlock()
+}
+
+// OnAlert registra un callback invocado con cada alerta nueva, después de
+// persistirla (p. ej. para agruparla en incidentes).
+func (s *State) OnAlert(fn func(processing.Alert)) {
+	s.mu.Lock()
+	s.alertHooks = append(s.alertHooks, fn)
+	s.mu.Unlock()
+}
+
+// Events devuelve el hub por el que se difunden alertas y cambios de estado de zona.
+func (s *State) Events() *Hub {
+	return s.events
 }
 
 // ImportZones importa un FeatureCollection GeoJSON al store si está disponible.
@@ -43,32 +93,217 @@ func (s *State) ListStoredZones() ([]storage.Zone, error) {
 	return s.store.ListZones()
 }
 
+// AddSubscription suscribe un teléfono a las difusiones SMS de una zona.
+func (s *State) AddSubscription(phone, zone string) (storage.Subscription, error) {
+	sub := storage.Subscription{Phone: phone, Zone: zone, CreatedAt: time.Now()}
+	if s.store == nil {
+		return sub, errors.New("suscripciones requieren almacenamiento")
+	}
+	id, err := s.store.AddSubscription(sub)
+	sub.ID = id
+	return sub, err
+}
+
+// ListSubscriptions devuelve las suscripciones de una zona (todas si zone es "").
+func (s *State) ListSubscriptions(zone string) ([]storage.Subscription, error) {
+	if s.store == nil {
+		return nil, nil
+	}
+	return s.store.ListSubscriptions(zone)
+}
+
+// UpdateSubscription cambia teléfono/zona de una suscripción existente.
+func (s *State) UpdateSubscription(sub storage.Subscription) error {
+	if s.store == nil {
+		return errors.New("suscripciones requieren almacenamiento")
+	}
+	return s.store.UpdateSubscription(sub)
+}
+
+// DeleteSubscription elimina una suscripción por id.
+func (s *State) DeleteSubscription(id int64) error {
+	if s.store == nil {
+		return errors.New("suscripciones requieren almacenamiento")
+	}
+	return s.store.DeleteSubscription(id)
+}
+
+// ListReporters devuelve los remitentes conocidos con su actividad.
+func (s *State) ListReporters() ([]storage.Reporter, error) {
+	if s.store == nil {
+		return nil, nil
+	}
+	return s.store.ListReporters()
+}
+
+// SetReporterName asigna un nombre visible a un remitente.
+func (s *State) SetReporterName(phone, name string) error {
+	if s.store == nil {
+		return errors.New("reporteros requieren almacenamiento")
+	}
+	return s.store.SetReporterName(phone, name)
+}
+
+// SetReporterLevel fija el nivel de confianza de un número ("comunitario" o "vigia").
+func (s *State) SetReporterLevel(phone, level string) error {
+	if s.store == nil {
+		return errors.New("reporteros requieren almacenamiento")
+	}
+	return s.store.SetReporterLevel(phone, level)
+}
+
+// ReviewAlert registra la revisión de un operador ("confirmada" o "descartada")
+// sobre una alerta; el resultado ajusta la confianza de su remitente.
+func (s *State) ReviewAlert(id, result string) error {
+	if s.store == nil {
+		return errors.New("revisiones requieren almacenamiento")
+	}
+	if err := s.store.ReviewAlert(id, result); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	for i := range s.alerts {
+		if s.alerts[i].ID == id {
+			s.alerts[i].Review = result
+		}
+	}
+	s.mu.Unlock()
+	return nil
+}
+
+// ListIncidents devuelve los incidentes de una zona (todas si zone es "").
+func (s *State) ListIncidents(zone string) ([]storage.Incident, error) {
+	if s.store == nil {
+		return nil, nil
+	}
+	return s.store.ListIncidents(zone)
+}
+
+// GetIncident devuelve un incidente con sus alertas vinculadas.
+func (s *State) GetIncident(id int64) (storage.Incident, error) {
+	if s.store == nil {
+		return storage.Incident{}, errors.New("incidentes requieren almacenamiento")
+	}
+	return s.store.GetIncident(id)
+}
+
+// MergeIncidents fusiona los incidentes sources en target.
+func (s *State) MergeIncidents(target int64, sources []int64) error {
+	if s.store == nil {
+		return errors.New("incidentes requieren almacenamiento")
+	}
+	return s.store.MergeIncidents(target, sources)
+}
+
+// SplitIncident separa alertas de un incidente en uno nuevo y devuelve su id.
+func (s *State) SplitIncident(id int64, alertIDs []string) (int64, error) {
+	if s.store == nil {
+		return 0, errors.New("incidentes requieren almacenamiento")
+	}
+	return s.store.SplitIncident(id, alertIDs)
+}
+
+// ZoneNames devuelve los nombres de zona conocidos: los del estado en memoria
+// y los almacenados en la DB.
+func (s *State) ZoneNames() []string {
+	seen := make(map[string]bool)
+	s.mu.RLock()
+	for z := range s.zoneStatus {
+		seen[z] = true
+	}
+	s.mu.RUnlock()
+	if zl, err := s.ListStoredZones(); err == nil {
+		for _, z := range zl {
+			if z.Name != "" {
+				seen[z.Name] = true
+			}
+		}
+	}
+	out := make([]string, 0, len(seen))
+	for z := range seen {
+		out = append(out, z)
+	}
+	sort.Strings(out)
+	return out
+}
+
 // AddAlert agrega una alerta y actualiza el estado de la zona.
-// Si se dispone de store, intenta persistir la alerta en segundo plano (de forma no bloqueante).
+// Si se dispone de store, primero persiste la alerta. Si falla, solo la
+// persistencia va a dead-letter: una caída de la base no debe silenciar un
+// aviso, así que la alerta igual cambia el estado, se difunde y dispara los
+// hooks (p. ej. la difusión SMS).
 func (s *State) AddAlert(a processing.Alert) {
-	// Actualizar estado en memoria rápidamente
+	// La confianza se consulta fuera del lock; las fuentes que ya la traen
+	// (sensores, vigías ya resueltos) no se sobrescriben.
+	if a.ReporterTrust == 0 {
+		a.ReporterTrust = s.reporterTrust(a)
+	}
+	// Solo los reportes ciudadanos se contrastan con el tiempo observado.
+	s.mu.RLock()
+	check := s.weather
+	s.mu.RUnlock()
+	if check != nil && a.Source == "" && a.Validation == "" {
+		a.Validation, a.WeatherNote = check(a)
+	}
+	a.Confidence = alertConfidence(a.ReporterTrust, a.Validation)
+
+	// Persistir de forma síncrona para garantizar durabilidad.
+	if s.store != nil {
+		if err := s.store.SaveAlert(a); err != nil {
+			log.Println("warning: failed to persist alert, sending to dead-letter:", err)
+			s.addDeadLetter(storage.DeadLetterPersist, a, err, 1)
+		}
+	}
+	s.apply(a)
+}
+
+// apply incorpora una alerta: actualiza el estado en memoria, publica los
+// eventos y ejecuta los hooks.
+func (s *State) apply(a processing.Alert) {
 	s.mu.Lock()
 	s.alerts = append(s.alerts, a)
 	if len(s.alerts) > 500 {
 		s.alerts = s.alerts[len(s.alerts)-500:]
 	}
-	switch a.Severity {
-	case "crítica":
-		s.zoneStatus[a.Zone] = "rojo"
-	case "alta":
-		if s.zoneStatus[a.Zone] != "rojo" {
-			s.zoneStatus[a.Zone] = "amarillo"
-		}
-	default:
+	prev := s.zoneStatus[a.Zone]
+	if next := s.escalationLocked(a); processing.StatusRank(next) > processing.StatusRank(prev) {
+		s.zoneStatus[a.Zone] = next
 	}
+	cur := s.zoneStatus[a.Zone]
+	hooks := s.zoneHooks
+	alertHooks := s.alertHooks
 	s.mu.Unlock()
 
-	// Persistir de forma síncrona para garantizar durabilidad.
+	if s.store != nil && a.Reporter != "" {
+		if err := s.store.TouchReporter(a.Reporter, a.Timestamp); err != nil {
+			log.Println("warning: failed to update reporter:", err)
+		}
+	}
+
+	s.events.Publish(EventAlert, a)
+	if cur != prev {
+		change := ZoneStatusChange{Zone: a.Zone, Status: cur, Prev: prev}
+		s.events.Publish(EventZoneStatus, change)
+		for _, fn := range hooks {
+			fn(change, a)
+		}
+	}
+	for _, fn := range alertHooks {
+		fn(a)
+	}
+}
+
+// AckAlert registra el acuse de un operador sobre una alerta y lo difunde a
+// los demás paneles conectados.
+func (s *State) AckAlert(alertID, operator string) (storage.AlertAck, error) {
+	ack := storage.AlertAck{AlertID: alertID, Operator: operator, AckedAt: time.Now()}
 	if s.store != nil {
-		if err := s.store.SaveAlert(a); err != nil {
-			log.Println("warning: failed to persist alert:", err)
+		if err := s.store.SaveAck(ack); err != nil {
+			return ack, err
 		}
 	}
+	s.events.Publish(EventAck, ack)
+	return ack, nil
 }
 
 // Close espera que las persistencias pendientes terminen y cierra el store si existe.
@@ -111,10 +346,17 @@ func (s *State) Zones() map[string]string {
 // ResetZones permite resetear niveles a verde (útil en demo).
 func (s *State) ResetZones() {
 	s.mu.Lock()
-	defer s.mu.Unlock()
-	for k := range s.zoneStatus {
+	var changes []ZoneStatusChange
+	for k, v := range s.zoneStatus {
+		if v != "verde" {
+			changes = append(changes, ZoneStatusChange{Zone: k, Status: "verde", Prev: v})
+		}
 		s.zoneStatus[k] = "verde"
 	}
+	s.mu.Unlock()
+	for _, c := range changes {
+		s.events.Publish(EventZoneStatus, c)
+	}
 }
 
 // Seed agrega algunas alertas de ejemplo (para la primera carga de UI).
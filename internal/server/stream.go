@@ -0,0 +1,105 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Intervalo de heartbeat SSE; mantiene viva la conexión a través de proxies.
+const streamHeartbeat = 15 * time.Second
+
+// GET /api/stream: Server-Sent Events con eventos "alert", "zone_status" y
+// "heartbeat". Con la cabecera Last-Event-ID (o ?lastEventId=) se reenvían los
+// eventos posteriores que sigan en el historial del hub.
+func (s *Server) handleStream(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming no soportado", http.StatusInternalServerError)
+		return
+	}
+
+	lastID := r.Header.Get("Last-Event-ID")
+	if lastID == "" {
+		lastID = r.URL.Query().Get("lastEventId")
+	}
+	var since uint64
+	if lastID != "" {
+		n, err := strconv.ParseUint(lastID, 10, 64)
+		if err != nil {
+			http.Error(w, "Last-Event-ID inválido", http.StatusBadRequest)
+			return
+		}
+		since = n
+	} else {
+		// Cliente nuevo: solo eventos a partir de ahora.
+		since = ^uint64(0)
+	}
+
+	events, backlog, cancel := s.state.Events().Subscribe(since, 32)
+	defer cancel()
+
+	h := w.Header()
+	h.Set("Content-Type", "text/event-stream")
+	h.Set("Cache-Control", "no-cache")
+	h.Set("Connection", "keep-alive")
+	h.Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, "retry: 3000\n\n")
+	for _, ev := range backlog {
+		if err := writeSSE(w, ev); err != nil {
+			return
+		}
+	}
+	flusher.Flush()
+
+	hb := time.NewTicker(s.heartbeat)
+	defer hb.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-s.done:
+			return
+		case ev, ok := <-events:
+			if !ok {
+				// Cliente lento desconectado por el hub; reconectará con su último id.
+				return
+			}
+			if err := writeSSE(w, ev); err != nil {
+				return
+			}
+			flusher.Flush()
+		case now := <-hb.C:
+			// El heartbeat no lleva id para no alterar el punto de reanudación.
+			if err := writeSSE(w, Event{Type: EventHeartbeat, Data: map[string]any{"ts": now.UTC()}}); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+// writeSSE escribe un evento en formato text/event-stream.
+func writeSSE(w io.Writer, ev Event) error {
+	data, err := json.Marshal(ev.Data)
+	if err != nil {
+		log.Println("error serializando evento:", err)
+		return nil
+	}
+	if ev.ID != 0 {
+		if _, err := fmt.Fprintf(w, "id: %d\n", ev.ID); err != nil {
+			return err
+		}
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", ev.Type, data)
+	return err
+}
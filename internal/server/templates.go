@@ -0,0 +1,86 @@
+package server
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"alerta_climatica/internal/notify"
+)
+
+// GET /api/admin/templates: plantillas de difusión SMS vigentes.
+// PUT /api/admin/templates: JSON [{"estado": "rojo", "texto": "...", "instrucciones": "..."}]
+// reemplaza las plantillas de esos estados; 400 si alguna no compila o no
+// cabe en notify.MaxSegments segmentos.
+func (s *Server) handleTemplates(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut:
+		var list []notify.Template
+		if err := json.NewDecoder(r.Body).Decode(&list); err != nil {
+			http.Error(w, "JSON inválido", http.StatusBadRequest)
+			return
+		}
+		if err := s.templates.SetTemplates(list); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(s.templates.Templates()); err != nil {
+		log.Println("error serializando plantillas:", err)
+	}
+}
+
+// POST /api/admin/templates/preview: muestra el SMS que se enviaría, con su
+// codificación (GSM-7 o UCS-2), segmentos y caracteres que fuerzan UCS-2.
+// JSON {"estado": "rojo", "zona": "...", "fenomeno": "...", "severidad": "...",
+// "instrucciones": "...", "texto": "...", "sin_tildes": true}; "texto" prueba
+// una plantilla sin guardarla y "sin_tildes" omitido usa la configuración vigente.
+func (s *Server) handleTemplatePreview(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	var req struct {
+		Estado        string `json:"estado"`
+		Zona          string `json:"zona"`
+		Fenomeno      string `json:"fenomeno"`
+		Severidad     string `json:"severidad"`
+		Instrucciones string `json:"instrucciones"`
+		Texto         string `json:"texto"`
+		SinTildes     *bool  `json:"sin_tildes"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "JSON inválido", http.StatusBadRequest)
+		return
+	}
+	data := notify.TemplateData{
+		Zona:          req.Zona,
+		Estado:        req.Estado,
+		Fenomeno:      req.Fenomeno,
+		Severidad:     req.Severidad,
+		Instrucciones: req.Instrucciones,
+		Hora:          time.Now(),
+	}
+	if data.Zona == "" {
+		data.Zona = "Zona Centro"
+	}
+	var tpl *notify.Template
+	if req.Texto != "" {
+		tpl = &notify.Template{Estado: req.Estado, Texto: req.Texto, Instrucciones: req.Instrucciones}
+	}
+	p, err := s.templates.Preview(data, tpl, req.SinTildes)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(p); err != nil {
+		log.Println("error serializando vista previa:", err)
+	}
+}
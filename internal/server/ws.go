@@ -0,0 +1,263 @@
+package server
+
+import (
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"alerta_climatica/internal/processing"
+)
+
+// Parámetros del canal WebSocket de paneles de operación.
+const (
+	wsSendBuffer = 64               // mensajes en cola por conexión antes de considerarla lenta
+	wsWriteWait  = 10 * time.Second // plazo para escribir un frame
+	wsPongWait   = 60 * time.Second // sin pong en este plazo se cierra la conexión
+	wsPingPeriod = wsPongWait * 9 / 10
+	wsMaxMessage = 4096
+)
+
+var wsUpgrader = websocket.Upgrader{ReadBufferSize: 1024, WriteBufferSize: 1024}
+
+// wsClientMsg es un mensaje del cliente: subscribe, unsubscribe o ack.
+type wsClientMsg struct {
+	Type       string   `json:"type"`
+	Zones      []string `json:"zonas,omitempty"`
+	Severities []string `json:"severidades,omitempty"`
+	ID         string   `json:"id,omitempty"`       // ack: id de la alerta
+	Operator   string   `json:"operador,omitempty"` // ack: quién confirma
+}
+
+// wsServerMsg es un mensaje del servidor. Type es "event", "subscribed", "ack" o "error".
+type wsServerMsg struct {
+	Type       string   `json:"type"`
+	Event      string   `json:"event,omitempty"`
+	ID         uint64   `json:"id,omitempty"`
+	Data       any      `json:"data,omitempty"`
+	Zones      []string `json:"zonas,omitempty"`
+	Severities []string `json:"severidades,omitempty"`
+	Error      string   `json:"error,omitempty"`
+}
+
+// wsFilter es la suscripción de una conexión. Sin suscribirse no se reciben
+// eventos; un subscribe sin zonas ni severidades equivale a "todo".
+type wsFilter struct {
+	mu         sync.Mutex
+	active     bool
+	zones      map[string]bool
+	severities map[string]bool
+}
+
+func (f *wsFilter) subscribe(zones, sevs []string) ([]string, []string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !f.active {
+		f.active = true
+		f.zones = make(map[string]bool)
+		f.severities = make(map[string]bool)
+	}
+	for _, z := range zones {
+		f.zones[z] = true
+	}
+	for _, sv := range sevs {
+		f.severities[sv] = true
+	}
+	return keys(f.zones), keys(f.severities)
+}
+
+// unsubscribe quita zonas/severidades; sin argumentos cancela la suscripción.
+func (f *wsFilter) unsubscribe(zones, sevs []string) ([]string, []string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(zones) == 0 && len(sevs) == 0 {
+		f.active = false
+		f.zones, f.severities = nil, nil
+		return nil, nil
+	}
+	for _, z := range zones {
+		delete(f.zones, z)
+	}
+	for _, sv := range sevs {
+		delete(f.severities, sv)
+	}
+	return keys(f.zones), keys(f.severities)
+}
+
+// match indica si un evento del hub corresponde a la suscripción.
+func (f *wsFilter) match(ev Event) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !f.active {
+		return false
+	}
+	var zone, sev string
+	switch d := ev.Data.(type) {
+	case processing.Alert:
+		zone, sev = d.Zone, d.Severity
+	case ZoneStatusChange:
+		zone = d.Zone
+	default:
+		// Acks y otros eventos sin zona llegan a todo panel suscrito.
+		return true
+	}
+	if len(f.zones) > 0 && !f.zones[zone] {
+		return false
+	}
+	if sev != "" && len(f.severities) > 0 && !f.severities[sev] {
+		return false
+	}
+	return true
+}
+
+func keys(m map[string]bool) []string {
+	out := make([]string, 0, len(m))
+	for k := range m {
+		out = append(out, k)
+	}
+	return out
+}
+
+// wsConn es una conexión de panel con su buffer de salida.
+type wsConn struct {
+	conn   *websocket.Conn
+	send   chan wsServerMsg
+	filter wsFilter
+	done   chan struct{}
+	once   sync.Once
+}
+
+// enqueue pone un mensaje en el buffer de salida. Si está lleno, el cliente
+// no da abasto y se desconecta en vez de frenar a los demás.
+func (c *wsConn) enqueue(m wsServerMsg) bool {
+	select {
+	case c.send <- m:
+		return true
+	case <-c.done:
+		return false
+	default:
+		log.Printf("ws: slow consumer %s, disconnecting", c.conn.RemoteAddr())
+		c.close(websocket.ClosePolicyViolation, "cliente lento")
+		return false
+	}
+}
+
+// close termina la conexión una sola vez, intentando enviar un frame de cierre.
+func (c *wsConn) close(code int, reason string) {
+	c.once.Do(func() {
+		close(c.done)
+		msg := websocket.FormatCloseMessage(code, reason)
+		_ = c.conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(wsWriteWait))
+		c.conn.Close()
+	})
+}
+
+// GET /api/ws: canal WebSocket para paneles de operación. Protocolo JSON:
+// el cliente envía {"type":"subscribe","zonas":[...],"severidades":[...]},
+// {"type":"unsubscribe",...} o {"type":"ack","id":"<alerta>","operador":"..."};
+// el servidor responde con {"type":"event","event":"alert",...}, "subscribed",
+// "ack" o "error".
+func (s *Server) handleWS(w http.ResponseWriter, r *http.Request) {
+	conn, err := wsUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Println("ws upgrade failed:", err)
+		return
+	}
+	c := &wsConn{conn: conn, send: make(chan wsServerMsg, wsSendBuffer), done: make(chan struct{})}
+
+	events, _, cancel := s.state.Events().Subscribe(^uint64(0), wsSendBuffer)
+	defer cancel()
+
+	go c.writeLoop()
+	go func() {
+		for {
+			select {
+			case <-c.done:
+				return
+			case <-s.done:
+				c.close(websocket.CloseGoingAway, "servidor cerrando")
+				return
+			case ev, ok := <-events:
+				if !ok {
+					c.close(websocket.ClosePolicyViolation, "cliente lento")
+					return
+				}
+				if c.filter.match(ev) {
+					c.enqueue(wsServerMsg{Type: "event", Event: ev.Type, ID: ev.ID, Data: ev.Data})
+				}
+			}
+		}
+	}()
+	s.wsReadLoop(c)
+	c.close(websocket.CloseNormalClosure, "")
+}
+
+// wsReadLoop atiende los mensajes del cliente hasta que la conexión se cierra.
+func (s *Server) wsReadLoop(c *wsConn) {
+	c.conn.SetReadLimit(wsMaxMessage)
+	_ = c.conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	c.conn.SetPongHandler(func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	})
+	for {
+		var m wsClientMsg
+		if err := c.conn.ReadJSON(&m); err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				log.Println("ws read error:", err)
+			}
+			return
+		}
+		var reply wsServerMsg
+		switch strings.ToLower(m.Type) {
+		case "subscribe":
+			z, sv := c.filter.subscribe(m.Zones, m.Severities)
+			reply = wsServerMsg{Type: "subscribed", Zones: z, Severities: sv}
+		case "unsubscribe":
+			z, sv := c.filter.unsubscribe(m.Zones, m.Severities)
+			reply = wsServerMsg{Type: "subscribed", Zones: z, Severities: sv}
+		case "ack":
+			if m.ID == "" {
+				reply = wsServerMsg{Type: "error", Error: "ack requiere id"}
+				break
+			}
+			ack, err := s.state.AckAlert(m.ID, m.Operator)
+			if err != nil {
+				log.Println("ws ack failed:", err)
+				reply = wsServerMsg{Type: "error", Error: "no se pudo registrar el ack"}
+				break
+			}
+			reply = wsServerMsg{Type: "ack", Data: ack}
+		default:
+			reply = wsServerMsg{Type: "error", Error: "tipo de mensaje desconocido: " + m.Type}
+		}
+		if !c.enqueue(reply) {
+			return
+		}
+	}
+}
+
+// writeLoop es el único escritor de la conexión: vacía el buffer y envía pings.
+func (c *wsConn) writeLoop() {
+	ping := time.NewTicker(wsPingPeriod)
+	defer ping.Stop()
+	for {
+		select {
+		case <-c.done:
+			return
+		case m := <-c.send:
+			_ = c.conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+			if err := c.conn.WriteJSON(m); err != nil {
+				c.close(websocket.CloseGoingAway, "")
+				return
+			}
+		case <-ping.C:
+			if err := c.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteWait)); err != nil {
+				c.close(websocket.CloseGoingAway, "")
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package server_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"alerta_climatica/internal/processing"
+	srvpkg "alerta_climatica/internal/server"
+	"alerta_climatica/internal/storage"
+)
+
+// TestWebSocketSubscriptionAndAck se suscribe solo a alertas críticas de
+// "Zona Sur", verifica que las demás no llegan y confirma una alerta con ack.
+func TestWebSocketSubscriptionAndAck(t *testing.T) {
+	store, err := storage.NewSQLite(t.TempDir() + "/ws.db")
+	if err != nil {
+		t.Fatalf("failed to open sqlite: %v", err)
+	}
+	defer store.Close()
+
+	st := srvpkg.NewState(store)
+	proc := processing.NewProcessor(nil, st.AddAlert)
+	proc.StartWorkers(1)
+	defer proc.Close()
+
+	ts := httptest.NewServer(srvpkg.NewServer(st, proc).Router())
+	defer ts.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(ts.URL, "http")+"/api/ws", nil)
+	if err != nil {
+		t.Fatalf("dial failed: %v", err)
+	}
+	defer conn.Close()
+	_ = conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+
+	read := func() map[string]any {
+		t.Helper()
+		var m map[string]any
+		if err := conn.ReadJSON(&m); err != nil {
+			t.Fatalf("read failed: %v", err)
+		}
+		return m
+	}
+
+	if err := conn.WriteJSON(map[string]any{"type": "subscribe", "zonas": []string{"Zona Sur"}, "severidades": []string{"crítica"}}); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+	if m := read(); m["type"] != "subscribed" {
+		t.Fatalf("expected subscribed, got %v", m)
+	}
+
+	send := func(zone, text string) {
+		b, _ := json.Marshal(map[string]string{"zona": zone, "texto": text})
+		resp, err := http.Post(ts.URL+"/api/sms", "application/json", bytes.NewReader(b))
+		if err != nil {
+			t.Fatalf("post failed: %v", err)
+		}
+		resp.Body.Close()
+	}
+	send("Zona Norte", "alerta roja en el cerro") // otra zona: filtrada
+	send("Zona Sur", "Lluvia intensa")            // severidad alta: filtrada
+	send("Zona Sur", "Se declara alerta roja")
+
+	// Los cambios de estado de Zona Sur también llegan (filtran solo por zona);
+	// de las alertas, solo debe llegar la crítica.
+	var data map[string]any
+	for data == nil {
+		m := read()
+		d, _ := m["data"].(map[string]any)
+		if d["zona"] != "Zona Sur" {
+			t.Fatalf("unexpected event for another zone: %v", m)
+		}
+		if m["event"] == "alert" {
+			if d["severidad"] != "crítica" {
+				t.Fatalf("expected only the critical alert, got %v", m)
+			}
+			data = d
+		}
+	}
+	alertID, _ := data["id"].(string)
+
+	if err := conn.WriteJSON(map[string]any{"type": "ack", "id": alertID, "operador": "central"}); err != nil {
+		t.Fatalf("write ack failed: %v", err)
+	}
+	// Pueden llegar antes el zone_status a rojo y el eco del ack por el hub.
+	gotAck := false
+	for i := 0; i < 4 && !gotAck; i++ {
+		m := read()
+		gotAck = m["type"] == "ack"
+	}
+	if !gotAck {
+		t.Fatal("ack reply not received")
+	}
+	acks, err := store.ListAcks(alertID)
+	if err != nil || len(acks) != 1 || acks[0].Operator != "central" {
+		t.Fatalf("ack not persisted: %+v (err %v)", acks, err)
+	}
+}
+
+// TestShutdownClosesStreams cierra el servidor con un cliente SSE y otro
+// WebSocket conectados: ninguno debe retener el cierre hasta el plazo.
+func TestShutdownClosesStreams(t *testing.T) {
+	store, err := storage.NewSQLite(t.TempDir() + "/shutdown.db")
+	if err != nil {
+		t.Fatalf("failed to open sqlite: %v", err)
+	}
+	defer store.Close()
+
+	st := srvpkg.NewState(store)
+	proc := processing.NewProcessor(nil, st.AddAlert)
+	proc.StartWorkers(1)
+	defer proc.Close()
+
+	srv := srvpkg.NewServer(st, proc)
+	ts := httptest.NewUnstartedServer(srv.Router())
+	ts.Config.RegisterOnShutdown(srv.CloseStreams)
+	ts.Start()
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/api/stream")
+	if err != nil {
+		t.Fatalf("stream request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(ts.URL, "http")+"/api/ws", nil)
+	if err != nil {
+		t.Fatalf("dial failed: %v", err)
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := ts.Config.Shutdown(ctx); err != nil {
+		t.Fatalf("shutdown waited for open streams: %v", err)
+	}
+	if _, err := io.ReadAll(resp.Body); err != nil {
+		t.Fatalf("stream did not end cleanly: %v", err)
+	}
+	_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if _, _, err := conn.ReadMessage(); !websocket.IsCloseError(err, websocket.CloseGoingAway) {
+		t.Fatalf("expected going away close, got %v", err)
+	}
+}
@@ -0,0 +1,207 @@
+// Package spam descarta mensajes entrantes repetidos, cadenas y remitentes
+// abusivos antes de que generen alertas.
+package spam
+
+import (
+	"log"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"alerta_climatica/internal/processing"
+	"alerta_climatica/internal/storage"
+)
+
+// Config ajusta los criterios del filtro.
+type Config struct {
+	DuplicateWindow time.Duration // mismo remitente y mismo texto normalizado
+	SimilarWindow   time.Duration // textos casi idénticos de remitentes distintos
+	Similarity      float64       // umbral de similitud de Jaccard entre palabras
+	MinSimilarWords int           // solo textos largos se comparan entre remitentes
+	RateLimit       int           // mensajes por remitente dentro de RateWindow
+	RateWindow      time.Duration
+}
+
+// DefaultConfig: los reportes cortos de vecinos distintos ("se desborda el
+// río") son corroboración, no spam; solo se comparan entre remitentes los
+// textos largos típicos de mensajes en cadena.
+func DefaultConfig() Config {
+	return Config{
+		DuplicateWindow: 10 * time.Minute,
+		SimilarWindow:   30 * time.Minute,
+		Similarity:      0.85,
+		MinSimilarWords: 8,
+		RateLimit:       6,
+		RateWindow:      10 * time.Minute,
+	}
+}
+
+type seen struct {
+	at     time.Time
+	sender string
+	text   string          // texto normalizado
+	words  map[string]bool // solo para textos largos
+}
+
+// Filter es un interceptor del Processor: devuelve true (y registra el motivo)
+// para los mensajes que no deben generar alertas. Es seguro para uso
+// concurrente desde varios shards.
+type Filter struct {
+	store storage.SpamStore
+	cfg   Config
+
+	mu        sync.Mutex
+	bySender  map[string][]seen // mensajes recientes por remitente
+	long      []seen            // textos largos recientes de cualquier remitente
+	lastSweep time.Time
+}
+
+// NewFilter crea el filtro. store puede ser nil (sin lista de bloqueo ni registro).
+func NewFilter(store storage.SpamStore, cfg Config) *Filter {
+	return &Filter{store: store, cfg: cfg, bySender: make(map[string][]seen)}
+}
+
+// Handle descarta msg si corresponde y lo guarda con el motivo.
+func (f *Filter) Handle(msg processing.IncomingMessage) bool {
+	reason := f.Check(msg)
+	if reason == "" {
+		return false
+	}
+	log.Printf("spam: suppressed message from %q in %s: %s", msg.From, msg.Zone, reason)
+	if f.store != nil {
+		m := storage.SuppressedMessage{Phone: msg.From, Zone: msg.Zone, Text: msg.Text, Channel: msg.Channel, Reason: reason, ReceivedAt: msg.ReceivedAt}
+		if m.ReceivedAt.IsZero() {
+			m.ReceivedAt = time.Now()
+		}
+		if _, err := f.store.SaveSuppressed(m); err != nil {
+			log.Println("warning: cannot save suppressed message:", err)
+		}
+	}
+	return true
+}
+
+// Check devuelve el motivo por el que msg debe descartarse, o "" si pasa.
+// Todo mensaje evaluado cuenta para el límite y las comparaciones siguientes.
+func (f *Filter) Check(msg processing.IncomingMessage) string {
+	if msg.From != "" && f.store != nil {
+		blocked, err := f.store.IsBlocked(msg.From)
+		if err != nil {
+			log.Println("warning: cannot check blocklist:", err)
+		} else if blocked {
+			return storage.SuppressBlocked
+		}
+	}
+
+	now := msg.ReceivedAt
+	if now.IsZero() {
+		now = time.Now()
+	}
+	cur := seen{at: now, sender: msg.From, text: Normalize(msg.Text)}
+	words := strings.Fields(cur.text)
+	if len(words) >= f.cfg.MinSimilarWords {
+		cur.words = wordSet(words)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.sweep(now)
+
+	reason := ""
+	if msg.From != "" {
+		recent := f.bySender[msg.From]
+		inRate := 0
+		for _, s := range recent {
+			if s.text == cur.text && now.Sub(s.at) <= f.cfg.DuplicateWindow {
+				reason = storage.SuppressDuplicate
+			}
+			if now.Sub(s.at) <= f.cfg.RateWindow {
+				inRate++
+			}
+		}
+		if reason == "" && f.cfg.RateLimit > 0 && inRate >= f.cfg.RateLimit {
+			reason = storage.SuppressRateLimit
+		}
+		f.bySender[msg.From] = append(recent, cur)
+	}
+	if cur.words != nil {
+		if reason == "" {
+			for _, s := range f.long {
+				// Los anónimos ("") cuentan como remitentes distintos entre sí.
+				if (s.sender != msg.From || msg.From == "") && now.Sub(s.at) <= f.cfg.SimilarWindow && jaccard(s.words, cur.words) >= f.cfg.Similarity {
+					reason = storage.SuppressSimilar
+					break
+				}
+			}
+		}
+		f.long = append(f.long, cur)
+	}
+	return reason
+}
+
+// sweep descarta lo que ya salió de todas las ventanas, como mucho una vez por
+// minuto. Requiere f.mu tomado.
+func (f *Filter) sweep(now time.Time) {
+	if now.Sub(f.lastSweep) < time.Minute {
+		return
+	}
+	f.lastSweep = now
+	keep := max(f.cfg.DuplicateWindow, f.cfg.RateWindow)
+	for k, list := range f.bySender {
+		list = prune(list, now, keep)
+		if len(list) == 0 {
+			delete(f.bySender, k)
+		} else {
+			f.bySender[k] = list
+		}
+	}
+	f.long = prune(f.long, now, f.cfg.SimilarWindow)
+}
+
+func prune(list []seen, now time.Time, keep time.Duration) []seen {
+	out := list[:0]
+	for _, s := range list {
+		if now.Sub(s.at) <= keep {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+var accentFolder = strings.NewReplacer("á", "a", "é", "e", "í", "i", "ó", "o", "ú", "u", "ü", "u", "ñ", "n")
+
+// Normalize reduce un texto a minúsculas sin tildes, signos ni espacios extra,
+// para que "¡Se desborda el RÍO!!" y "se desborda el rio" se consideren iguales.
+func Normalize(text string) string {
+	t := accentFolder.Replace(strings.ToLower(text))
+	t = strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return r
+		}
+		return ' '
+	}, t)
+	return strings.Join(strings.Fields(t), " ")
+}
+
+func wordSet(words []string) map[string]bool {
+	set := make(map[string]bool, len(words))
+	for _, w := range words {
+		set[w] = true
+	}
+	return set
+}
+
+// jaccard es |a∩b| / |a∪b| sobre conjuntos de palabras.
+func jaccard(a, b map[string]bool) float64 {
+	inter := 0
+	for w := range a {
+		if b[w] {
+			inter++
+		}
+	}
+	union := len(a) + len(b) - inter
+	if union == 0 {
+		return 0
+	}
+	return float64(inter) / float64(union)
+}
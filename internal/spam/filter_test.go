@@ -0,0 +1,59 @@
+package spam
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"alerta_climatica/internal/processing"
+	"alerta_climatica/internal/storage"
+)
+
+func TestFilterReasons(t *testing.T) {
+	store, err := storage.NewSQLite(filepath.Join(t.TempDir(), "spam.db"))
+	if err != nil {
+		t.Fatalf("NewSQLite: %v", err)
+	}
+	defer store.Close()
+	cfg := DefaultConfig()
+	cfg.RateLimit = 3
+	f := NewFilter(store, cfg)
+
+	t0 := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	msg := func(from, text string, at time.Duration) processing.IncomingMessage {
+		return processing.IncomingMessage{Zone: "Zona Sur", From: from, Text: text, ReceivedAt: t0.Add(at)}
+	}
+	chain := "Urgente reenvia a todos tus contactos mañana habrá un huaico enorme en toda la ciudad"
+
+	cases := []struct {
+		name string
+		msg  processing.IncomingMessage
+		want string
+	}{
+		{"first report", msg("+51911111111", "Se desborda el río", 0), ""},
+		{"same text normalized", msg("+51911111111", "¡Se desborda el RIO!!", time.Minute), storage.SuppressDuplicate},
+		{"other sender short text is corroboration", msg("+51922222222", "Se desborda el río", time.Minute), ""},
+		{"duplicate window elapsed", msg("+51911111111", "se desborda el rio", 15*time.Minute), ""},
+		{"chain first copy", msg("+51933333333", chain, 0), ""},
+		{"chain from another sender", msg("+51944444444", "URGENTE: reenvía a todos tus contactos, mañana habrá un huaico enorme en toda la ciudad", time.Minute), storage.SuppressSimilar},
+		{"under rate limit", msg("+51933333333", "lluvia intensa", 2*time.Minute), ""},
+		{"at rate limit", msg("+51933333333", "viento fuerte", 3*time.Minute), ""},
+		{"over rate limit", msg("+51933333333", "crecida del río", 4*time.Minute), storage.SuppressRateLimit},
+	}
+	for _, c := range cases {
+		if got := f.Check(c.msg); got != c.want {
+			t.Errorf("%s: got %q, want %q", c.name, got, c.want)
+		}
+	}
+
+	if err := store.BlockSender(storage.BlockedSender{Phone: "+51955555555", Reason: "cadenas", CreatedAt: t0}); err != nil {
+		t.Fatalf("BlockSender: %v", err)
+	}
+	if !f.Handle(msg("+51955555555", "alerta roja", 0)) {
+		t.Fatal("blocked sender was not suppressed")
+	}
+	list, err := store.ListSuppressed(storage.SuppressBlocked)
+	if err != nil || len(list) != 1 || list[0].Phone != "+51955555555" {
+		t.Fatalf("suppressed message not stored with reason: %+v, %v", list, err)
+	}
+}
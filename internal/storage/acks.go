@@ -0,0 +1,37 @@
+package storage
+
+import "time"
+
+// AlertAck registra que un operador tomó conocimiento de una alerta.
+type AlertAck struct {
+	AlertID  string    `json:"alerta_id"`
+	Operator string    `json:"operador"`
+	AckedAt  time.Time `json:"confirmado_en"`
+}
+
+// SaveAck guarda (o actualiza) el acuse de un operador sobre una alerta.
+func (s *SQLiteStore) SaveAck(ack AlertAck) error {
+	_, err := s.db.Exec(`INSERT OR REPLACE INTO alert_acks(alert_id, operator, acked_at) VALUES(?,?,?)`,
+		ack.AlertID, ack.Operator, formatTime(ack.AckedAt))
+	return err
+}
+
+// ListAcks devuelve los acuses de una alerta en orden cronológico.
+func (s *SQLiteStore) ListAcks(alertID string) ([]AlertAck, error) {
+	rows, err := s.db.Query(`SELECT alert_id, operator, acked_at FROM alert_acks WHERE alert_id = ? ORDER BY acked_at`, alertID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := make([]AlertAck, 0)
+	for rows.Next() {
+		var a AlertAck
+		var ts string
+		if err := rows.Scan(&a.AlertID, &a.Operator, &ts); err != nil {
+			return nil, err
+		}
+		a.AckedAt = parseTime(ts)
+		out = append(out, a)
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,103 @@
+package storage
+
+import (
+	"time"
+)
+
+// Tipos de entrada dead-letter.
+const (
+	DeadLetterPersist = "persist" // la alerta no pudo guardarse en la DB
+	DeadLetterPanic   = "panic"   // el worker entró en pánico procesando el mensaje
+)
+
+// DeadLetter registra una alerta o mensaje que falló al persistirse o procesarse.
+// Payload guarda el JSON original (processing.Alert o processing.IncomingMessage
+// según Kind) para poder reintentarlo o reinyectarlo.
+type DeadLetter struct {
+	ID          int64     `json:"id"`
+	Kind        string    `json:"tipo"`
+	Payload     string    `json:"payload"`
+	Error       string    `json:"error"`
+	Attempts    int       `json:"intentos"`
+	CreatedAt   time.Time `json:"creado_en"`
+	NextAttempt time.Time `json:"proximo_intento"`
+	Resolved    bool      `json:"resuelto"`
+}
+
+// AddDeadLetter inserta una entrada y devuelve su id.
+func (s *SQLiteStore) AddDeadLetter(d DeadLetter) (int64, error) {
+	res, err := s.db.Exec(`INSERT INTO dead_letters(kind, payload, error, attempts, created_at, next_attempt, resolved) VALUES(?,?,?,?,?,?,?)`,
+		d.Kind, d.Payload, d.Error, d.Attempts, formatTime(d.CreatedAt), formatTime(d.NextAttempt), d.Resolved)
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+// GetDeadLetter devuelve una entrada por id (sql.ErrNoRows si no existe).
+func (s *SQLiteStore) GetDeadLetter(id int64) (DeadLetter, error) {
+	row := s.db.QueryRow(`SELECT id, kind, payload, error, attempts, created_at, next_attempt, resolved FROM dead_letters WHERE id = ?`, id)
+	return scanDeadLetter(row)
+}
+
+// ListDeadLetters lista entradas, más recientes primero. Por defecto omite las resueltas.
+func (s *SQLiteStore) ListDeadLetters(includeResolved bool) ([]DeadLetter, error) {
+	q := `SELECT id, kind, payload, error, attempts, created_at, next_attempt, resolved FROM dead_letters`
+	if !includeResolved {
+		q += ` WHERE resolved = 0`
+	}
+	q += ` ORDER BY id DESC LIMIT 500`
+	rows, err := s.db.Query(q)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := make([]DeadLetter, 0)
+	for rows.Next() {
+		d, err := scanDeadLetter(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, d)
+	}
+	return out, rows.Err()
+}
+
+// UpdateDeadLetter actualiza error, intentos, próximo intento y estado de resolución.
+func (s *SQLiteStore) UpdateDeadLetter(d DeadLetter) error {
+	_, err := s.db.Exec(`UPDATE dead_letters SET error = ?, attempts = ?, next_attempt = ?, resolved = ? WHERE id = ?`,
+		d.Error, d.Attempts, formatTime(d.NextAttempt), d.Resolved, d.ID)
+	return err
+}
+
+// rowScanner abstrae *sql.Row y *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanDeadLetter(r rowScanner) (DeadLetter, error) {
+	var d DeadLetter
+	var created, next string
+	if err := r.Scan(&d.ID, &d.Kind, &d.Payload, &d.Error, &d.Attempts, &created, &next, &d.Resolved); err != nil {
+		return DeadLetter{}, err
+	}
+	d.CreatedAt = parseTime(created)
+	d.NextAttempt = parseTime(next)
+	return d, nil
+}
+
+// formatTime serializa tiempos en UTC/RFC3339 (mismo formato que alerts.timestamp).
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+func parseTime(s string) time.Time {
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
@@ -0,0 +1,297 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"alerta_climatica/internal/processing"
+)
+
+// Incident agrupa alertas relacionadas (misma zona y fenómeno, cercanas en el
+// tiempo) en un solo evento.
+type Incident struct {
+	ID           int64     `json:"id"`
+	Zone         string    `json:"zona"`
+	Type         string    `json:"tipo"`
+	Start        time.Time `json:"inicio"`
+	End          time.Time `json:"fin"`
+	PeakSeverity string    `json:"severidad_maxima"` // sin contar reportes descartados
+	ReportCount  int       `json:"reportes"`         // reportes no descartados
+	AlertIDs     []string  `json:"alertas,omitempty"`
+}
+
+// ErrInvalidIncidentOp indica una fusión o división de incidentes imposible
+// (p. ej. dividir todas las alertas o alertas que no pertenecen al incidente).
+var ErrInvalidIncidentOp = errors.New("storage: operación de incidente inválida")
+
+// severityRank ordena las severidades de processing para calcular el pico.
+var severityRank = map[string]int{"baja": 0, "media": 1, "alta": 2, "crítica": 3}
+
+// CreateIncident abre un incidente nuevo con la alerta a como primer reporte.
+func (s *SQLiteStore) CreateIncident(a processing.Alert) (int64, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	ts := formatTime(a.Timestamp)
+	res, err := tx.Exec(`INSERT INTO incidents(zone, type, started_at, ended_at, peak_severity, report_count) VALUES(?,?,?,?,?,1)`,
+		a.Zone, a.Type, ts, ts, a.Severity)
+	if err != nil {
+		return 0, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	if _, err := tx.Exec(`INSERT OR REPLACE INTO incident_alerts(incident_id, alert_id, severity, timestamp) VALUES(?,?,?,?)`,
+		id, a.ID, a.Severity, ts); err != nil {
+		return 0, err
+	}
+	return id, tx.Commit()
+}
+
+// AttachAlert vincula la alerta a al incidente id y actualiza fin, pico y conteo.
+func (s *SQLiteStore) AttachAlert(id int64, a processing.Alert) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(`INSERT OR REPLACE INTO incident_alerts(incident_id, alert_id, severity, timestamp) VALUES(?,?,?,?)`,
+		id, a.ID, a.Severity, formatTime(a.Timestamp)); err != nil {
+		return err
+	}
+	if err := recomputeIncident(tx, id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// FindIncident devuelve el incidente de zona y fenómeno cuyo intervalo,
+// ampliado en gap por ambos lados, contiene at. Si hay varios, el más cercano
+// a at (sql.ErrNoRows si no hay ninguno). Un reporte atrasado encuentra así
+// el incidente al que pertenece aunque ya haya otro más reciente.
+func (s *SQLiteStore) FindIncident(zone, typ string, at time.Time, gap time.Duration) (Incident, error) {
+	rows, err := s.db.Query(`SELECT `+incidentColumns+` FROM incidents
+        WHERE zone = ? AND type = ? AND started_at <= ? AND ended_at >= ? ORDER BY ended_at DESC, id DESC`,
+		zone, typ, formatTime(at.Add(gap)), formatTime(at.Add(-gap)))
+	if err != nil {
+		return Incident{}, err
+	}
+	defer rows.Close()
+	var best Incident
+	bestDist := time.Duration(-1)
+	for rows.Next() {
+		inc, err := scanIncident(rows)
+		if err != nil {
+			return Incident{}, err
+		}
+		var dist time.Duration
+		switch {
+		case at.Before(inc.Start):
+			dist = inc.Start.Sub(at)
+		case at.After(inc.End):
+			dist = at.Sub(inc.End)
+		}
+		if bestDist < 0 || dist < bestDist {
+			best, bestDist = inc, dist
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return Incident{}, err
+	}
+	if bestDist < 0 {
+		return Incident{}, sql.ErrNoRows
+	}
+	return best, nil
+}
+
+// GetIncident devuelve un incidente con sus alertas vinculadas.
+func (s *SQLiteStore) GetIncident(id int64) (Incident, error) {
+	inc, err := scanIncident(s.db.QueryRow(`SELECT `+incidentColumns+` FROM incidents WHERE id = ?`, id))
+	if err != nil {
+		return Incident{}, err
+	}
+	rows, err := s.db.Query(`SELECT alert_id FROM incident_alerts WHERE incident_id = ? ORDER BY timestamp, alert_id`, id)
+	if err != nil {
+		return Incident{}, err
+	}
+	defer rows.Close()
+	inc.AlertIDs = make([]string, 0, inc.ReportCount)
+	for rows.Next() {
+		var aid string
+		if err := rows.Scan(&aid); err != nil {
+			return Incident{}, err
+		}
+		inc.AlertIDs = append(inc.AlertIDs, aid)
+	}
+	return inc, rows.Err()
+}
+
+// ListIncidents lista incidentes, más recientes primero; zone vacío lista todas.
+func (s *SQLiteStore) ListIncidents(zone string) ([]Incident, error) {
+	q := `SELECT ` + incidentColumns + ` FROM incidents`
+	var args []any
+	if zone != "" {
+		q += ` WHERE zone = ?`
+		args = append(args, zone)
+	}
+	rows, err := s.db.Query(q+` ORDER BY ended_at DESC, id DESC LIMIT 200`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := make([]Incident, 0)
+	for rows.Next() {
+		inc, err := scanIncident(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, inc)
+	}
+	return out, rows.Err()
+}
+
+// MergeIncidents mueve las alertas de sources al incidente target y elimina
+// los incidentes origen. El incidente resultante conserva zona y fenómeno de target.
+func (s *SQLiteStore) MergeIncidents(target int64, sources []int64) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := incidentExists(tx, target); err != nil {
+		return err
+	}
+	for _, src := range sources {
+		if src == target {
+			return ErrInvalidIncidentOp
+		}
+		if err := incidentExists(tx, src); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`UPDATE incident_alerts SET incident_id = ? WHERE incident_id = ?`, target, src); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`DELETE FROM incidents WHERE id = ?`, src); err != nil {
+			return err
+		}
+	}
+	if err := recomputeIncident(tx, target); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// SplitIncident separa alertIDs del incidente id en un incidente nuevo de la
+// misma zona y fenómeno, y devuelve su id. Las alertas deben pertenecer al
+// incidente y debe quedar al menos una en el original.
+func (s *SQLiteStore) SplitIncident(id int64, alertIDs []string) (int64, error) {
+	if len(alertIDs) == 0 {
+		return 0, ErrInvalidIncidentOp
+	}
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	var zone, typ string
+	if err := tx.QueryRow(`SELECT zone, type FROM incidents WHERE id = ?`, id).Scan(&zone, &typ); err != nil {
+		return 0, err
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(alertIDs)), ",")
+	args := []any{id}
+	for _, aid := range alertIDs {
+		args = append(args, aid)
+	}
+	var moving, total int
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM incident_alerts WHERE incident_id = ? AND alert_id IN (`+placeholders+`)`, args...).Scan(&moving); err != nil {
+		return 0, err
+	}
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM incident_alerts WHERE incident_id = ?`, id).Scan(&total); err != nil {
+		return 0, err
+	}
+	if moving != len(alertIDs) || moving == total {
+		return 0, ErrInvalidIncidentOp
+	}
+
+	res, err := tx.Exec(`INSERT INTO incidents(zone, type, started_at, ended_at, peak_severity, report_count) VALUES(?,?,'','','',0)`, zone, typ)
+	if err != nil {
+		return 0, err
+	}
+	newID, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	if _, err := tx.Exec(`UPDATE incident_alerts SET incident_id = ? WHERE incident_id = ? AND alert_id IN (`+placeholders+`)`,
+		append([]any{newID}, args...)...); err != nil {
+		return 0, err
+	}
+	if err := recomputeIncident(tx, id); err != nil {
+		return 0, err
+	}
+	if err := recomputeIncident(tx, newID); err != nil {
+		return 0, err
+	}
+	return newID, tx.Commit()
+}
+
+const incidentColumns = `id, zone, type, started_at, ended_at, peak_severity, report_count`
+
+func scanIncident(r rowScanner) (Incident, error) {
+	var inc Incident
+	var start, end string
+	if err := r.Scan(&inc.ID, &inc.Zone, &inc.Type, &start, &end, &inc.PeakSeverity, &inc.ReportCount); err != nil {
+		return Incident{}, err
+	}
+	inc.Start = parseTime(start)
+	inc.End = parseTime(end)
+	return inc, nil
+}
+
+func incidentExists(tx *sql.Tx, id int64) error {
+	var one int
+	return tx.QueryRow(`SELECT 1 FROM incidents WHERE id = ?`, id).Scan(&one)
+}
+
+// recomputeIncident recalcula inicio, fin, pico y conteo a partir de las
+// alertas vinculadas. Las descartadas en revisión siguen marcando el intervalo
+// pero no cuentan para el pico ni el conteo. Las marcas de tiempo en RFC3339
+// UTC ordenan como texto.
+func recomputeIncident(tx *sql.Tx, id int64) error {
+	rows, err := tx.Query(`SELECT ia.severity, ia.timestamp, COALESCE(a.review, '') FROM incident_alerts ia
+        LEFT JOIN alerts a ON a.id = ia.alert_id WHERE ia.incident_id = ? ORDER BY ia.timestamp`, id)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	var start, end, peak string
+	count := 0
+	for rows.Next() {
+		var sev, ts, review string
+		if err := rows.Scan(&sev, &ts, &review); err != nil {
+			return err
+		}
+		if start == "" {
+			start = ts
+		}
+		end = ts
+		if review == ReviewDismissed {
+			continue
+		}
+		if count == 0 || severityRank[sev] > severityRank[peak] {
+			peak = sev
+		}
+		count++
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	_, err = tx.Exec(`UPDATE incidents SET started_at = ?, ended_at = ?, peak_severity = ?, report_count = ? WHERE id = ?`,
+		start, end, peak, count, id)
+	return err
+}
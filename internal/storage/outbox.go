@@ -0,0 +1,234 @@
+package storage
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+)
+
+// Estados de un envío SMS en la bandeja de salida.
+const (
+	DeliveryQueued    = "en_cola"
+	DeliverySent      = "enviado"      // aceptado por el proveedor
+	DeliveryDelivered = "entregado"    // confirmado por reporte de entrega
+	DeliveryFailed    = "fallido"      // sin más reintentos o rechazado por el operador
+	DeliveryRetrying  = "reintentando" // falló; se reintenta en NextAttempt
+)
+
+// deliveryRanks ordena los estados de un envío. Los reportes de entrega solo
+// lo hacen avanzar: un "enviado" tardío no pisa un fallo ni un reintento ya
+// programado, y un fallo repetido no vuelve a programarlo.
+var deliveryRanks = map[string]int{
+	DeliveryQueued:    0,
+	DeliverySent:      1,
+	DeliveryRetrying:  2,
+	DeliveryFailed:    2,
+	DeliveryDelivered: 3,
+}
+
+// DeliveryAdvances dice si un reporte con estado next hace avanzar un envío
+// que está en cur.
+func DeliveryAdvances(cur, next string) bool {
+	r, ok := deliveryRanks[next]
+	return ok && r > deliveryRanks[cur]
+}
+
+// Delivery registra el envío de un SMS a un destinatario dentro de una
+// campaña (una difusión por escalamiento de zona).
+type Delivery struct {
+	ID          int64     `json:"id"`
+	Campaign    string    `json:"campania"`
+	Phone       string    `json:"telefono"`
+	Zone        string    `json:"zona"`
+	Message     string    `json:"mensaje"`
+	Status      string    `json:"estado"`
+	Error       string    `json:"error,omitempty"`
+	Attempts    int       `json:"intentos"`
+	NextAttempt time.Time `json:"proximo_intento"`
+	ProviderID  string    `json:"id_proveedor,omitempty"` // id del proveedor para reportes de entrega
+	CreatedAt   time.Time `json:"creado_en"`
+	UpdatedAt   time.Time `json:"actualizado_en"`
+}
+
+// CampaignStats resume los envíos de una campaña por estado.
+type CampaignStats struct {
+	Campaign  string    `json:"campania"`
+	Zone      string    `json:"zona"`
+	Total     int       `json:"total"`
+	Queued    int       `json:"en_cola"`
+	Sent      int       `json:"enviados"`
+	Delivered int       `json:"entregados"`
+	Retrying  int       `json:"reintentando"`
+	Failed    int       `json:"fallidos"`
+	Start     time.Time `json:"inicio"`
+}
+
+const deliveryColumns = `id, campaign, phone, zone, message, status, error, attempts, next_attempt_at, provider_id, created_at, updated_at`
+
+// SaveDelivery agrega un envío a la bandeja de salida y devuelve su id.
+func (s *SQLiteStore) SaveDelivery(d Delivery) (int64, error) {
+	if d.CreatedAt.IsZero() {
+		d.CreatedAt = time.Now()
+	}
+	res, err := s.db.Exec(`INSERT INTO deliveries(campaign, phone, zone, message, status, error, attempts, next_attempt_at, provider_id, created_at, updated_at) VALUES(?,?,?,?,?,?,?,?,?,?,?)`,
+		d.Campaign, d.Phone, d.Zone, d.Message, d.Status, d.Error, d.Attempts, formatTime(d.NextAttempt), d.ProviderID, formatTime(d.CreatedAt), formatTime(d.UpdatedAt))
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+// UpdateDelivery guarda estado, error, intentos, próximo intento e id del
+// proveedor de un envío (sql.ErrNoRows si el id no existe).
+func (s *SQLiteStore) UpdateDelivery(d Delivery) error {
+	if d.UpdatedAt.IsZero() {
+		d.UpdatedAt = time.Now()
+	}
+	res, err := s.db.Exec(`UPDATE deliveries SET status = ?, error = ?, attempts = ?, next_attempt_at = ?, provider_id = ?, updated_at = ? WHERE id = ?`,
+		d.Status, d.Error, d.Attempts, formatTime(d.NextAttempt), d.ProviderID, formatTime(d.UpdatedAt), d.ID)
+	if err != nil {
+		return err
+	}
+	return expectAffected(res)
+}
+
+// ListDeliveries lista los envíos de una campaña y/o estado, más recientes
+// primero (los últimos 500; "" no filtra).
+func (s *SQLiteStore) ListDeliveries(campaign, status string) ([]Delivery, error) {
+	q := `SELECT ` + deliveryColumns + ` FROM deliveries WHERE 1 = 1`
+	args := []any{}
+	if campaign != "" {
+		q += ` AND campaign = ?`
+		args = append(args, campaign)
+	}
+	if status != "" {
+		q += ` AND status = ?`
+		args = append(args, status)
+	}
+	q += ` ORDER BY id DESC LIMIT 500`
+	return s.queryDeliveries(q, args...)
+}
+
+// DueDeliveries devuelve hasta limit envíos en cola o por reintentar cuyo
+// próximo intento ya venció, los más antiguos primero.
+func (s *SQLiteStore) DueDeliveries(now time.Time, limit int) ([]Delivery, error) {
+	return s.queryDeliveries(`SELECT `+deliveryColumns+` FROM deliveries
+        WHERE status IN (?, ?) AND next_attempt_at <= ? ORDER BY next_attempt_at, id LIMIT ?`,
+		DeliveryQueued, DeliveryRetrying, formatTime(now), limit)
+}
+
+// GetDelivery devuelve el envío con ese id o, si id es 0, el que tenga ese id
+// de proveedor (sql.ErrNoRows si no existe).
+func (s *SQLiteStore) GetDelivery(id int64, providerID string) (Delivery, error) {
+	q := `SELECT ` + deliveryColumns + ` FROM deliveries WHERE `
+	var arg any
+	switch {
+	case id != 0:
+		q, arg = q+`id = ?`, id
+	case providerID != "":
+		q, arg = q+`provider_id = ? ORDER BY id DESC`, providerID
+	default:
+		return Delivery{}, sql.ErrNoRows
+	}
+	list, err := s.queryDeliveries(q+` LIMIT 1`, arg)
+	if err != nil {
+		return Delivery{}, err
+	}
+	if len(list) == 0 {
+		return Delivery{}, sql.ErrNoRows
+	}
+	return list[0], nil
+}
+
+// MarkDelivery aplica un reporte de entrega al envío con ese id o, si id es
+// 0, al que tenga ese id de proveedor, solo si lo hace avanzar (ver
+// DeliveryAdvances). Los estados finales anulan el próximo intento.
+// Devuelve sql.ErrNoRows si no hay envío que actualizar.
+func (s *SQLiteStore) MarkDelivery(id int64, providerID, status, errText string) error {
+	var before []string
+	for st := range deliveryRanks {
+		if DeliveryAdvances(st, status) {
+			before = append(before, st)
+		}
+	}
+	if len(before) == 0 {
+		return sql.ErrNoRows
+	}
+	q := `UPDATE deliveries SET status = ?, error = ?, updated_at = ?`
+	args := []any{status, errText, formatTime(time.Now())}
+	if status == DeliveryDelivered || status == DeliveryFailed {
+		q += `, next_attempt_at = ''`
+	}
+	q += ` WHERE status IN (?` + strings.Repeat(`, ?`, len(before)-1) + `) AND `
+	for _, st := range before {
+		args = append(args, st)
+	}
+	switch {
+	case id != 0:
+		q += `id = ?`
+		args = append(args, id)
+	case providerID != "":
+		q += `provider_id = ?`
+		args = append(args, providerID)
+	default:
+		return sql.ErrNoRows
+	}
+	res, err := s.db.Exec(q, args...)
+	if err != nil {
+		return err
+	}
+	return expectAffected(res)
+}
+
+// CampaignStats cuenta los envíos por estado de una campaña, o de las 100
+// campañas más recientes si campaign es "".
+func (s *SQLiteStore) CampaignStats(campaign string) ([]CampaignStats, error) {
+	q := `SELECT campaign, MIN(zone), COUNT(*),
+        SUM(status = ?), SUM(status = ?), SUM(status = ?), SUM(status = ?), SUM(status = ?),
+        MIN(created_at)
+        FROM deliveries`
+	args := []any{DeliveryQueued, DeliverySent, DeliveryDelivered, DeliveryRetrying, DeliveryFailed}
+	if campaign != "" {
+		q += ` WHERE campaign = ?`
+		args = append(args, campaign)
+	}
+	q += ` GROUP BY campaign ORDER BY MIN(created_at) DESC LIMIT 100`
+	rows, err := s.db.Query(q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := make([]CampaignStats, 0)
+	for rows.Next() {
+		var c CampaignStats
+		var start string
+		if err := rows.Scan(&c.Campaign, &c.Zone, &c.Total, &c.Queued, &c.Sent, &c.Delivered, &c.Retrying, &c.Failed, &start); err != nil {
+			return nil, err
+		}
+		c.Start = parseTime(start)
+		out = append(out, c)
+	}
+	return out, rows.Err()
+}
+
+func (s *SQLiteStore) queryDeliveries(q string, args ...any) ([]Delivery, error) {
+	rows, err := s.db.Query(q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := make([]Delivery, 0)
+	for rows.Next() {
+		var d Delivery
+		var next, created, updated string
+		if err := rows.Scan(&d.ID, &d.Campaign, &d.Phone, &d.Zone, &d.Message, &d.Status, &d.Error,
+			&d.Attempts, &next, &d.ProviderID, &created, &updated); err != nil {
+			return nil, err
+		}
+		d.NextAttempt = parseTime(next)
+		d.CreatedAt = parseTime(created)
+		d.UpdatedAt = parseTime(updated)
+		out = append(out, d)
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,71 @@
+package storage
+
+import (
+	"encoding/json"
+	"time"
+
+	"alerta_climatica/internal/processing"
+)
+
+// SaveQueued guarda mensajes pendientes de procesar (en orden) en una sola transacción.
+func (s *SQLiteStore) SaveQueued(msgs []processing.IncomingMessage) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	stmt, err := tx.Prepare(`INSERT INTO queued_messages(payload, queued_at) VALUES(?,?)`)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	defer stmt.Close()
+
+	now := formatTime(time.Now())
+	for _, m := range msgs {
+		b, err := json.Marshal(m)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+		if _, err := stmt.Exec(string(b), now); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// TakeQueued devuelve los mensajes guardados en orden de llegada y vacía la cola.
+func (s *SQLiteStore) TakeQueued() ([]processing.IncomingMessage, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	rows, err := tx.Query(`SELECT payload FROM queued_messages ORDER BY id`)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	var out []processing.IncomingMessage
+	for rows.Next() {
+		var payload string
+		if err := rows.Scan(&payload); err != nil {
+			rows.Close()
+			tx.Rollback()
+			return nil, err
+		}
+		var m processing.IncomingMessage
+		if err := json.Unmarshal([]byte(payload), &m); err != nil {
+			rows.Close()
+			tx.Rollback()
+			return nil, err
+		}
+		out = append(out, m)
+	}
+	rows.Close()
+	if _, err := tx.Exec(`DELETE FROM queued_messages`); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	return out, tx.Commit()
+}
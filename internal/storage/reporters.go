@@ -0,0 +1,206 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Niveles de confianza de un remitente.
+const (
+	TrustAnonymous = "anonimo"     // mensaje sin número de remitente
+	TrustCommunity = "comunitario" // número conocido (por defecto)
+	TrustMonitor   = "vigia"       // monitor comunitario capacitado y verificado
+)
+
+// Resultados de la revisión de una alerta por un operador.
+const (
+	ReviewConfirmed = "confirmada"
+	ReviewDismissed = "descartada"
+)
+
+// Reporter es un número que ha enviado reportes al sistema.
+type Reporter struct {
+	Phone       string    `json:"telefono"`
+	Name        string    `json:"nombre,omitempty"`
+	Level       string    `json:"nivel"`
+	FirstSeen   time.Time `json:"primer_reporte"`
+	LastSeen    time.Time `json:"ultimo_reporte"`
+	ReportCount int       `json:"reportes"`
+	Confirmed   int       `json:"confirmados"`
+	Dismissed   int       `json:"descartados"`
+	Trust       float64   `json:"confianza"`
+}
+
+// Pesos base por nivel y ajuste por cada alerta confirmada (o descartada) neta.
+const (
+	trustAnonymous = 0.3
+	trustCommunity = 0.5
+	trustStep      = 0.1
+	trustMaxEarned = 0.9 // el historial no alcanza por sí solo el peso de un vigía
+)
+
+// TrustScore calcula la confianza (0..1) de un remitente: un vigía vale 1 y
+// basta para escalar; un número comunitario parte de 0.5 y sube o baja según
+// su historial de alertas confirmadas y descartadas.
+func TrustScore(level string, confirmed, dismissed int) float64 {
+	switch level {
+	case TrustMonitor:
+		return 1
+	case TrustAnonymous:
+		return trustAnonymous
+	}
+	t := trustCommunity + trustStep*float64(confirmed-dismissed)
+	if t < 0 {
+		return 0
+	}
+	if t > trustMaxEarned {
+		return trustMaxEarned
+	}
+	return t
+}
+
+// TouchReporter registra un reporte de phone: lo crea si es nuevo y actualiza
+// último reporte y conteo.
+func (s *SQLiteStore) TouchReporter(phone string, at time.Time) error {
+	ts := formatTime(at)
+	_, err := s.db.Exec(`INSERT INTO reporters(phone, first_seen, last_seen, report_count) VALUES(?,?,?,1)
+        ON CONFLICT(phone) DO UPDATE SET last_seen = excluded.last_seen, report_count = report_count + 1,
+            first_seen = COALESCE(NULLIF(first_seen, ''), excluded.first_seen)`,
+		phone, ts, ts)
+	return err
+}
+
+const reporterColumns = `phone, name, level, COALESCE(first_seen, ''), COALESCE(last_seen, ''), report_count, confirmed, dismissed`
+
+func scanReporter(r rowScanner) (Reporter, error) {
+	var rep Reporter
+	var first, last string
+	if err := r.Scan(&rep.Phone, &rep.Name, &rep.Level, &first, &last, &rep.ReportCount, &rep.Confirmed, &rep.Dismissed); err != nil {
+		return Reporter{}, err
+	}
+	rep.FirstSeen = parseTime(first)
+	rep.LastSeen = parseTime(last)
+	rep.Trust = TrustScore(rep.Level, rep.Confirmed, rep.Dismissed)
+	return rep, nil
+}
+
+// GetReporter devuelve un remitente (sql.ErrNoRows si no existe).
+func (s *SQLiteStore) GetReporter(phone string) (Reporter, error) {
+	return scanReporter(s.db.QueryRow(`SELECT `+reporterColumns+` FROM reporters WHERE phone = ?`, phone))
+}
+
+// ListReporters lista los remitentes, más recientes primero.
+func (s *SQLiteStore) ListReporters() ([]Reporter, error) {
+	rows, err := s.db.Query(`SELECT ` + reporterColumns + ` FROM reporters ORDER BY last_seen DESC LIMIT 500`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := make([]Reporter, 0)
+	for rows.Next() {
+		r, err := scanReporter(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, r)
+	}
+	return out, rows.Err()
+}
+
+// SetReporterName asigna un nombre visible a un remitente (sql.ErrNoRows si no existe).
+func (s *SQLiteStore) SetReporterName(phone, name string) error {
+	res, err := s.db.Exec(`UPDATE reporters SET name = ? WHERE phone = ?`, name, phone)
+	if err != nil {
+		return err
+	}
+	return expectAffected(res)
+}
+
+// SetReporterLevel fija el nivel de confianza de un número. Lo registra si aún
+// no ha reportado, para poder dar de alta vigías por adelantado.
+func (s *SQLiteStore) SetReporterLevel(phone, level string) error {
+	if level != TrustCommunity && level != TrustMonitor {
+		return fmt.Errorf("storage: nivel de confianza inválido %q", level)
+	}
+	_, err := s.db.Exec(`INSERT INTO reporters(phone, level, first_seen, last_seen, report_count) VALUES(?,?,'','',0)
+        ON CONFLICT(phone) DO UPDATE SET level = excluded.level`, phone, level)
+	return err
+}
+
+// ReviewAlert registra la revisión de una alerta y ajusta los contadores de
+// confirmadas/descartadas de su remitente. Cambiar una revisión previa deshace
+// su efecto en el historial.
+func (s *SQLiteStore) ReviewAlert(id, result string) error {
+	if result != ReviewConfirmed && result != ReviewDismissed {
+		return fmt.Errorf("storage: revisión inválida %q", result)
+	}
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var reporter, prev string
+	if err := tx.QueryRow(`SELECT COALESCE(reporter, ''), COALESCE(review, '') FROM alerts WHERE id = ?`, id).Scan(&reporter, &prev); err != nil {
+		return err
+	}
+	if prev == result {
+		return nil
+	}
+	if _, err := tx.Exec(`UPDATE alerts SET review = ? WHERE id = ?`, result, id); err != nil {
+		return err
+	}
+	if reporter != "" {
+		if err := adjustReviewCount(tx, reporter, result, 1); err != nil {
+			return err
+		}
+		if prev != "" {
+			if err := adjustReviewCount(tx, reporter, prev, -1); err != nil {
+				return err
+			}
+		}
+	}
+	// Un reporte descartado (o rehabilitado) cambia el pico y el conteo de su incidente.
+	if err := recomputeAlertIncidents(tx, id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// recomputeAlertIncidents recalcula los incidentes a los que está vinculada
+// la alerta id.
+func recomputeAlertIncidents(tx *sql.Tx, id string) error {
+	rows, err := tx.Query(`SELECT incident_id FROM incident_alerts WHERE alert_id = ?`, id)
+	if err != nil {
+		return err
+	}
+	var ids []int64
+	for rows.Next() {
+		var inc int64
+		if err := rows.Scan(&inc); err != nil {
+			rows.Close()
+			return err
+		}
+		ids = append(ids, inc)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for _, inc := range ids {
+		if err := recomputeIncident(tx, inc); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func adjustReviewCount(tx *sql.Tx, phone, result string, delta int) error {
+	col := "confirmed"
+	if result == ReviewDismissed {
+		col = "dismissed"
+	}
+	_, err := tx.Exec(`UPDATE reporters SET `+col+` = MAX(0, `+col+` + ?) WHERE phone = ?`, delta, phone)
+	return err
+}
@@ -0,0 +1,189 @@
+package storage
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Tipos de sensor conocidos; el tipo decide el fenómeno de sus alertas.
+const (
+	SensorRiver = "nivel_rio"   // limnímetro: nivel del río
+	SensorRain  = "pluviometro" // precipitación por intervalo de medición
+)
+
+// Tipos de regla de un sensor.
+const (
+	SensorRuleLevel = "umbral"    // la lectura alcanza el valor
+	SensorRuleRise  = "subida"    // sube al menos el valor por hora dentro de la ventana
+	SensorRuleTotal = "acumulado" // la suma de lecturas dentro de la ventana alcanza el valor
+)
+
+// SensorRule es un umbral propio de un sensor.
+type SensorRule struct {
+	Kind      string  `json:"tipo"`
+	Value     float64 `json:"valor"`
+	WindowMin int     `json:"ventana_min,omitempty"` // subida y acumulado; por defecto 60
+	Severity  string  `json:"severidad,omitempty"`   // por defecto "alta"
+}
+
+// Sensor es una estación automática registrada (hidrométrica o pluviómetro).
+type Sensor struct {
+	ID        string       `json:"id"`
+	Name      string       `json:"nombre"`
+	Kind      string       `json:"tipo"`
+	Zone      string       `json:"zona"`
+	Lat       float64      `json:"lat"`
+	Lon       float64      `json:"lon"`
+	Unit      string       `json:"unidad,omitempty"`
+	Rules     []SensorRule `json:"reglas"`
+	CreatedAt time.Time    `json:"creado_en"`
+}
+
+// Reading es una lectura de un sensor.
+type Reading struct {
+	SensorID string    `json:"sensor,omitempty"`
+	Time     time.Time `json:"hora"`
+	Value    float64   `json:"valor"`
+}
+
+// SaveSensor registra un sensor o actualiza sus datos (conserva la fecha de alta).
+func (s *SQLiteStore) SaveSensor(sn Sensor) error {
+	rules, err := json.Marshal(sn.Rules)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(`INSERT INTO sensors(id, name, kind, zone, lat, lon, unit, rules, created_at) VALUES(?,?,?,?,?,?,?,?,?)
+        ON CONFLICT(id) DO UPDATE SET name = excluded.name, kind = excluded.kind, zone = excluded.zone,
+            lat = excluded.lat, lon = excluded.lon, unit = excluded.unit, rules = excluded.rules`,
+		sn.ID, sn.Name, sn.Kind, sn.Zone, sn.Lat, sn.Lon, sn.Unit, string(rules), formatTime(sn.CreatedAt))
+	return err
+}
+
+const sensorColumns = `id, name, kind, zone, lat, lon, unit, rules, created_at`
+
+func scanSensor(row interface{ Scan(...any) error }) (Sensor, error) {
+	var sn Sensor
+	var rules, created string
+	if err := row.Scan(&sn.ID, &sn.Name, &sn.Kind, &sn.Zone, &sn.Lat, &sn.Lon, &sn.Unit, &rules, &created); err != nil {
+		return sn, err
+	}
+	sn.CreatedAt = parseTime(created)
+	sn.Rules = []SensorRule{}
+	if rules != "" {
+		if err := json.Unmarshal([]byte(rules), &sn.Rules); err != nil {
+			return sn, err
+		}
+	}
+	return sn, nil
+}
+
+// GetSensor devuelve un sensor (sql.ErrNoRows si no está registrado).
+func (s *SQLiteStore) GetSensor(id string) (Sensor, error) {
+	return scanSensor(s.db.QueryRow(`SELECT `+sensorColumns+` FROM sensors WHERE id = ?`, id))
+}
+
+// ListSensors lista los sensores de una zona (todos si zone es "").
+func (s *SQLiteStore) ListSensors(zone string) ([]Sensor, error) {
+	q := `SELECT ` + sensorColumns + ` FROM sensors`
+	var args []any
+	if zone != "" {
+		q += ` WHERE zone = ?`
+		args = append(args, zone)
+	}
+	rows, err := s.db.Query(q+` ORDER BY id`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := make([]Sensor, 0)
+	for rows.Next() {
+		sn, err := scanSensor(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, sn)
+	}
+	return out, rows.Err()
+}
+
+// DeleteSensor da de baja un sensor y sus lecturas (sql.ErrNoRows si no existía).
+func (s *SQLiteStore) DeleteSensor(id string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	res, err := tx.Exec(`DELETE FROM sensors WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	if err := expectAffected(res); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM sensor_readings WHERE sensor_id = ?`, id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// SaveReadings guarda un lote de lecturas y devuelve cuántas eran nuevas; una
+// lectura repetida (mismo sensor y hora) se ignora, así el envío es idempotente.
+func (s *SQLiteStore) SaveReadings(sensorID string, readings []Reading) (int, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	stmt, err := tx.Prepare(`INSERT OR IGNORE INTO sensor_readings(sensor_id, taken_at, value) VALUES(?,?,?)`)
+	if err != nil {
+		return 0, err
+	}
+	defer stmt.Close()
+	saved := 0
+	for _, r := range readings {
+		res, err := stmt.Exec(sensorID, formatTime(r.Time), r.Value)
+		if err != nil {
+			return 0, err
+		}
+		if n, _ := res.RowsAffected(); n > 0 {
+			saved++
+		}
+	}
+	return saved, tx.Commit()
+}
+
+// LatestReading devuelve la lectura más reciente de un sensor no posterior a
+// notAfter (sql.ErrNoRows si no hay).
+func (s *SQLiteStore) LatestReading(sensorID string, notAfter time.Time) (Reading, error) {
+	r := Reading{SensorID: sensorID}
+	var ts string
+	err := s.db.QueryRow(`SELECT taken_at, value FROM sensor_readings WHERE sensor_id = ? AND taken_at <= ? ORDER BY taken_at DESC LIMIT 1`,
+		sensorID, formatTime(notAfter)).Scan(&ts, &r.Value)
+	if err != nil {
+		return Reading{}, err
+	}
+	r.Time = parseTime(ts)
+	return r, nil
+}
+
+// ListReadings devuelve las lecturas de un sensor desde since (inclusive),
+// en orden cronológico, hasta un máximo de 5000.
+func (s *SQLiteStore) ListReadings(sensorID string, since time.Time) ([]Reading, error) {
+	rows, err := s.db.Query(`SELECT taken_at, value FROM sensor_readings WHERE sensor_id = ? AND taken_at >= ? ORDER BY taken_at LIMIT 5000`,
+		sensorID, formatTime(since))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := make([]Reading, 0)
+	for rows.Next() {
+		r := Reading{SensorID: sensorID}
+		var ts string
+		if err := rows.Scan(&ts, &r.Value); err != nil {
+			return nil, err
+		}
+		r.Time = parseTime(ts)
+		out = append(out, r)
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,110 @@
+package storage
+
+import "time"
+
+// Motivos por los que un mensaje entrante se descarta sin generar alerta.
+const (
+	SuppressBlocked   = "bloqueado" // remitente en la lista de bloqueo
+	SuppressDuplicate = "duplicado" // mismo remitente, mismo texto, dentro de la ventana
+	SuppressSimilar   = "similar"   // texto casi idéntico de otro remitente (cadena)
+	SuppressRateLimit = "limite"    // el remitente superó el máximo de mensajes por ventana
+)
+
+// SuppressedMessage es un mensaje entrante descartado por el filtro anti-spam.
+type SuppressedMessage struct {
+	ID         int64     `json:"id"`
+	Phone      string    `json:"telefono,omitempty"`
+	Zone       string    `json:"zona"`
+	Text       string    `json:"texto"`
+	Channel    string    `json:"canal,omitempty"`
+	Reason     string    `json:"motivo"`
+	ReceivedAt time.Time `json:"recibido_en"`
+}
+
+// BlockedSender es un número cuyos mensajes se descartan.
+type BlockedSender struct {
+	Phone     string    `json:"telefono"`
+	Reason    string    `json:"motivo,omitempty"`
+	CreatedAt time.Time `json:"creado_en"`
+}
+
+// SaveSuppressed registra un mensaje descartado.
+func (s *SQLiteStore) SaveSuppressed(m SuppressedMessage) (int64, error) {
+	res, err := s.db.Exec(`INSERT INTO suppressed_messages(phone, zone, text, channel, reason, received_at) VALUES(?,?,?,?,?,?)`,
+		m.Phone, m.Zone, m.Text, m.Channel, m.Reason, formatTime(m.ReceivedAt))
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+// ListSuppressed lista los mensajes descartados, más recientes primero;
+// reason vacío lista todos los motivos.
+func (s *SQLiteStore) ListSuppressed(reason string) ([]SuppressedMessage, error) {
+	q := `SELECT id, phone, zone, text, channel, reason, received_at FROM suppressed_messages`
+	var args []any
+	if reason != "" {
+		q += ` WHERE reason = ?`
+		args = append(args, reason)
+	}
+	rows, err := s.db.Query(q+` ORDER BY id DESC LIMIT 500`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := make([]SuppressedMessage, 0)
+	for rows.Next() {
+		var m SuppressedMessage
+		var ts string
+		if err := rows.Scan(&m.ID, &m.Phone, &m.Zone, &m.Text, &m.Channel, &m.Reason, &ts); err != nil {
+			return nil, err
+		}
+		m.ReceivedAt = parseTime(ts)
+		out = append(out, m)
+	}
+	return out, rows.Err()
+}
+
+// BlockSender agrega (o actualiza) un número en la lista de bloqueo.
+func (s *SQLiteStore) BlockSender(b BlockedSender) error {
+	_, err := s.db.Exec(`INSERT INTO blocked_senders(phone, reason, created_at) VALUES(?,?,?)
+        ON CONFLICT(phone) DO UPDATE SET reason = excluded.reason`,
+		b.Phone, b.Reason, formatTime(b.CreatedAt))
+	return err
+}
+
+// UnblockSender quita un número de la lista de bloqueo (sql.ErrNoRows si no estaba).
+func (s *SQLiteStore) UnblockSender(phone string) error {
+	res, err := s.db.Exec(`DELETE FROM blocked_senders WHERE phone = ?`, phone)
+	if err != nil {
+		return err
+	}
+	return expectAffected(res)
+}
+
+// IsBlocked indica si el número está en la lista de bloqueo.
+func (s *SQLiteStore) IsBlocked(phone string) (bool, error) {
+	var n int
+	err := s.db.QueryRow(`SELECT COUNT(*) FROM blocked_senders WHERE phone = ?`, phone).Scan(&n)
+	return n > 0, err
+}
+
+// ListBlocked devuelve la lista de bloqueo.
+func (s *SQLiteStore) ListBlocked() ([]BlockedSender, error) {
+	rows, err := s.db.Query(`SELECT phone, reason, created_at FROM blocked_senders ORDER BY created_at DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := make([]BlockedSender, 0)
+	for rows.Next() {
+		var b BlockedSender
+		var ts string
+		if err := rows.Scan(&b.Phone, &b.Reason, &ts); err != nil {
+			return nil, err
+		}
+		b.CreatedAt = parseTime(ts)
+		out = append(out, b)
+	}
+	return out, rows.Err()
+}
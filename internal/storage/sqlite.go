@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"strings"
 	"time"
 
 	"alerta_climatica/internal/processing"
@@ -12,16 +13,99 @@ import (
 	_ "modernc.org/sqlite"
 )
 
-// Store es la interfaz mínima usada por el servidor para persistir alertas.
+// Store es la interfaz completa usada por el servidor para persistir
+// alertas. Los paquetes que solo necesitan una parte reciben la interfaz
+// angosta correspondiente (SpamStore, IncidentStore, OutboxStore...).
 type Store interface {
 	SaveAlert(a processing.Alert) error
 	ListAlerts() ([]processing.Alert, error)
 	// Zones-related methods
 	ImportZonesFromGeoJSON(data []byte) error
 	ListZones() ([]Zone, error)
+	// Dead-letter: alertas o mensajes que fallaron al persistirse/procesarse
+	AddDeadLetter(d DeadLetter) (int64, error)
+	GetDeadLetter(id int64) (DeadLetter, error)
+	ListDeadLetters(includeResolved bool) ([]DeadLetter, error)
+	UpdateDeadLetter(d DeadLetter) error
+	// Acuses de recibo de operadores sobre alertas
+	SaveAck(ack AlertAck) error
+	ListAcks(alertID string) ([]AlertAck, error)
+	OutboxStore
+	// Registro de remitentes (reporteros)
+	TouchReporter(phone string, at time.Time) error
+	ListReporters() ([]Reporter, error)
+	SetReporterName(phone, name string) error
+	GetReporter(phone string) (Reporter, error)
+	SetReporterLevel(phone, level string) error
+	// ReviewAlert marca una alerta como confirmada o descartada y actualiza el
+	// historial del remitente.
+	ReviewAlert(id, result string) error
+	IncidentStore
+	SpamStore
+	SensorStore
+	// Cola durable de mensajes pendientes (implementa processing.DurableQueue)
+	SaveQueued(msgs []processing.IncomingMessage) error
+	TakeQueued() ([]processing.IncomingMessage, error)
 	Close() error
 }
 
+// SubscriptionStore guarda las suscripciones de vecinos a zonas (comandos SMS).
+type SubscriptionStore interface {
+	AddSubscription(sub Subscription) (int64, error)
+	ListSubscriptions(zone string) ([]Subscription, error)
+	ListSubscriptionsByPhone(phone string) ([]Subscription, error)
+	UpdateSubscription(sub Subscription) error
+	DeleteSubscription(id int64) error
+	DeleteSubscriptionsByPhone(phone, zone string) (int64, error)
+}
+
+// OutboxStore es la bandeja de salida: envíos SMS a los suscriptores de una
+// zona, con reintentos y reportes de entrega.
+type OutboxStore interface {
+	SubscriptionStore
+	SaveDelivery(d Delivery) (int64, error)
+	UpdateDelivery(d Delivery) error
+	ListDeliveries(campaign, status string) ([]Delivery, error)
+	DueDeliveries(now time.Time, limit int) ([]Delivery, error)
+	MarkDelivery(id int64, providerID, status, errText string) error
+	GetDelivery(id int64, providerID string) (Delivery, error)
+	CampaignStats(campaign string) ([]CampaignStats, error)
+}
+
+// IncidentStore agrupa alertas relacionadas en incidentes.
+type IncidentStore interface {
+	CreateIncident(a processing.Alert) (int64, error)
+	AttachAlert(id int64, a processing.Alert) error
+	FindIncident(zone, typ string, at time.Time, gap time.Duration) (Incident, error)
+	GetIncident(id int64) (Incident, error)
+	ListIncidents(zone string) ([]Incident, error)
+	MergeIncidents(target int64, sources []int64) error
+	SplitIncident(id int64, alertIDs []string) (int64, error)
+}
+
+// SpamStore registra los mensajes descartados por el filtro anti-spam y la
+// lista de bloqueo.
+type SpamStore interface {
+	SaveSuppressed(m SuppressedMessage) (int64, error)
+	ListSuppressed(reason string) ([]SuppressedMessage, error)
+	BlockSender(b BlockedSender) error
+	UnblockSender(phone string) error
+	IsBlocked(phone string) (bool, error)
+	ListBlocked() ([]BlockedSender, error)
+}
+
+// SensorStore guarda los sensores automáticos (limnímetros, pluviómetros) y
+// sus lecturas.
+type SensorStore interface {
+	SaveSensor(sn Sensor) error
+	GetSensor(id string) (Sensor, error)
+	ListSensors(zone string) ([]Sensor, error)
+	DeleteSensor(id string) error
+	SaveReadings(sensorID string, readings []Reading) (int, error)
+	ListReadings(sensorID string, since time.Time) ([]Reading, error)
+	LatestReading(sensorID string, notAfter time.Time) (Reading, error)
+}
+
 // Zone representa una zona geográfica almacenada (geom es GeoJSON geometry as text).
 type Zone struct {
 	ID   int64  `json:"id"`
@@ -125,7 +209,15 @@ type SQLiteStore struct {
 
 // NewSQLite abre (o crea) la base de datos en path y aplica esquema mínimo.
 func NewSQLite(path string) (Store, error) {
-	db, err := sql.Open("sqlite", path)
+	// busy_timeout en cada conexión del pool: escrituras concurrentes (workers,
+	// acks, reintentos) esperan el lock en vez de fallar con SQLITE_BUSY.
+	dsn := path
+	if strings.Contains(dsn, "?") {
+		dsn += "&_pragma=busy_timeout(5000)"
+	} else {
+		dsn += "?_pragma=busy_timeout(5000)"
+	}
+	db, err := sql.Open("sqlite", dsn)
 	if err != nil {
 		return nil, err
 	}
@@ -143,24 +235,176 @@ func NewSQLite(path string) (Store, error) {
         message TEXT,
         extract TEXT,
         timestamp TEXT
+    );
+    CREATE TABLE IF NOT EXISTS zones (
+        id INTEGER PRIMARY KEY AUTOINCREMENT,
+        name TEXT,
+        geom TEXT,
+        created_at TEXT
+    );
+    CREATE TABLE IF NOT EXISTS dead_letters (
+        id INTEGER PRIMARY KEY AUTOINCREMENT,
+        kind TEXT,
+        payload TEXT,
+        error TEXT,
+        attempts INTEGER,
+        created_at TEXT,
+        next_attempt TEXT,
+        resolved INTEGER DEFAULT 0
+    );
+    CREATE TABLE IF NOT EXISTS alert_acks (
+        alert_id TEXT,
+        operator TEXT,
+        acked_at TEXT,
+        PRIMARY KEY (alert_id, operator)
+    );
+    CREATE TABLE IF NOT EXISTS subscriptions (
+        id INTEGER PRIMARY KEY AUTOINCREMENT,
+        phone TEXT NOT NULL,
+        zone TEXT NOT NULL,
+        created_at TEXT,
+        UNIQUE (phone, zone)
+    );
+    CREATE TABLE IF NOT EXISTS deliveries (
+        id INTEGER PRIMARY KEY AUTOINCREMENT,
+        campaign TEXT,
+        phone TEXT,
+        zone TEXT,
+        message TEXT,
+        status TEXT,
+        error TEXT,
+        created_at TEXT,
+        attempts INTEGER DEFAULT 0,
+        next_attempt_at TEXT DEFAULT '',
+        provider_id TEXT DEFAULT '',
+        updated_at TEXT DEFAULT ''
+    );
+    CREATE TABLE IF NOT EXISTS queued_messages (
+        id INTEGER PRIMARY KEY AUTOINCREMENT,
+        payload TEXT,
+        queued_at TEXT
+    );
+    CREATE TABLE IF NOT EXISTS reporters (
+        phone TEXT PRIMARY KEY,
+        name TEXT DEFAULT '',
+        first_seen TEXT,
+        last_seen TEXT,
+        report_count INTEGER DEFAULT 0
+    );
+    CREATE TABLE IF NOT EXISTS incidents (
+        id INTEGER PRIMARY KEY AUTOINCREMENT,
+        zone TEXT,
+        type TEXT,
+        started_at TEXT,
+        ended_at TEXT,
+        peak_severity TEXT,
+        report_count INTEGER DEFAULT 0
+    );
+    CREATE INDEX IF NOT EXISTS idx_incidents_zone_type ON incidents(zone, type, ended_at);
+    CREATE TABLE IF NOT EXISTS incident_alerts (
+        alert_id TEXT PRIMARY KEY,
+        incident_id INTEGER,
+        severity TEXT,
+        timestamp TEXT
+    );
+    CREATE INDEX IF NOT EXISTS idx_incident_alerts_incident ON incident_alerts(incident_id);
+    CREATE TABLE IF NOT EXISTS suppressed_messages (
+        id INTEGER PRIMARY KEY AUTOINCREMENT,
+        phone TEXT,
+        zone TEXT,
+        text TEXT,
+        channel TEXT,
+        reason TEXT,
+        received_at TEXT
+    );
+    CREATE TABLE IF NOT EXISTS blocked_senders (
+        phone TEXT PRIMARY KEY,
+        reason TEXT DEFAULT '',
+        created_at TEXT
+    );
+    CREATE TABLE IF NOT EXISTS sensors (
+        id TEXT PRIMARY KEY,
+        name TEXT DEFAULT '',
+        kind TEXT,
+        zone TEXT,
+        lat REAL DEFAULT 0,
+        lon REAL DEFAULT 0,
+        unit TEXT DEFAULT '',
+        rules TEXT DEFAULT '[]',
+        created_at TEXT
+    );
+    CREATE TABLE IF NOT EXISTS sensor_readings (
+        sensor_id TEXT NOT NULL,
+        taken_at TEXT NOT NULL,
+        value REAL,
+        PRIMARY KEY (sensor_id, taken_at)
     );`
 
 	if _, err := db.Exec(schema); err != nil {
 		db.Close()
 		return nil, err
 	}
+	for _, m := range columnMigrations {
+		if err := ensureColumn(db, m.table, m.column, m.decl); err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
 
 	return &SQLiteStore{db: db}, nil
 }
 
+// columnMigrations agrega columnas nuevas a tablas creadas por versiones
+// anteriores (CREATE TABLE IF NOT EXISTS no altera tablas existentes).
+var columnMigrations = []struct{ table, column, decl string }{
+	{"alerts", "reporter", "TEXT DEFAULT ''"},
+	{"alerts", "channel", "TEXT DEFAULT ''"},
+	{"alerts", "reporter_trust", "REAL DEFAULT 0"},
+	{"alerts", "review", "TEXT DEFAULT ''"},
+	{"alerts", "source", "TEXT DEFAULT ''"},
+	{"alerts", "validation", "TEXT DEFAULT ''"},
+	{"alerts", "weather_note", "TEXT DEFAULT ''"},
+	{"alerts", "confidence", "REAL DEFAULT 0"},
+	{"reporters", "level", "TEXT DEFAULT 'comunitario'"},
+	{"reporters", "confirmed", "INTEGER DEFAULT 0"},
+	{"reporters", "dismissed", "INTEGER DEFAULT 0"},
+	{"deliveries", "attempts", "INTEGER DEFAULT 0"},
+	{"deliveries", "next_attempt_at", "TEXT DEFAULT ''"},
+	{"deliveries", "provider_id", "TEXT DEFAULT ''"},
+	{"deliveries", "updated_at", "TEXT DEFAULT ''"},
+}
+
+// ensureColumn agrega table.column si todavía no existe.
+func ensureColumn(db *sql.DB, table, column, decl string) error {
+	rows, err := db.Query(`SELECT name FROM pragma_table_info(?)`, table)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return err
+		}
+		if name == column {
+			return nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	_, err = db.Exec(fmt.Sprintf(`ALTER TABLE %s ADD COLUMN %s %s`, table, column, decl))
+	return err
+}
+
 func (s *SQLiteStore) SaveAlert(a processing.Alert) error {
-	_, err := s.db.Exec(`INSERT OR REPLACE INTO alerts(id, zone, type, severity, message, extract, timestamp) VALUES(?,?,?,?,?,?,?)`,
-		a.ID, a.Zone, a.Type, a.Severity, a.Message, a.Extract, a.Timestamp.UTC().Format(time.RFC3339))
+	_, err := s.db.Exec(`INSERT OR REPLACE INTO alerts(id, zone, type, severity, message, extract, reporter, channel, reporter_trust, review, source, validation, weather_note, confidence, timestamp) VALUES(?,?,?,?,?,?,?,?,?,?,?,?,?,?,?)`,
+		a.ID, a.Zone, a.Type, a.Severity, a.Message, a.Extract, a.Reporter, a.Channel, a.ReporterTrust, a.Review, a.Source, a.Validation, a.WeatherNote, a.Confidence, a.Timestamp.UTC().Format(time.RFC3339))
 	return err
 }
 
 func (s *SQLiteStore) ListAlerts() ([]processing.Alert, error) {
-	rows, err := s.db.Query(`SELECT id, zone, type, severity, message, extract, timestamp FROM alerts ORDER BY timestamp DESC LIMIT 500`)
+	rows, err := s.db.Query(`SELECT id, zone, type, severity, message, extract, reporter, channel, reporter_trust, review, source, validation, weather_note, confidence, timestamp FROM alerts ORDER BY timestamp DESC LIMIT 500`)
 	if err != nil {
 		return nil, err
 	}
@@ -170,7 +414,7 @@ func (s *SQLiteStore) ListAlerts() ([]processing.Alert, error) {
 	for rows.Next() {
 		var a processing.Alert
 		var ts string
-		if err := rows.Scan(&a.ID, &a.Zone, &a.Type, &a.Severity, &a.Message, &a.Extract, &ts); err != nil {
+		if err := rows.Scan(&a.ID, &a.Zone, &a.Type, &a.Severity, &a.Message, &a.Extract, &a.Reporter, &a.Channel, &a.ReporterTrust, &a.Review, &a.Source, &a.Validation, &a.WeatherNote, &a.Confidence, &ts); err != nil {
 			return nil, err
 		}
 		t, err := time.Parse(time.RFC3339, ts)
@@ -1,6 +1,7 @@
 package storage
 
 import (
+	"database/sql"
 	"os"
 	"path/filepath"
 	"testing"
@@ -51,3 +52,147 @@ func TestSQLiteSaveAndList(t *testing.T) {
 		t.Fatalf("saved alert not found in list")
 	}
 }
+
+func TestDeadLetterRoundTrip(t *testing.T) {
+	s, err := NewSQLite(filepath.Join(t.TempDir(), "dl.db"))
+	if err != nil {
+		t.Fatalf("NewSQLite failed: %v", err)
+	}
+	defer s.Close()
+
+	now := time.Now().UTC().Truncate(time.Second)
+	id, err := s.AddDeadLetter(DeadLetter{Kind: DeadLetterPersist, Payload: `{"id":"x"}`, Error: "disk full", Attempts: 1, CreatedAt: now, NextAttempt: now})
+	if err != nil {
+		t.Fatalf("AddDeadLetter failed: %v", err)
+	}
+
+	d, err := s.GetDeadLetter(id)
+	if err != nil {
+		t.Fatalf("GetDeadLetter failed: %v", err)
+	}
+	if d.Kind != DeadLetterPersist || d.Attempts != 1 || !d.NextAttempt.Equal(now) {
+		t.Fatalf("unexpected dead-letter: %+v", d)
+	}
+
+	d.Resolved = true
+	d.Attempts = 2
+	if err := s.UpdateDeadLetter(d); err != nil {
+		t.Fatalf("UpdateDeadLetter failed: %v", err)
+	}
+	open, err := s.ListDeadLetters(false)
+	if err != nil {
+		t.Fatalf("ListDeadLetters failed: %v", err)
+	}
+	if len(open) != 0 {
+		t.Fatalf("expected no open dead-letters, got %d", len(open))
+	}
+	all, _ := s.ListDeadLetters(true)
+	if len(all) != 1 || all[0].Attempts != 2 {
+		t.Fatalf("unexpected list with resolved: %+v", all)
+	}
+}
+
+func TestQueuedMessagesRoundTrip(t *testing.T) {
+	s, err := NewSQLite(filepath.Join(t.TempDir(), "queue.db"))
+	if err != nil {
+		t.Fatalf("NewSQLite failed: %v", err)
+	}
+	defer s.Close()
+
+	msgs := []processing.IncomingMessage{{Zone: "Zona Norte", Text: "uno"}, {Zone: "Zona Sur", Text: "dos"}}
+	if err := s.SaveQueued(msgs); err != nil {
+		t.Fatalf("SaveQueued failed: %v", err)
+	}
+	got, err := s.TakeQueued()
+	if err != nil {
+		t.Fatalf("TakeQueued failed: %v", err)
+	}
+	if len(got) != 2 || got[0].Text != "uno" || got[1].Zone != "Zona Sur" {
+		t.Fatalf("unexpected queued messages: %+v", got)
+	}
+	if again, _ := s.TakeQueued(); len(again) != 0 {
+		t.Fatalf("expected empty queue after take, got %+v", again)
+	}
+}
+
+func TestReportersAndAlertReporterColumns(t *testing.T) {
+	s, err := NewSQLite(filepath.Join(t.TempDir(), "reporters.db"))
+	if err != nil {
+		t.Fatalf("NewSQLite failed: %v", err)
+	}
+	defer s.Close()
+
+	now := time.Now().UTC().Truncate(time.Second)
+	a := processing.Alert{ID: "r1", Zone: "Zona Sur", Type: "lluvia", Severity: "alta", Reporter: "+51987654321", Channel: "sms", Timestamp: now}
+	if err := s.SaveAlert(a); err != nil {
+		t.Fatalf("SaveAlert failed: %v", err)
+	}
+	list, _ := s.ListAlerts()
+	if len(list) != 1 || list[0].Reporter != a.Reporter || list[0].Channel != "sms" {
+		t.Fatalf("reporter/channel not persisted: %+v", list)
+	}
+
+	for i := 0; i < 3; i++ {
+		if err := s.TouchReporter(a.Reporter, now.Add(time.Duration(i)*time.Minute)); err != nil {
+			t.Fatalf("TouchReporter failed: %v", err)
+		}
+	}
+	if err := s.SetReporterName(a.Reporter, "Vigía Rosa"); err != nil {
+		t.Fatalf("SetReporterName failed: %v", err)
+	}
+	reps, err := s.ListReporters()
+	if err != nil || len(reps) != 1 {
+		t.Fatalf("ListReporters: %+v, %v", reps, err)
+	}
+	r := reps[0]
+	if r.ReportCount != 3 || r.Name != "Vigía Rosa" || !r.FirstSeen.Equal(now) || !r.LastSeen.Equal(now.Add(2*time.Minute)) {
+		t.Fatalf("unexpected reporter: %+v", r)
+	}
+}
+
+func TestReviewAlertAdjustsReporterTrust(t *testing.T) {
+	s, err := NewSQLite(filepath.Join(t.TempDir(), "trust.db"))
+	if err != nil {
+		t.Fatalf("NewSQLite failed: %v", err)
+	}
+	defer s.Close()
+
+	phone := "+51987654321"
+	now := time.Now().UTC()
+	for _, id := range []string{"t1", "t2"} {
+		if err := s.SaveAlert(processing.Alert{ID: id, Zone: "Zona Sur", Type: "lluvia", Severity: "alta", Reporter: phone, Timestamp: now}); err != nil {
+			t.Fatalf("SaveAlert failed: %v", err)
+		}
+	}
+	if err := s.TouchReporter(phone, now); err != nil {
+		t.Fatalf("TouchReporter failed: %v", err)
+	}
+	if err := s.ReviewAlert("t1", ReviewConfirmed); err != nil {
+		t.Fatalf("ReviewAlert failed: %v", err)
+	}
+	if err := s.ReviewAlert("t2", ReviewConfirmed); err != nil {
+		t.Fatalf("ReviewAlert failed: %v", err)
+	}
+	// Corregir una revisión deshace su efecto previo.
+	if err := s.ReviewAlert("t2", ReviewDismissed); err != nil {
+		t.Fatalf("ReviewAlert failed: %v", err)
+	}
+	r, err := s.GetReporter(phone)
+	if err != nil {
+		t.Fatalf("GetReporter failed: %v", err)
+	}
+	if r.Level != TrustCommunity || r.Confirmed != 1 || r.Dismissed != 1 || r.Trust != 0.5 {
+		t.Fatalf("unexpected reporter after reviews: %+v", r)
+	}
+
+	if err := s.SetReporterLevel("+51911111111", TrustMonitor); err != nil {
+		t.Fatalf("SetReporterLevel failed: %v", err)
+	}
+	v, err := s.GetReporter("+51911111111")
+	if err != nil || v.Trust != 1 {
+		t.Fatalf("pre-registered vigía: %+v, %v", v, err)
+	}
+	if err := s.ReviewAlert("missing", ReviewConfirmed); err != sql.ErrNoRows {
+		t.Fatalf("expected sql.ErrNoRows for unknown alert, got %v", err)
+	}
+}
@@ -0,0 +1,122 @@
+package storage
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Subscription vincula un teléfono con una zona cuyas alertas quiere recibir por SMS.
+type Subscription struct {
+	ID        int64     `json:"id"`
+	Phone     string    `json:"telefono"`
+	Zone      string    `json:"zona"`
+	CreatedAt time.Time `json:"creado_en"`
+}
+
+// AddSubscription crea la suscripción (phone, zone). Si ya existe devuelve su id sin duplicarla.
+func (s *SQLiteStore) AddSubscription(sub Subscription) (int64, error) {
+	if sub.CreatedAt.IsZero() {
+		sub.CreatedAt = time.Now()
+	}
+	if _, err := s.db.Exec(`INSERT OR IGNORE INTO subscriptions(phone, zone, created_at) VALUES(?,?,?)`,
+		sub.Phone, sub.Zone, formatTime(sub.CreatedAt)); err != nil {
+		return 0, err
+	}
+	var id int64
+	err := s.db.QueryRow(`SELECT id FROM subscriptions WHERE phone = ? AND zone = ?`, sub.Phone, sub.Zone).Scan(&id)
+	return id, err
+}
+
+// ListSubscriptions lista las suscripciones de una zona (todas si zone es "").
+func (s *SQLiteStore) ListSubscriptions(zone string) ([]Subscription, error) {
+	q := `SELECT id, phone, zone, created_at FROM subscriptions`
+	args := []any{}
+	if zone != "" {
+		q += ` WHERE zone = ?`
+		args = append(args, zone)
+	}
+	q += ` ORDER BY id`
+	rows, err := s.db.Query(q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := make([]Subscription, 0)
+	for rows.Next() {
+		var sub Subscription
+		var ts string
+		if err := rows.Scan(&sub.ID, &sub.Phone, &sub.Zone, &ts); err != nil {
+			return nil, err
+		}
+		sub.CreatedAt = parseTime(ts)
+		out = append(out, sub)
+	}
+	return out, rows.Err()
+}
+
+// ListSubscriptionsByPhone lista las zonas a las que está suscrito un teléfono.
+func (s *SQLiteStore) ListSubscriptionsByPhone(phone string) ([]Subscription, error) {
+	rows, err := s.db.Query(`SELECT id, phone, zone, created_at FROM subscriptions WHERE phone = ? ORDER BY zone`, phone)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := make([]Subscription, 0)
+	for rows.Next() {
+		var sub Subscription
+		var ts string
+		if err := rows.Scan(&sub.ID, &sub.Phone, &sub.Zone, &ts); err != nil {
+			return nil, err
+		}
+		sub.CreatedAt = parseTime(ts)
+		out = append(out, sub)
+	}
+	return out, rows.Err()
+}
+
+// UpdateSubscription cambia teléfono y zona de una suscripción existente
+// (sql.ErrNoRows si el id no existe).
+func (s *SQLiteStore) UpdateSubscription(sub Subscription) error {
+	res, err := s.db.Exec(`UPDATE subscriptions SET phone = ?, zone = ? WHERE id = ?`, sub.Phone, sub.Zone, sub.ID)
+	if err != nil {
+		return err
+	}
+	return expectAffected(res)
+}
+
+// DeleteSubscription borra una suscripción por id (sql.ErrNoRows si no existe).
+func (s *SQLiteStore) DeleteSubscription(id int64) error {
+	res, err := s.db.Exec(`DELETE FROM subscriptions WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	return expectAffected(res)
+}
+
+// DeleteSubscriptionsByPhone borra las suscripciones de un teléfono a una zona
+// (a todas si zone es "") y devuelve cuántas se eliminaron.
+func (s *SQLiteStore) DeleteSubscriptionsByPhone(phone, zone string) (int64, error) {
+	q := `DELETE FROM subscriptions WHERE phone = ?`
+	args := []any{phone}
+	if zone != "" {
+		q += ` AND zone = ?`
+		args = append(args, zone)
+	}
+	res, err := s.db.Exec(q, args...)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// expectAffected convierte un UPDATE/DELETE sin filas afectadas en sql.ErrNoRows.
+func expectAffected(res sql.Result) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
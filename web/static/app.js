@@ -5,22 +5,27 @@ async function fetchJSON(url, opts = {}) {
 }
 let map = null
 let geojsonLayer = null
+let prealertLayer = null
 
 function colorForStatus(status) {
   switch (status) {
     case 'rojo': return '#e74c3c'
     case 'amarillo': return '#f1c40f'
+    case 'pendiente': return '#95a5a6'
     default: return '#2ecc71'
   }
 }
 
 function styleFunc(feature) {
   const status = (feature.properties && feature.properties.status) || 'verde'
+  // Reportes sin corroborar: borde punteado para distinguirlos de un estado confirmado.
+  const pending = status === 'pendiente'
   return {
-    color: '#333',
-    weight: 1,
+    color: pending ? '#7f8c8d' : '#333',
+    weight: pending ? 2 : 1,
+    dashArray: pending ? '6 4' : null,
     fillColor: colorForStatus(status),
-    fillOpacity: 0.6,
+    fillOpacity: pending ? 0.35 : 0.6,
   }
 }
 
@@ -40,7 +45,11 @@ async function initMap() {
     maxZoom: 19,
     attribution: '© OpenStreetMap contributors'
   }).addTo(map)
+  // Pre-alertas por pronóstico en su propia capa, activable desde el control.
+  prealertLayer = L.layerGroup().addTo(map)
+  L.control.layers(null, { 'Pre-alertas (pronóstico)': prealertLayer }).addTo(map)
   await loadZones()
+  await loadPrealerts()
 }
 
 async function loadZones() {
@@ -63,25 +72,124 @@ async function loadZones() {
   }
 }
 
+function prealertPopup(p) {
+  const inicio = new Date(p.inicio_previsto).toLocaleString()
+  const expira = new Date(p.expira).toLocaleString()
+  return `<strong>${p.zona}</strong> • pre-alerta ${p.fenomeno}<br/>${p.mensaje}<br/>Inicio previsto: ${inicio}<br/>Hasta: ${expira}`
+}
+
+// Las pre-alertas solo existen si el servidor consulta el pronóstico; un 404
+// significa que la función está desactivada y la capa queda vacía.
+async function loadPrealerts() {
+  if (!prealertLayer) return
+  try {
+    const res = await fetch('/api/prealerts')
+    const list = res.ok ? await res.json() : []
+    prealertLayer.clearLayers()
+    for (const p of list || []) {
+      L.circleMarker([p.lat, p.lon], {
+        radius: p.severidad === 'alta' ? 14 : 10,
+        color: '#e67e22',
+        weight: 2,
+        dashArray: '4 4',
+        fillColor: p.severidad === 'alta' ? '#e67e22' : '#f1c40f',
+        fillOpacity: 0.25,
+      }).bindPopup(prealertPopup(p)).addTo(prealertLayer)
+    }
+  } catch (e) {
+    console.error('prealerts', e)
+  }
+}
+
 function badgeFor(alert) {
   const color = alert.severidad === 'crítica' ? 'rojo' : (alert.severidad === 'alta' ? 'amarillo' : 'verde')
   return `<span class="badge ${color}">${alert.severidad}</span>`
 }
 
+// Contraste del reporte con el tiempo observado y confianza resultante.
+function validationFor(alert) {
+  if (alert.fuente) return ''
+  const conf = typeof alert.confianza === 'number' ? ` ${Math.round(alert.confianza * 100)}%` : ''
+  const labels = { corroborada: '✓ tiempo', dudosa: '? tiempo', sin_datos: 'sin datos' }
+  const label = labels[alert.validacion]
+  if (!label) return conf ? `<span class="validation">${conf.trim()}</span>` : ''
+  const title = alert.dato_meteo ? ` title="${alert.dato_meteo}"` : ''
+  return `<span class="validation ${alert.validacion}"${title}>${label}${conf}</span>`
+}
+
+let alerts = []
+
+function renderAlerts() {
+  const list = document.getElementById('alerts')
+  list.innerHTML = alerts.map(a => {
+    const t = new Date(a.timestamp)
+    const fuente = a.fuente ? ' • ' + (a.fuente === 'sensor' ? 'sensor' : 'pronóstico') : ''
+    const meta = `${a.zona} • ${t.toLocaleTimeString()}${fuente}${a.extracto ? ' • ' + a.extracto : ''}${a.telefono ? ' • ' + a.telefono : ''}`
+    return `<li><div><strong>${a.tipo}</strong>${validationFor(a)}<div class="meta">${meta}</div><div>${a.mensaje}</div></div>${badgeFor(a)}</li>`
+  }).join('')
+}
+
 async function refreshAlerts() {
   try {
-    const alerts = await fetchJSON('/api/alerts')
-    const list = document.getElementById('alerts')
-    list.innerHTML = alerts.map(a => {
-      const t = new Date(a.timestamp)
-      const meta = `${a.zona} • ${t.toLocaleTimeString()}${a.extracto ? ' • ' + a.extracto : ''}`
-      return `<li><div><strong>${a.tipo}</strong><div class="meta">${meta}</div><div>${a.mensaje}</div></div>${badgeFor(a)}</li>`
-    }).join('')
+    alerts = await fetchJSON('/api/alerts') || []
+    renderAlerts()
   } catch (e) {
     console.error('alerts', e)
   }
 }
 
+// Actualiza el color de una zona en el mapa sin volver a pedir el GeoJSON.
+function applyZoneStatus(change) {
+  if (!geojsonLayer) return
+  let found = false
+  geojsonLayer.eachLayer(layer => {
+    const props = layer.feature && layer.feature.properties
+    if (props && props.name === change.zona) {
+      props.status = change.estado
+      layer.setStyle(styleFunc(layer.feature))
+      layer.setPopupContent(`<strong>${props.name}</strong><br/>Estado: ${props.status}`)
+      found = true
+    }
+  })
+  if (!found) loadZones()
+}
+
+// Polling de respaldo: solo activo mientras no haya conexión SSE.
+let pollTimer = null
+
+function startPolling() {
+  if (pollTimer) return
+  pollTimer = setInterval(() => { refreshAlerts(); loadZones(); }, 3000)
+}
+
+function stopPolling() {
+  clearInterval(pollTimer)
+  pollTimer = null
+}
+
+// Suscripción push vía Server-Sent Events. EventSource reconecta solo y envía
+// Last-Event-ID, así que el servidor reenvía lo que se haya perdido.
+function connectStream() {
+  if (!window.EventSource) {
+    startPolling()
+    return
+  }
+  const es = new EventSource('/api/stream')
+  es.onopen = () => {
+    stopPolling()
+    // Resincronizar por si hubo cambios mientras no había conexión.
+    refreshAlerts()
+    loadZones()
+  }
+  es.onerror = () => startPolling()
+  es.addEventListener('alert', ev => {
+    const a = JSON.parse(ev.data)
+    alerts = [a, ...alerts.filter(x => x.id !== a.id)].slice(0, 500)
+    renderAlerts()
+  })
+  es.addEventListener('zone_status', ev => applyZoneStatus(JSON.parse(ev.data)))
+}
+
 async function submitSMS(ev) {
   ev.preventDefault()
   const zona = document.getElementById('zona').value
@@ -93,8 +201,8 @@ async function submitSMS(ev) {
     body: JSON.stringify({ zona, texto }),
   })
   document.getElementById('texto').value = ''
-  // dar tiempo a que el worker procese
-  setTimeout(() => { refreshAlerts(); loadZones(); }, 150)
+  // Con SSE la alerta llega por push; sin conexión, refrescar tras procesar.
+  if (pollTimer) setTimeout(() => { refreshAlerts(); loadZones(); }, 150)
 }
 
 async function resetZones() {
@@ -107,5 +215,7 @@ document.getElementById('resetBtn').addEventListener('click', resetZones)
 
 initMap()
 refreshAlerts()
-setInterval(() => { refreshAlerts(); loadZones(); }, 3000)
+connectStream()
+// El pronóstico cambia despacio: basta con refrescar la capa cada 5 minutos.
+setInterval(loadPrealerts, 5 * 60 * 1000)
 